@@ -5,12 +5,20 @@ IMAGE_TAG=adi-ber/vjal-platform:latest
 BUILD_DIR=build
 ZIP_NAME=vjal-platform-offline.zip
 
+# A license.json signed with a development key is only trusted by binaries
+# built with -tags devkeys, so refuse to package one the binary would reject.
+GO_TAGS="${GO_TAGS:-}"
+if grep -q '"kid": *"vjal-dev-' license.json && [[ " ${GO_TAGS//,/ } " != *" devkeys "* ]]; then
+  echo "build.sh: license.json is signed with a development key; set GO_TAGS=devkeys or bundle a release license" >&2
+  exit 1
+fi
+
 # Clean previous
 rm -rf "\${BUILD_DIR}"
 mkdir -p "\${BUILD_DIR}/offline" "\${BUILD_DIR}/bin"
 
 # Build Docker image
-docker build -t "\${IMAGE_TAG}" .
+docker build --build-arg GO_TAGS="${GO_TAGS}" -t "\${IMAGE_TAG}" .
 
 # Create a container and copy out the compressed binary
 CID=$(docker create "\${IMAGE_TAG}")
@@ -18,9 +26,12 @@ docker cp "\${CID}:/\${APP_NAME}" "\${BUILD_DIR}/bin/\${APP_NAME}"
 docker rm "\${CID}"
 
 # Copy runtime assets into the offline folder
-cp config.json license.json "\${BUILD_DIR}/offline/"
+cp config.json license.json llm_prompts.enc "\${BUILD_DIR}/offline/"
 mkdir -p "\${BUILD_DIR}/offline/forms" "\${BUILD_DIR}/offline/docs"
-cp -r forms docs "\${BUILD_DIR}/offline/"
+cp -r forms definitions docs "\${BUILD_DIR}/offline/"
+
+# Sign the manifest of shipped assets with the release key
+go run ./cmd/vjal-manifest generate -signing-key "${MANIFEST_KEY:?set MANIFEST_KEY to the release signing key}" -root "\${BUILD_DIR}/offline"
 
 # Move binary into offline folder
 cp "\${BUILD_DIR}/bin/\${APP_NAME}" "\${BUILD_DIR}/offline/"
@@ -3,21 +3,37 @@ package main
 import (
   "context"
   "encoding/json"
+  "errors"
+  "flag"
   "fmt"
   "html/template"
   "log"
   "net/http"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
 
+  "github.com/adi-ber/vjal-platform/pkg/audit"
   "github.com/adi-ber/vjal-platform/pkg/config"
   "github.com/adi-ber/vjal-platform/pkg/form"
   "github.com/adi-ber/vjal-platform/pkg/license"
   "github.com/adi-ber/vjal-platform/pkg/llm"
   "github.com/adi-ber/vjal-platform/pkg/output"
+  "github.com/adi-ber/vjal-platform/pkg/pii"
+  "github.com/adi-ber/vjal-platform/pkg/prompts"
+  "github.com/adi-ber/vjal-platform/pkg/sse"
+  "github.com/adi-ber/vjal-platform/pkg/storage"
+  "github.com/adi-ber/vjal-platform/pkg/usage"
   "github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const maxRounds = 4
 
+// configPollInterval is how often config files are checked for changes.
+const configPollInterval = 5 * time.Second
+
 type pageData struct {
   Round         int
   MaxRounds     int
@@ -25,8 +41,50 @@ type pageData struct {
 }
 
 type dynamicRequest struct {
-  Round   int               `json:"round"`
-  Answers map[string]string `json:"answers"`
+  Round     int               `json:"round"`
+  Answers   map[string]string `json:"answers"`
+  Questions map[string]string `json:"questions"` // question ID → label as asked
+}
+
+// promptData fills the dynamicSystem, dynamicFollowUp and dynamicReport
+// prompts. History is only used by bundles packed before dynamicSystem
+// existed, which put the whole interview into one message.
+type promptData struct {
+  Round     int
+  MaxRounds int
+  History   string
+}
+
+// conversation replays the interview as chat turns: the system prompt,
+// then each question as an assistant message followed by its answer, then
+// instruction as the final user message. Questions are ordered as asked:
+// the initial fields first, then q2, q3, ….
+func conversation(system string, initial []form.PromptField, req dynamicRequest, instruction string) []llm.Message {
+  var msgs []llm.Message
+  if system != "" {
+    msgs = append(msgs, llm.Message{Role: llm.RoleSystem, Content: system})
+  }
+  rank := func(id string) int {
+    for i, f := range initial {
+      if f.ID == id {
+        return i - len(initial)
+      }
+    }
+    n, _ := strconv.Atoi(strings.TrimPrefix(id, "q"))
+    return n
+  }
+  ids := make([]string, 0, len(req.Answers))
+  for id := range req.Answers {
+    ids = append(ids, id)
+  }
+  sort.Slice(ids, func(i, j int) bool { return rank(ids[i]) < rank(ids[j]) })
+  for _, id := range ids {
+    if label := req.Questions[id]; label != "" {
+      msgs = append(msgs, llm.Message{Role: llm.RoleAssistant, Content: label})
+    }
+    msgs = append(msgs, llm.Message{Role: llm.RoleUser, Content: req.Answers[id]})
+  }
+  return append(msgs, llm.Message{Role: llm.RoleUser, Content: instruction})
 }
 
 type Question struct {
@@ -42,21 +100,53 @@ type dynamicResponse struct {
 var tmpl = template.Must(template.ParseFiles("cmd/dynamic-example/templates/dynamic_form.html"))
 
 func main() {
-  cfg, err := config.Load("config.json")
+  flags := config.RegisterFlags(flag.CommandLine)
+  flag.Parse()
+  cfgMgr, err := config.NewManager(config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys}, configPollInterval)
   if err != nil {
     log.Fatalf("config load: %v", err)
   }
+  cfg := cfgMgr.Current()
+  log.Printf("config sources:\n%s", cfgMgr.Provenance())
 
-  lic, err := license.NewValidator(cfg).Validate(context.Background())
+  if err := license.CheckAssets(cfg); err != nil {
+    log.Fatalf("assets: %v", err)
+  }
+
+  if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
+    log.Fatalf("output dir: %v", err)
+  }
+  store, err := storage.Open(cfg, license.SecretKeys)
   if err != nil {
+    log.Fatalf("storage: %v", err)
+  }
+  rec := audit.New(store)
+
+  licMgr := license.NewManager(license.NewValidator(cfg), license.WithStatusHook(rec.LicenseEvent))
+  if _, err := licMgr.Refresh(context.Background()); !licMgr.Active() {
     log.Fatalf("license: %v", err)
   }
+  go licMgr.Run(context.Background())
+
+  promptSet, err := prompts.Load(prompts.DefaultPath, licMgr.License())
+  if err != nil {
+    log.Fatalf("prompts: %v", err)
+  }
 
-  ai, err := llm.New(cfg, lic)
+  tracker := usage.NewTracker(store, licMgr)
+
+  ledger := llm.NewLedger(store)
+  ai, err := llm.NewReloadable(cfg, func(c *config.AppConfig) (llm.Client, error) {
+    return llm.New(c, licMgr.License(), llm.WithAudit(rec), llm.WithCache(store), llm.WithLedger(ledger))
+  })
   if err != nil {
     log.Fatalf("llm init: %v", err)
   }
 
+  cfgMgr.Subscribe("license", licMgr)
+  cfgMgr.Subscribe("llm", ai)
+  go cfgMgr.Run(context.Background())
+
   renderer := output.NewRenderer()
 
   defs, err := form.LoadDefinitionsDir("definitions")
@@ -67,6 +157,7 @@ func main() {
   if !ok {
     log.Fatalf("no definition for key complexProcess")
   }
+  sensitive := form.SensitiveFields(initialFields)
   questionsJSON, err := json.Marshal(initialFields)
   if err != nil {
     log.Fatalf("marshal initial questions: %v", err)
@@ -90,59 +181,174 @@ func main() {
     }
   })
 
-  http.HandleFunc("/dynamic-submit", func(w http.ResponseWriter, r *http.Request) {
-    var req dynamicRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-      http.Error(w, "invalid JSON", http.StatusBadRequest)
-      return
-    }
-
+  // nextTurn builds the chat for a submission: the next follow-up question
+  // or, after the last round, the report, which is gated through sub as
+  // reportFormat. It returns the context to call the LLM with. If it fails
+  // it has already written the response.
+  nextTurn := func(w http.ResponseWriter, r *http.Request, sub *usage.Submission, req dynamicRequest, reportFormat string) (context.Context, []llm.Message, bool) {
     history := ""
     count := 0
+    answers := make(map[string]interface{}, len(req.Answers))
     for q, ans := range req.Answers {
       count++
       history += fmt.Sprintf("%d. %s: %s\n", count, q, ans)
+      answers[q] = ans
+    }
+    base := pii.WithValues(r.Context(), sensitive.Values(answers))
+
+    // The middleware already checked the route; this records its feature.
+    if err := sub.Route(licMgr, "/dynamic-submit"); err != nil {
+      license.WriteProblem(w, r, err)
+      return nil, nil, false
     }
 
-    next := req.Round + 1
-    if next <= maxRounds {
-      prompt := fmt.Sprintf(
-        "Round %d of %d.\n\nThe user has provided the following answers so far:\n%s\n\nPlease ask exactly one concise follow‑up question to clarify or gather missing details.",
-        next, maxRounds, history,
-      )
-      question, err := ai.Prompt(r.Context(), prompt)
+    system := ""
+    if _, ok := promptSet["dynamicSystem"]; ok {
+      rendered, err := promptSet.Render("dynamicSystem", promptData{MaxRounds: maxRounds})
       if err != nil {
-        http.Error(w, "LLM error: "+err.Error(), http.StatusInternalServerError)
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return nil, nil, false
+      }
+      system = rendered
+    }
+
+    key, data := "dynamicReport", promptData{MaxRounds: maxRounds, History: history}
+    if next := req.Round + 1; next <= maxRounds {
+      key, data.Round = "dynamicFollowUp", next
+    } else if err := sub.Format(licMgr, reportFormat); err != nil {
+      license.WriteProblem(w, r, err)
+      return nil, nil, false
+    }
+    prompt, err := promptSet.Render(key, data)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusInternalServerError)
+      return nil, nil, false
+    }
+    if err := sub.Consume(usage.LLMCalls, 1); err != nil {
+      usage.WriteProblem(w, r, err)
+      return nil, nil, false
+    }
+    return audit.WithPromptKey(base, key), conversation(system, initialFields, req, prompt), true
+  }
+
+  http.Handle("/dynamic-submit", licMgr.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    var req dynamicRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+      http.Error(w, "invalid JSON", http.StatusBadRequest)
+      return
+    }
+    sub := tracker.Submission(license.DefaultGate)
+    ctx, messages, ok := nextTurn(w, r, sub, req, "pdf")
+    if !ok {
+      return
+    }
+    // The last round renders a PDF; count it before the LLM call, so a
+    // spent PDF quota costs no call
+    if req.Round+1 > maxRounds {
+      if err := sub.Consume(usage.PDFs, 1); err != nil {
+        sub.Refund(usage.LLMCalls)
+        usage.WriteProblem(w, r, err)
+        return
+      }
+    }
+    reply, err := ai.Chat(ctx, messages, llm.ChatOptions{})
+    if err != nil {
+      sub.Refund(usage.LLMCalls, usage.PDFs)
+      var be *llm.BudgetExceededError
+      if errors.As(err, &be) {
+        llm.WriteProblem(w, r, be)
         return
       }
+      http.Error(w, "LLM error: "+err.Error(), http.StatusInternalServerError)
+      return
+    }
+
+    input, _ := json.Marshal(req.Answers)
+    if next := req.Round + 1; next <= maxRounds {
+      question := reply.Message.Content
       resp := dynamicResponse{
         Questions: []Question{{ID: fmt.Sprintf("q%d", next), Label: question}},
         NextRound: next,
       }
+      rec.Record(ctx, audit.ActionSubmit, input, []byte(question), fmt.Sprintf("round=%d", req.Round))
+      sub.Done()
       json.NewEncoder(w).Encode(resp)
       return
     }
 
-    prompt := fmt.Sprintf(
-      "You have completed %d rounds. Compile a final, comprehensive report based solely on these answers:\n%s\n\nReturn only the report text.",
-      maxRounds, history,
-    )
-    report, err := ai.Prompt(r.Context(), prompt)
-    if err != nil {
-      http.Error(w, "LLM error: "+err.Error(), http.StatusInternalServerError)
-      return
-    }
+    report := reply.Message.Content
     pdfBytes, err := renderer.ToPDF(report)
     if err != nil {
+      sub.Refund(usage.PDFs)
       http.Error(w, "PDF error: "+err.Error(), http.StatusInternalServerError)
       return
     }
+    rec.Record(ctx, audit.ActionSubmit, input, pdfBytes, "format=pdf")
+    sub.Done()
     w.Header().Set("Content-Type", "application/pdf")
     w.Header().Set("Content-Disposition", "attachment; filename=\"report.pdf\"")
     w.Write(pdfBytes)
-  })
+  })))
+
+  // Same as /dynamic-submit, but streams the question or report as
+  // server-sent events: "delta" events carry {"text"} as it is generated,
+  // then "done" carries the dynamicResponse, or {"done": true, "report"}
+  // after the last round. Failures after the stream starts arrive as an
+  // "error" event {"error"}.
+  http.Handle("/dynamic-submit/stream", licMgr.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    var req dynamicRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+      http.Error(w, "invalid JSON", http.StatusBadRequest)
+      return
+    }
+    sub := tracker.Submission(license.DefaultGate)
+    ctx, messages, ok := nextTurn(w, r, sub, req, "md")
+    if !ok {
+      return
+    }
+    events, err := sse.NewWriter(w)
+    if err != nil {
+      sub.Refund(usage.LLMCalls)
+      http.Error(w, err.Error(), http.StatusInternalServerError)
+      return
+    }
+    reply, err := ai.ChatStream(ctx, messages, llm.ChatOptions{}, func(delta string) error {
+      return events.Send("delta", map[string]string{"text": delta})
+    })
+    if err != nil {
+      sub.Refund(usage.LLMCalls)
+      events.Send("error", map[string]string{"error": "LLM error: " + err.Error()})
+      return
+    }
+
+    input, _ := json.Marshal(req.Answers)
+    text := reply.Message.Content
+    if next := req.Round + 1; next <= maxRounds {
+      rec.Record(ctx, audit.ActionSubmit, input, []byte(text), fmt.Sprintf("round=%d stream=true", req.Round))
+      sub.Done()
+      events.Send("done", dynamicResponse{
+        Questions: []Question{{ID: fmt.Sprintf("q%d", next), Label: text}},
+        NextRound: next,
+      })
+      return
+    }
+    rec.Record(ctx, audit.ActionSubmit, input, []byte(text), "format=stream")
+    sub.Done()
+    events.Send("done", map[string]interface{}{"done": true, "report": text})
+  })))
+
+  // Usage & LLM spend, on their own unauthenticated admin listener
+  admin := http.NewServeMux()
+  admin.Handle("/admin/usage", tracker.Handler())
+  admin.Handle("/admin/llm-spend", ledger.Handler())
+  if cfg.AdminAddr != "" {
+    go func() {
+      log.Printf("admin endpoints on %s", cfg.AdminAddr)
+      log.Fatal(http.ListenAndServe(cfg.AdminAddr, admin))
+    }()
+  }
 
   addr := fmt.Sprintf(":%d", cfg.HTTPPort)
   log.Printf("listening on %s", addr)
-  log.Fatal(http.ListenAndServe(addr, nil))
+  log.Fatal(http.ListenAndServe(addr, audit.Middleware(llm.CacheMiddleware(license.DefaultGate.Middleware(licMgr, http.DefaultServeMux)))))
 }
@@ -2,27 +2,35 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"time"
 
+	"github.com/adi-ber/vjal-platform/pkg/audit"
 	"github.com/adi-ber/vjal-platform/pkg/config"
 	"github.com/adi-ber/vjal-platform/pkg/form"
 	"github.com/adi-ber/vjal-platform/pkg/license"
 	"github.com/adi-ber/vjal-platform/pkg/llm"
 	_ "github.com/adi-ber/vjal-platform/pkg/metrics"
 	"github.com/adi-ber/vjal-platform/pkg/output"
+	"github.com/adi-ber/vjal-platform/pkg/pii"
+	"github.com/adi-ber/vjal-platform/pkg/prompts"
+	"github.com/adi-ber/vjal-platform/pkg/sse"
 	"github.com/adi-ber/vjal-platform/pkg/storage"
+	"github.com/adi-ber/vjal-platform/pkg/usage"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// configPollInterval is how often config files are checked for changes.
+const configPollInterval = 5 * time.Second
+
 // processRequest is the JSON payload for our /process endpoint.
 type processRequest struct {
 	PromptKey string                 `json:"promptKey"`
@@ -31,52 +39,71 @@ type processRequest struct {
 }
 
 func main() {
-	// 1) Load configuration
-	cfg, err := config.Load("config.json")
+	// 1) Load configuration (file → profile → env → flags)
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfgMgr, err := config.NewManager(config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys}, configPollInterval)
 	if err != nil {
 		log.Fatalf("config load error: %v", err)
 	}
+	cfg := cfgMgr.Current()
+	log.Printf("config sources:\n%s", cfgMgr.Provenance())
+
+	// 1a) Verify shipped assets against the signed manifest
+	if err := license.CheckAssets(cfg); err != nil {
+		log.Fatalf("asset verification failed: %v", err)
+	}
 
-	// 1a) Ensure output directory exists
+	// 1b) Ensure output directory exists
 	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
 		log.Fatalf("failed to create output dir %q: %v", cfg.OutputDir, err)
 	}
 
 	// 2) Load all form definitions from definitions/
-	formDefs, err := form.LoadDefinitionsDir("definitions")
+	defsLoader, err := form.NewDefinitionsLoader("definitions")
 	if err != nil {
 		log.Fatalf("cannot load form definitions: %v", err)
 	}
 
-	// 3) Load LLM prompts
-	promptBytes, err := ioutil.ReadFile("llm_prompts.enc")
+	// 2a) Initialize storage (form state, usage counters and audit log)
+	store, err := storage.Open(cfg, license.SecretKeys)
 	if err != nil {
-		log.Fatalf("failed to read llm_prompts.enc: %v", err)
-	}
-	var promptTemplates map[string]string
-	if err := json.Unmarshal(promptBytes, &promptTemplates); err != nil {
-		log.Fatalf("invalid JSON in llm_prompts.enc: %v", err)
+		log.Fatalf("storage init error: %v", err)
 	}
+	rec := audit.New(store)
 
-	// 4) Validate license
-	validator := license.NewValidator(cfg)
-	lic, err := validator.Validate(context.Background())
-	if err != nil {
+	// 3) Validate license; it is revalidated every licenseCheckInterval
+	licMgr := license.NewManager(license.NewValidator(cfg), license.WithStatusHook(rec.LicenseEvent))
+	if _, err := licMgr.Refresh(context.Background()); !licMgr.Active() {
 		log.Fatalf("license validation failed: %v", err)
 	}
+	go licMgr.Run(context.Background())
 
-	// 5) Initialize storage (for form state, unused here but required)
-	if _, err := storage.New(filepath.Join(cfg.OutputDir, "state.db")); err != nil {
-		log.Fatalf("storage init error: %v", err)
+	// 4) Decrypt the LLM prompt bundle with the validated license
+	promptSet, err := prompts.Load(prompts.DefaultPath, licMgr.License())
+	if err != nil {
+		log.Fatalf("prompt bundle error: %v", err)
 	}
 
+	// 5) Count usage against the license limits
+	tracker := usage.NewTracker(store, licMgr)
+
 	// 6) Initialize LLM client & renderer
-	ai, err := llm.New(cfg, lic)
+	ledger := llm.NewLedger(store)
+	ai, err := llm.NewReloadable(cfg, func(c *config.AppConfig) (llm.Client, error) {
+		return llm.New(c, licMgr.License(), llm.WithAudit(rec), llm.WithCache(store), llm.WithLedger(ledger))
+	})
 	if err != nil {
 		log.Fatalf("LLM init error: %v", err)
 	}
 	renderer := output.NewRenderer()
 
+	// 6a) Rebuild dependants whenever the config file changes
+	cfgMgr.Subscribe("license", licMgr)
+	cfgMgr.Subscribe("llm", ai)
+	cfgMgr.Subscribe("definitions", defsLoader)
+	go cfgMgr.Run(context.Background())
+
 	// 7) Parse our prompt‑form template
 	promptFormTmpl := template.Must(template.ParseFiles(
 		"cmd/example/templates/prompt_form.html",
@@ -85,11 +112,19 @@ func main() {
 	// --- Serve the prompt‑driven form ---
 	http.HandleFunc("/prompt-form", func(w http.ResponseWriter, r *http.Request) {
 		key := r.URL.Query().Get("promptKey")
-		fields, ok := formDefs[key]
+		fields, ok := defsLoader.Definitions()[key]
 		if !ok {
 			http.NotFound(w, r)
 			return
 		}
+		if err := license.DefaultGate.PromptKey(licMgr, key); err != nil {
+			license.WriteProblem(w, r, err)
+			return
+		}
+		if err := tracker.Check(usage.Definitions, int64(len(defsLoader.Definitions()))); err != nil {
+			usage.WriteProblem(w, r, err)
+			return
+		}
 		fieldsJSON, _ := json.Marshal(fields)
 		data := struct {
 			PromptFieldsJSON template.JS
@@ -102,32 +137,78 @@ func main() {
 		}
 	})
 
+	// prepare checks a decoded request against the license, through sub, and
+	// the quotas and renders its prompt. If it fails it has already written
+	// the response.
+	prepare := func(w http.ResponseWriter, r *http.Request, sub *usage.Submission, req *processRequest) (context.Context, string, bool) {
+		// 1) Lookup prompt template
+		if _, ok := promptSet[req.PromptKey]; !ok {
+			http.Error(w, "unknown promptKey", http.StatusBadRequest)
+			return nil, "", false
+		}
+		if err := sub.PromptKey(licMgr, req.PromptKey); err != nil {
+			license.WriteProblem(w, r, err)
+			return nil, "", false
+		}
+		if err := tracker.Check(usage.Definitions, int64(len(defsLoader.Definitions()))); err != nil {
+			usage.WriteProblem(w, r, err)
+			return nil, "", false
+		}
+
+		// 2) Render the prompt by merging in user data
+		sensitive := form.SensitiveFields(defsLoader.Definitions()[req.PromptKey])
+		log.Printf("[process] promptKey=%s format=%s data=%v", req.PromptKey, req.Format, sensitive.Redact(req.Data))
+		prompt, err := promptSet.Render(req.PromptKey, req.Data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("prompt render error: %v", err), http.StatusInternalServerError)
+			return nil, "", false
+		}
+
+		// 3) Count the LLM call
+		if err := sub.Consume(usage.LLMCalls, 1); err != nil {
+			usage.WriteProblem(w, r, err)
+			return nil, "", false
+		}
+		ctx := audit.WithPromptKey(pii.WithValues(r.Context(), sensitive.Values(req.Data)), req.PromptKey)
+		return ctx, prompt, true
+	}
+
 	// --- Process form → prompt → LLM → HTML or PDF ---
-	http.HandleFunc("/process", func(w http.ResponseWriter, r *http.Request) {
+	http.Handle("/process", licMgr.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var req processRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid JSON", http.StatusBadRequest)
 			return
 		}
-
-		// 1) Lookup prompt template
-		tpl, ok := promptTemplates[req.PromptKey]
-		if !ok {
-			http.Error(w, "unknown promptKey", http.StatusBadRequest)
+		if req.Format != "html" {
+			req.Format = "pdf" // anything else renders as PDF
+		}
+		sub := tracker.Submission(license.DefaultGate)
+		if err := sub.Format(licMgr, req.Format); err != nil {
+			license.WriteProblem(w, r, err)
 			return
 		}
-
-		// 2) Render the prompt by merging in user data
-		var buf bytes.Buffer
-		t := template.Must(template.New("p").Parse(tpl))
-		if err := t.Execute(&buf, req.Data); err != nil {
-			http.Error(w, fmt.Sprintf("prompt render error: %v", err), http.StatusInternalServerError)
+		ctx, prompt, ok := prepare(w, r, sub, &req)
+		if !ok {
 			return
 		}
+		// Count the PDF before the LLM call, so a spent PDF quota costs no call
+		if req.Format == "pdf" {
+			if err := sub.Consume(usage.PDFs, 1); err != nil {
+				sub.Refund(usage.LLMCalls)
+				usage.WriteProblem(w, r, err)
+				return
+			}
+		}
 
-		// 3) Call the LLM
-		aiResp, err := ai.Prompt(context.Background(), buf.String())
+		aiResp, err := ai.Prompt(ctx, prompt)
 		if err != nil {
+			sub.Refund(usage.LLMCalls, usage.PDFs)
+			var be *llm.BudgetExceededError
+			if errors.As(err, &be) {
+				llm.WriteProblem(w, r, be)
+				return
+			}
 			http.Error(w, fmt.Sprintf("LLM error: %v", err), http.StatusInternalServerError)
 			return
 		}
@@ -140,20 +221,58 @@ func main() {
 				http.Error(w, fmt.Sprintf("HTML render error: %v", err), http.StatusInternalServerError)
 				return
 			}
+			rec.Record(ctx, audit.ActionSubmit, inputJSON(req.Data), []byte(out), "format=html")
+			sub.Done()
 			w.Header().Set("Content-Type", "text/html")
 			w.Write([]byte(out))
 
 		default: // pdf
 			pdf, err := renderer.ToPDF(aiResp)
 			if err != nil {
+				sub.Refund(usage.PDFs)
 				http.Error(w, fmt.Sprintf("PDF error: %v", err), http.StatusInternalServerError)
 				return
 			}
+			rec.Record(ctx, audit.ActionSubmit, inputJSON(req.Data), pdf, "format=pdf")
+			sub.Done()
 			w.Header().Set("Content-Type", "application/pdf")
 			w.Header().Set("Content-Disposition", "attachment; filename=\"result.pdf\"")
 			w.Write(pdf)
 		}
-	})
+	})))
+
+	// --- Process form → prompt → LLM, streamed as server-sent events ---
+	// "delta" events carry {"text"} as it is generated, then a final "done"
+	// event carries {"model", "provider", "usage"}, or an "error" event {"error"}.
+	http.Handle("/process/stream", licMgr.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req processRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		sub := tracker.Submission(license.DefaultGate)
+		ctx, prompt, ok := prepare(w, r, sub, &req)
+		if !ok {
+			return
+		}
+		events, err := sse.NewWriter(w)
+		if err != nil {
+			sub.Refund(usage.LLMCalls)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp, err := ai.ChatStream(ctx, llm.UserPrompt(prompt), llm.ChatOptions{}, func(delta string) error {
+			return events.Send("delta", map[string]string{"text": delta})
+		})
+		if err != nil {
+			sub.Refund(usage.LLMCalls)
+			events.Send("error", map[string]string{"error": fmt.Sprintf("LLM error: %v", err)})
+			return
+		}
+		rec.Record(ctx, audit.ActionSubmit, inputJSON(req.Data), []byte(resp.Message.Content), "format=stream")
+		sub.Done()
+		events.Send("done", map[string]interface{}{"model": resp.Model, "provider": resp.Provider, "usage": resp.Usage})
+	})))
 
 	// --- Metrics & health ---
 	http.Handle("/metrics", promhttp.Handler())
@@ -162,8 +281,26 @@ func main() {
 		fmt.Fprintln(w, "OK")
 	})
 
+	// --- Usage & LLM spend, on their own unauthenticated admin listener ---
+	admin := http.NewServeMux()
+	admin.Handle("/admin/usage", tracker.Handler())
+	admin.Handle("/admin/llm-spend", ledger.Handler())
+	if cfg.AdminAddr != "" {
+		go func() {
+			log.Printf("admin endpoints on %s", cfg.AdminAddr)
+			log.Fatal(http.ListenAndServe(cfg.AdminAddr, admin))
+		}()
+	}
+
 	// --- Start server ---
 	addr := fmt.Sprintf(":%d", cfg.HTTPPort)
 	log.Printf("starting example server on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	log.Fatal(http.ListenAndServe(addr, audit.Middleware(llm.CacheMiddleware(license.DefaultGate.Middleware(licMgr, http.DefaultServeMux)))))
+}
+
+// inputJSON is the form data as audited: its JSON encoding, whose hash
+// identifies the inputs of a submission.
+func inputJSON(data map[string]interface{}) []byte {
+	b, _ := json.Marshal(data)
+	return b
 }
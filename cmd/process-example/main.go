@@ -1,43 +1,38 @@
 package main
 
 import (
-  "bytes"
   "context"
   "encoding/json"
+  "errors"
+  "flag"
   "fmt"
   "html/template"
   "io/ioutil"
   "log"
   "net/http"
   "os"
-  "path/filepath"
+  "time"
 
+  "github.com/adi-ber/vjal-platform/pkg/audit"
   "github.com/adi-ber/vjal-platform/pkg/config"
   "github.com/adi-ber/vjal-platform/pkg/license"
   "github.com/adi-ber/vjal-platform/pkg/llm"
   _ "github.com/adi-ber/vjal-platform/pkg/metrics"
   "github.com/adi-ber/vjal-platform/pkg/output"
+  "github.com/adi-ber/vjal-platform/pkg/pii"
+  "github.com/adi-ber/vjal-platform/pkg/prompts"
+  "github.com/adi-ber/vjal-platform/pkg/sse"
   "github.com/adi-ber/vjal-platform/pkg/storage"
+  "github.com/adi-ber/vjal-platform/pkg/usage"
   "github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// promptTemplates holds your IP‑protected prompt strings.
-var promptTemplates = map[string]string{
-  "accountingClassifier": `You are a financial classifier.
-Description: {{.description}}
-Amount: {{.amount}}
-Answer:`,
-  "userSummary": `Form submission summary:
-Name: {{.name}}
-Age: {{.age}}
-Answer:`,
-}
-
 // PromptField describes one variable the prompt expects.
 type PromptField struct {
-  ID    string `json:"id"`
-  Label string `json:"label"`
-  Type  string `json:"type"`
+  ID          string    `json:"id"`
+  Label       string    `json:"label"`
+  Type        string    `json:"type"`
+  Sensitivity pii.Class `json:"sensitivity,omitempty"`
 }
 
 // promptFields defines, for each promptKey, the fields to render.
@@ -47,11 +42,25 @@ var promptFields = map[string][]PromptField{
     {ID: "amount",       Label: "Amount",      Type: "number"},
   },
   "userSummary": {
-    {ID: "name", Label: "Name", Type: "text"},
+    {ID: "name", Label: "Name", Type: "text", Sensitivity: pii.PII},
     {ID: "age",  Label: "Age",  Type: "number"},
   },
 }
 
+// sensitiveFields returns the fields of promptKey marked pii or secret.
+func sensitiveFields(promptKey string) pii.Fields {
+  out := make(pii.Fields)
+  for _, f := range promptFields[promptKey] {
+    if f.Sensitivity != pii.None {
+      out[f.ID] = f.Sensitivity
+    }
+  }
+  return out
+}
+
+// configPollInterval is how often config files are checked for changes.
+const configPollInterval = 5 * time.Second
+
 // processRequest defines the JSON payload for /process.
 type processRequest struct {
   PromptKey string                 `json:"promptKey"`
@@ -70,36 +79,64 @@ var promptFormTmpl = template.Must(template.ParseFiles(
 ))
 
 func main() {
-  // 1) Load configuration
-  cfg, err := config.Load("config.json")
+  // 1) Load configuration (file → profile → env → flags)
+  flags := config.RegisterFlags(flag.CommandLine)
+  flag.Parse()
+  cfgMgr, err := config.NewManager(config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys}, configPollInterval)
   if err != nil {
     log.Fatalf("config load error: %v", err)
   }
+  cfg := cfgMgr.Current()
+  log.Printf("config sources:\n%s", cfgMgr.Provenance())
+
+  // 1a) Verify shipped assets against the signed manifest
+  if err := license.CheckAssets(cfg); err != nil {
+    log.Fatalf("asset verification failed: %v", err)
+  }
 
   // 2) Ensure output directory exists
   if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
     log.Fatalf("failed to create output dir: %v", err)
   }
 
-  // 3) Validate license
-  validator := license.NewValidator(cfg)
-  lic, err := validator.Validate(context.Background())
+  // 2a) Initialize storage (usage counters and audit log)
+  store, err := storage.Open(cfg, license.SecretKeys)
   if err != nil {
+    log.Fatalf("storage init error: %v", err)
+  }
+  rec := audit.New(store)
+
+  // 3) Validate license; it is revalidated every licenseCheckInterval
+  licMgr := license.NewManager(license.NewValidator(cfg), license.WithStatusHook(rec.LicenseEvent))
+  if _, err := licMgr.Refresh(context.Background()); !licMgr.Active() {
     log.Fatalf("license validation failed: %v", err)
   }
+  go licMgr.Run(context.Background())
 
-  // 4) Initialize storage (unused here but required)
-  if _, err := storage.New(filepath.Join(cfg.OutputDir, "state.db")); err != nil {
-    log.Fatalf("storage init error: %v", err)
+  // 3a) Decrypt the LLM prompt bundle with the validated license
+  promptSet, err := prompts.Load(prompts.DefaultPath, licMgr.License())
+  if err != nil {
+    log.Fatalf("prompt bundle error: %v", err)
   }
 
+  // 4) Count usage against the license limits
+  tracker := usage.NewTracker(store, licMgr)
+
   // 5) Initialize LLM and renderer
-  ai, err := llm.New(cfg, lic)
+  ledger := llm.NewLedger(store)
+  ai, err := llm.NewReloadable(cfg, func(c *config.AppConfig) (llm.Client, error) {
+    return llm.New(c, licMgr.License(), llm.WithAudit(rec), llm.WithCache(store), llm.WithLedger(ledger))
+  })
   if err != nil {
     log.Fatalf("LLM init error: %v", err)
   }
   renderer := output.NewRenderer()
 
+  // 5a) Rebuild dependants whenever the config file changes
+  cfgMgr.Subscribe("license", licMgr)
+  cfgMgr.Subscribe("llm", ai)
+  go cfgMgr.Run(context.Background())
+
   // 6) Serve the generic schema‑driven form
   http.HandleFunc("/form-demo", func(w http.ResponseWriter, r *http.Request) {
     schemaBytes, err := ioutil.ReadFile("forms/schema_v1.json")
@@ -139,39 +176,86 @@ func main() {
   })
 
   // 7) Process endpoint with detailed logging
-  http.HandleFunc("/process", func(w http.ResponseWriter, r *http.Request) {
-    var req processRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-      log.Printf("[process] JSON decode error: %v", err)
-      http.Error(w, err.Error(), http.StatusBadRequest)
-      return
-    }
+  //
+  // prepare checks a decoded request against the license, through sub, and
+  // the quotas and renders its prompt. If it fails it has already written
+  // the response.
+  prepare := func(w http.ResponseWriter, r *http.Request, sub *usage.Submission, req *processRequest) (context.Context, string, bool) {
+    sensitive := sensitiveFields(req.PromptKey)
+    log.Printf("[process] promptKey=%s format=%s data=%v", req.PromptKey, req.Format, sensitive.Redact(req.Data))
 
-    tmplStr, ok := promptTemplates[req.PromptKey]
-    if !ok {
+    if _, ok := promptSet[req.PromptKey]; !ok {
       err := fmt.Errorf("unknown promptKey %q", req.PromptKey)
       log.Printf("[process] %v", err)
       http.Error(w, err.Error(), http.StatusBadRequest)
-      return
+      return nil, "", false
+    }
+    if err := sub.PromptKey(licMgr, req.PromptKey); err != nil {
+      license.WriteProblem(w, r, err)
+      return nil, "", false
+    }
+    if req.Format != "md" && req.Format != "html" {
+      req.Format = "pdf" // anything else renders as PDF
+    }
+    if err := sub.Format(licMgr, req.Format); err != nil {
+      license.WriteProblem(w, r, err)
+      return nil, "", false
     }
 
-    var buf bytes.Buffer
-    tmpl := template.Must(template.New("p").Parse(tmplStr))
-    if err := tmpl.Execute(&buf, req.Data); err != nil {
+    prompt, err := promptSet.Render(req.PromptKey, req.Data)
+    if err != nil {
       log.Printf("[process] template exec error: %v", err)
       http.Error(w, err.Error(), http.StatusInternalServerError)
+      return nil, "", false
+    }
+
+    if err := sub.Consume(usage.LLMCalls, 1); err != nil {
+      usage.WriteProblem(w, r, err)
+      return nil, "", false
+    }
+    ctx := audit.WithPromptKey(pii.WithValues(r.Context(), sensitive.Values(req.Data)), req.PromptKey)
+    return ctx, prompt, true
+  }
+
+  http.Handle("/process", licMgr.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    var req processRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+      log.Printf("[process] JSON decode error: %v", err)
+      http.Error(w, err.Error(), http.StatusBadRequest)
       return
     }
+    sub := tracker.Submission(license.DefaultGate)
+    ctx, prompt, ok := prepare(w, r, sub, &req)
+    if !ok {
+      return
+    }
+    // Count the PDF before the LLM call, so a spent PDF quota costs no call
+    if req.Format == "pdf" {
+      if err := sub.Consume(usage.PDFs, 1); err != nil {
+        sub.Refund(usage.LLMCalls)
+        usage.WriteProblem(w, r, err)
+        return
+      }
+    }
 
-    aiResp, err := ai.Prompt(context.Background(), buf.String())
+    input, _ := json.Marshal(req.Data)
+    aiResp, err := ai.Prompt(ctx, prompt)
     if err != nil {
+      sub.Refund(usage.LLMCalls, usage.PDFs)
       log.Printf("[process] LLM error: %v", err)
+      var be *llm.BudgetExceededError
+      if errors.As(err, &be) {
+        llm.WriteProblem(w, r, be)
+        return
+      }
       http.Error(w, err.Error(), http.StatusInternalServerError)
       return
     }
 
     switch req.Format {
     case "md":
+      rec.Record(ctx, audit.ActionSubmit, input, []byte(aiResp), "format=md")
+      sub.Done()
       w.Header().Set("Content-Type", "text/markdown")
       w.Write([]byte(aiResp))
 
@@ -182,21 +266,64 @@ func main() {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
       }
+      rec.Record(ctx, audit.ActionSubmit, input, []byte(htmlOut), "format=html")
+      sub.Done()
       w.Header().Set("Content-Type", "text/html")
       w.Write([]byte(htmlOut))
 
     default: // PDF
       pdfBytes, err := renderer.ToPDF(aiResp)
       if err != nil {
+        sub.Refund(usage.PDFs)
         log.Printf("[process] PDF render error: %v", err)
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
       }
+      rec.Record(ctx, audit.ActionSubmit, input, pdfBytes, "format=pdf")
+      sub.Done()
       w.Header().Set("Content-Type", "application/pdf")
       w.Header().Set("Content-Disposition", "attachment; filename=\"result.pdf\"")
       w.Write(pdfBytes)
     }
-  })
+  })))
+
+  // 7a) Same as /process, but streams the reply as server-sent events:
+  // "delta" events carry {"text"} as it is generated, then a final "done"
+  // event carries {"model", "provider", "usage"}, or an "error" event {"error"}.
+  http.Handle("/process/stream", licMgr.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    var req processRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+      log.Printf("[process/stream] JSON decode error: %v", err)
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    req.Format = "md" // streamed text is the Markdown reply
+    sub := tracker.Submission(license.DefaultGate)
+    ctx, prompt, ok := prepare(w, r, sub, &req)
+    if !ok {
+      return
+    }
+    events, err := sse.NewWriter(w)
+    if err != nil {
+      sub.Refund(usage.LLMCalls)
+      http.Error(w, err.Error(), http.StatusInternalServerError)
+      return
+    }
+
+    input, _ := json.Marshal(req.Data)
+    resp, err := ai.ChatStream(ctx, llm.UserPrompt(prompt), llm.ChatOptions{}, func(delta string) error {
+      return events.Send("delta", map[string]string{"text": delta})
+    })
+    if err != nil {
+      sub.Refund(usage.LLMCalls)
+      log.Printf("[process/stream] LLM error: %v", err)
+      events.Send("error", map[string]string{"error": err.Error()})
+      return
+    }
+    rec.Record(ctx, audit.ActionSubmit, input, []byte(resp.Message.Content), "format=stream")
+    sub.Done()
+    events.Send("done", map[string]interface{}{"model": resp.Model, "provider": resp.Provider, "usage": resp.Usage})
+  })))
 
   // 8) Metrics & health endpoints
   http.Handle("/metrics", promhttp.Handler())
@@ -205,8 +332,19 @@ func main() {
     fmt.Fprintln(w, "OK")
   })
 
+  // 8a) Usage & LLM spend, on their own unauthenticated admin listener
+  admin := http.NewServeMux()
+  admin.Handle("/admin/usage", tracker.Handler())
+  admin.Handle("/admin/llm-spend", ledger.Handler())
+  if cfg.AdminAddr != "" {
+    go func() {
+      log.Printf("admin endpoints on %s", cfg.AdminAddr)
+      log.Fatal(http.ListenAndServe(cfg.AdminAddr, admin))
+    }()
+  }
+
   // 9) Start the HTTP server
   addr := fmt.Sprintf(":%d", cfg.HTTPPort)
   log.Printf("listening on %s", addr)
-  log.Fatal(http.ListenAndServe(addr, nil))
+  log.Fatal(http.ListenAndServe(addr, audit.Middleware(llm.CacheMiddleware(license.DefaultGate.Middleware(licMgr, http.DefaultServeMux)))))
 }
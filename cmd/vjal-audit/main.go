@@ -0,0 +1,104 @@
+// cmd/vjal-audit/main.go
+//
+// vjal-audit checks and prints the tamper-evident audit log in
+// <outputDir>/state.db.
+//
+//	vjal-audit verify [-config config.json]
+//	vjal-audit list [-config config.json] [-from 1] [-n 50]
+//
+// verify prints the hash of the newest entry. Keep it somewhere the server
+// cannot write, so a later verify can show the log was not rewritten.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usageText()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "verify":
+		err = verify(args)
+	case "list":
+		err = list(args)
+	default:
+		usageText()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vjal-audit: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usageText() {
+	fmt.Fprintln(os.Stderr, "usage: vjal-audit <verify|list> [flags]")
+}
+
+// openStore opens the state database under the configured outputDir.
+func openStore(flags *config.Flags) (*storage.Store, error) {
+	cfg, _, err := config.LoadLayered(config.Options{Path: flags.ConfigPath, Flags: flags})
+	if err != nil {
+		return nil, err
+	}
+	dbPath := filepath.Join(cfg.OutputDir, "state.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no audit log: %w", err)
+	}
+	return storage.New(dbPath)
+}
+
+// verify checks the hash chain.
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	fs.Parse(args)
+
+	store, err := openStore(flags)
+	if err != nil {
+		return err
+	}
+	head, err := store.VerifyAudit()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("audit log OK, head %s\n", head)
+	return nil
+}
+
+// list prints entries as JSON lines.
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	from := fs.Int64("from", 1, "first entry to print")
+	n := fs.Int("n", 0, "print at most this many entries (0 means all)")
+	fs.Parse(args)
+
+	store, err := openStore(flags)
+	if err != nil {
+		return err
+	}
+	entries, err := store.AuditEntries(*from, *n)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
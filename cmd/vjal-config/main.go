@@ -0,0 +1,120 @@
+// cmd/vjal-config/main.go
+//
+// vjal-config inspects config files offline, before they are shipped.
+//
+//	vjal-config validate [-config config.json] [-env production] [overrides...]
+//	vjal-config show     [-config config.json] [-env production] [overrides...]
+//	vjal-config encrypt-secret [-config config.json] < secret.txt
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	_ "github.com/adi-ber/vjal-platform/pkg/llm" // registers LLM providers for validation
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, args := os.Args[1], os.Args[2:]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	fs.Parse(args)
+	opts := config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys}
+
+	switch cmd {
+	case "validate":
+		os.Exit(validate(opts))
+	case "show":
+		os.Exit(show(opts))
+	case "encrypt-secret":
+		os.Exit(encryptSecret(opts))
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: vjal-config <validate|show|encrypt-secret> [-config path] [config flags]")
+}
+
+// validate prints every problem with the config and exits non-zero on errors.
+func validate(opts config.Options) int {
+	report := config.Check(opts)
+	for _, p := range report.Errors {
+		fmt.Printf("ERROR    %s\n", p)
+	}
+	for _, p := range report.Warnings {
+		fmt.Printf("WARNING  %s\n", p)
+	}
+	fmt.Printf("%s: %d error(s), %d warning(s)\n", opts.Path, len(report.Errors), len(report.Warnings))
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+// show prints the effective config, with secrets redacted, and where each
+// value came from.
+func show(opts config.Options) int {
+	cfg, prov, err := config.LoadLayered(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config load error: %v\n", err)
+		return 1
+	}
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshal config: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(out))
+	fmt.Println()
+	fmt.Print(prov)
+	return 0
+}
+
+// encryptSecret reads one secret from stdin and prints it as an enc:
+// reference bound to the license named by the config.
+func encryptSecret(opts config.Options) int {
+	cfg, _, err := config.LoadLayered(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config load error: %v\n", err)
+		return 1
+	}
+	licenseKey, deviceID, err := license.SecretKeys(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "license error: %v\n", err)
+		return 1
+	}
+
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "read secret: %v\n", err)
+		return 1
+	}
+	secret = strings.TrimRight(secret, "\r\n")
+	if secret == "" {
+		fmt.Fprintln(os.Stderr, "no secret on stdin")
+		return 1
+	}
+
+	ref, err := config.EncryptSecret(secret, licenseKey, deviceID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "encrypt: %v\n", err)
+		return 1
+	}
+	fmt.Println(ref)
+	return 0
+}
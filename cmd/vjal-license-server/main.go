@@ -0,0 +1,101 @@
+// cmd/vjal-license-server/main.go
+//
+// vjal-license-server is a reference license server for lease activation.
+// It knows the licenses in -licenses, issues leases signed with -signing-key
+// and publishes the keys listed in -revoked as a signed revocation list.
+//
+//	vjal-license-server -signing-key keys/vjal-dev-1.key -licenses licenses/ [-revoked revoked.txt] [-ttl 72h] [-addr :9400]
+//
+// Both directories are re-read on every request, so issuing or revoking a
+// license takes effect without a restart.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+)
+
+func main() {
+	addr := flag.String("addr", ":9400", "listen address")
+	keyPath := flag.String("signing-key", "", "issuer private key that signs leases (required)")
+	kid := flag.String("kid", "", "issuer key ID (default: signing key file name without .key)")
+	licenses := flag.String("licenses", "licenses", "directory of license files this server activates")
+	revoked := flag.String("revoked", "revoked.txt", "file of revoked license keys, one per line")
+	ttl := flag.Duration("ttl", license.DefaultLeaseTTL, "lease lifetime")
+	flag.Parse()
+
+	if *keyPath == "" {
+		log.Fatal("-signing-key is required")
+	}
+	keyData, err := os.ReadFile(*keyPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	priv, err := license.ParsePrivateKey(string(keyData))
+	if err != nil {
+		log.Fatalf("%s: %v", *keyPath, err)
+	}
+	if *kid == "" {
+		*kid = strings.TrimSuffix(filepath.Base(*keyPath), ".key")
+	}
+
+	srv := &license.LeaseServer{
+		KeyID:   *kid,
+		Key:     priv,
+		TTL:     *ttl,
+		Lookup:  lookup(*licenses),
+		Revoked: func() ([]string, error) { return readRevoked(*revoked) },
+	}
+	log.Printf("license server on %s (licenses in %s, revocations in %s)", *addr, *licenses, *revoked)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
+
+// lookup finds a correctly signed license with the given key in dir.
+func lookup(dir string) func(string) (*license.License, bool) {
+	keys := license.DefaultKeyring()
+	return func(key string) (*license.License, bool) {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		for _, p := range paths {
+			lic, err := license.ReadFile(p)
+			if err != nil || lic.Key != key {
+				continue
+			}
+			if err := lic.Verify(keys, ""); err != nil {
+				log.Printf("⚠️  skipping %s: %v", p, err)
+				continue
+			}
+			return lic, true
+		}
+		return nil, false
+	}
+}
+
+// readRevoked reads one license key per line, ignoring blanks and # comments.
+// A missing file revokes nothing.
+func readRevoked(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var keys []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			keys = append(keys, line)
+		}
+	}
+	return keys, sc.Err()
+}
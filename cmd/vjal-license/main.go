@@ -0,0 +1,333 @@
+// cmd/vjal-license/main.go
+//
+// vjal-license creates issuer keys and mints, inspects and verifies license files.
+//
+//	vjal-license keygen  -kid vjal-prod-2 [-out keys]
+//	vjal-license issue   -signing-key keys/vjal-prod-2.key -expires 30d -features offline_llm,cv_analysis [-device ID] [-llm-calls N] [-pdfs N] [-max-definitions N] [-out license.json]
+//	vjal-license inspect [-license license.json] [-pub keys/vjal-prod-2.pub]
+//	vjal-license verify  [-license license.json] [-pub keys/vjal-prod-2.pub] [-env production]
+//	vjal-license fingerprint [-sources machine-id,hostname,mac]
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "keygen":
+		err = keygen(args)
+	case "issue":
+		err = issue(args)
+	case "inspect":
+		err = inspect(args)
+	case "verify":
+		err = verify(args)
+	case "fingerprint":
+		err = fingerprint(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vjal-license: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: vjal-license <keygen|issue|inspect|verify|fingerprint> [flags]")
+}
+
+// keygen writes <kid>.key (private, mode 0600) and <kid>.pub into -out.
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	kid := fs.String("kid", "", "issuer key ID, e.g. vjal-prod-2 (required)")
+	out := fs.String("out", "keys", "directory to write the key files to")
+	fs.Parse(args)
+	if *kid == "" {
+		return errors.New("-kid is required")
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	privPath := filepath.Join(*out, *kid+".key")
+	pubPath := filepath.Join(*out, *kid+".pub")
+	if _, err := os.Stat(privPath); err == nil {
+		return fmt.Errorf("%s already exists; refusing to overwrite", privPath)
+	}
+	if err := os.WriteFile(privPath, []byte(base64.StdEncoding.EncodeToString(priv)+"\n"), 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(pubPath, []byte(base64.StdEncoding.EncodeToString(pub)+"\n"), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s and %s\n", privPath, pubPath)
+	fmt.Printf("embed with: -ldflags \"-X github.com/adi-ber/vjal-platform/pkg/license.extraIssuerKeys=%s=%s\"\n",
+		*kid, base64.StdEncoding.EncodeToString(pub))
+	return nil
+}
+
+// issue signs a new license with an issuer private key.
+func issue(args []string) error {
+	fs := flag.NewFlagSet("issue", flag.ExitOnError)
+	keyPath := fs.String("signing-key", "", "issuer private key file written by keygen (required)")
+	kid := fs.String("kid", "", "issuer key ID (default: signing key file name without .key)")
+	licKey := fs.String("license-key", "", "license key (default: a random VJAL-XXXX-... key)")
+	expires := fs.String("expires", "", "expiry as RFC3339, YYYY-MM-DD or a duration like 14d or 720h (required)")
+	features := fs.String("features", "", "comma-separated feature list")
+	device := fs.String("device", "", "bind the license to this device ID")
+	llmCalls := fs.Int64("llm-calls", 0, "LLM calls allowed per month (0 = unlimited)")
+	pdfs := fs.Int64("pdfs", 0, "PDFs allowed per month (0 = unlimited)")
+	maxDefs := fs.Int64("max-definitions", 0, "form definitions allowed (0 = unlimited)")
+	out := fs.String("out", "", "write the license here instead of stdout")
+	fs.Parse(args)
+
+	if *keyPath == "" || *expires == "" {
+		return errors.New("-signing-key and -expires are required")
+	}
+	keyData, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return err
+	}
+	priv, err := license.ParsePrivateKey(string(keyData))
+	if err != nil {
+		return fmt.Errorf("%s: %w", *keyPath, err)
+	}
+	if *kid == "" {
+		*kid = strings.TrimSuffix(filepath.Base(*keyPath), ".key")
+	}
+	exp, err := parseExpiry(*expires, time.Now())
+	if err != nil {
+		return err
+	}
+	if *licKey == "" {
+		if *licKey, err = randomLicenseKey(); err != nil {
+			return err
+		}
+	}
+
+	lic := license.License{
+		Key:      *licKey,
+		Expires:  exp,
+		Features: splitList(*features),
+		DeviceID: *device,
+	}
+	if limits := (license.Limits{LLMCallsPerMonth: *llmCalls, PDFsPerMonth: *pdfs, MaxDefinitions: *maxDefs}); limits != (license.Limits{}) {
+		lic.Limits = &limits
+	}
+	if err := lic.Sign(*kid, priv); err != nil {
+		return fmt.Errorf("sign license: %w", err)
+	}
+	data, err := json.MarshalIndent(lic, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "issued %s to %s (%s)\n", lic.Key, *out, remaining(lic.Expires, time.Now()))
+	return nil
+}
+
+// inspect prints a license and whether its signature verifies.
+func inspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	path := fs.String("license", "license.json", "license file")
+	pub := fs.String("pub", "", "verify against this public key file instead of the embedded keys")
+	env := fs.String("env", "", "env to verify for; dev-only keys are refused in production")
+	fs.Parse(args)
+
+	lic, err := license.ReadFile(*path)
+	if err != nil {
+		return err
+	}
+	keys, err := keyring(*pub, lic.KeyID)
+	if err != nil {
+		return err
+	}
+
+	device := lic.DeviceID
+	if device == "" {
+		device = "(any)"
+	}
+	features := strings.Join(lic.Features, ", ")
+	if features == "" {
+		features = "(none)"
+	}
+	limits := "(unlimited)"
+	if l := lic.Limits; l != nil {
+		limits = fmt.Sprintf("%s LLM calls/month, %s PDFs/month, %s definitions",
+			limitString(l.LLMCallsPerMonth), limitString(l.PDFsPerMonth), limitString(l.MaxDefinitions))
+	}
+	status := "valid"
+	if err := lic.Verify(keys, *env); err != nil {
+		status = err.Error()
+	}
+	fmt.Printf("License key: %s\n", lic.Key)
+	fmt.Printf("Expires:     %s (%s)\n", lic.Expires.UTC().Format(time.RFC3339), remaining(lic.Expires, time.Now()))
+	fmt.Printf("Features:    %s\n", features)
+	fmt.Printf("Device:      %s\n", device)
+	fmt.Printf("Limits:      %s\n", limits)
+	fmt.Printf("Issuer key:  %s\n", lic.KeyID)
+	fmt.Printf("Signature:   %s\n", status)
+	return nil
+}
+
+// verify exits non-zero unless the license is correctly signed and unexpired.
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	path := fs.String("license", "license.json", "license file")
+	pub := fs.String("pub", "", "verify against this public key file instead of the embedded keys")
+	env := fs.String("env", "", "env to verify for; dev-only keys are refused in production")
+	fs.Parse(args)
+
+	lic, err := license.ReadFile(*path)
+	if err != nil {
+		return err
+	}
+	keys, err := keyring(*pub, lic.KeyID)
+	if err != nil {
+		return err
+	}
+	if err := lic.Verify(keys, *env); err != nil {
+		return err
+	}
+	if time.Now().After(lic.Expires) {
+		return fmt.Errorf("license %s %s", lic.Key, remaining(lic.Expires, time.Now()))
+	}
+	fmt.Printf("%s: OK, %s\n", *path, remaining(lic.Expires, time.Now()))
+	return nil
+}
+
+// fingerprint prints this device's ID, for use with issue -device.
+func fingerprint(args []string) error {
+	fs := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	sources := fs.String("sources", strings.Join(license.DefaultSources, ","), "comma-separated fingerprint sources; must match the app's deviceSources")
+	fs.Parse(args)
+
+	id, err := license.SystemFingerprint{Sources: splitList(*sources)}.Fingerprint()
+	if err != nil {
+		return err
+	}
+	fmt.Println(id)
+	return nil
+}
+
+// keyring returns the embedded issuer keys, or only the key in pubPath
+// (registered under kid) when one is given.
+func keyring(pubPath, kid string) (license.Keyring, error) {
+	if pubPath == "" {
+		return license.DefaultKeyring(), nil
+	}
+	data, err := os.ReadFile(pubPath)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := license.ParsePublicKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pubPath, err)
+	}
+	return license.Keyring{kid: {Public: pub}}, nil
+}
+
+// parseExpiry accepts RFC3339, a plain date (end of that day, UTC), a number
+// of days ("30d") or a Go duration ("720h") relative to now.
+func parseExpiry(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Add(24*time.Hour - time.Second).UTC(), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n).UTC().Truncate(time.Second), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(d).UTC().Truncate(time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -expires %q", s)
+}
+
+// remaining describes how long until (or since) expiry.
+func remaining(expires, now time.Time) string {
+	d := expires.Sub(now)
+	if d < 0 {
+		return fmt.Sprintf("expired %s ago", humanDuration(-d))
+	}
+	return fmt.Sprintf("valid for %s", humanDuration(d))
+}
+
+func humanDuration(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(math.Floor(d.Hours()/24)))
+	}
+	return d.Truncate(time.Minute).String()
+}
+
+func limitString(n int64) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func randomLicenseKey() (string, error) {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString("VJAL")
+	for i, c := range b {
+		if i%4 == 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(alphabet[int(c)%len(alphabet)])
+	}
+	return sb.String(), nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+// cmd/vjal-manifest/main.go
+//
+// vjal-manifest signs the list of shipped assets at build time and checks
+// an installed tree against it.
+//
+//	vjal-manifest generate -signing-key keys/vjal-prod-2.key [-root .] [-out manifest.json] [paths...]
+//	vjal-manifest verify [-manifest manifest.json] [-pub keys/vjal-prod-2.pub] [-env production]
+//
+// Without paths, generate covers defaultAssets. Paths are relative to -root,
+// and the manifest is normally written there too.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+)
+
+// defaultAssets are the definitions, prompt bundles, templates and fonts the
+// servers load at run time.
+var defaultAssets = []string{
+	"definitions",
+	"forms",
+	"llm_prompts.enc",
+	"templates",
+	"cmd/example/templates",
+	"cmd/process-example/templates",
+	"cmd/dynamic-example/templates",
+	"assets/fonts",
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "generate":
+		err = generate(args)
+	case "verify":
+		err = verify(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vjal-manifest: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: vjal-manifest <generate|verify> [flags]")
+}
+
+// generate hashes the assets that exist under -root and signs the result.
+func generate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	keyPath := fs.String("signing-key", "", "issuer private key (required)")
+	kid := fs.String("kid", "", "issuer key ID (default: signing key file name without .key)")
+	root := fs.String("root", ".", "directory the asset paths are relative to")
+	out := fs.String("out", "", "manifest to write (default: <root>/manifest.json)")
+	fs.Parse(args)
+	if *keyPath == "" {
+		return errors.New("-signing-key is required")
+	}
+	if *kid == "" {
+		*kid = strings.TrimSuffix(filepath.Base(*keyPath), ".key")
+	}
+	if *out == "" {
+		*out = filepath.Join(*root, license.DefaultManifestPath)
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		// Not every build ships every example, so skip absent defaults.
+		for _, p := range defaultAssets {
+			if _, err := os.Stat(filepath.Join(*root, p)); err == nil {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	keyData, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return err
+	}
+	priv, err := license.ParsePrivateKey(string(keyData))
+	if err != nil {
+		return fmt.Errorf("%s: %w", *keyPath, err)
+	}
+	m, err := license.BuildManifest(*root, paths)
+	if err != nil {
+		return err
+	}
+	if err := m.Sign(*kid, priv); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "signed %d assets with %s into %s\n", len(m.Files), *kid, *out)
+	return nil
+}
+
+// verify checks the manifest signature and every asset it lists.
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	path := fs.String("manifest", license.DefaultManifestPath, "manifest to check")
+	pub := fs.String("pub", "", "verify against this public key file instead of the embedded keys")
+	env := fs.String("env", "", "refuse development keys when set to production")
+	fs.Parse(args)
+
+	keys := license.DefaultKeyring()
+	if *pub != "" {
+		m, err := license.ReadManifest(*path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(*pub)
+		if err != nil {
+			return err
+		}
+		key, err := license.ParsePublicKey(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", *pub, err)
+		}
+		keys = license.Keyring{m.KeyID: {Public: key}}
+	}
+	if err := license.VerifyAssets(*path, keys, *env); err != nil {
+		return err
+	}
+	fmt.Printf("%s: OK\n", *path)
+	return nil
+}
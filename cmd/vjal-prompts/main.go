@@ -0,0 +1,115 @@
+// cmd/vjal-prompts/main.go
+//
+// vjal-prompts packs LLM prompt templates into an encrypted bundle bound to
+// one license, and unpacks a bundle for editing.
+//
+//	vjal-prompts pack -in prompts.json -license customer-license.json [-out llm_prompts.enc]
+//	vjal-prompts unpack -license license.json [-in llm_prompts.enc] [-out prompts.json]
+//
+// prompts.json is a JSON object of prompt key to template. The bundle is
+// keyed by the license key and the device ID in the license, so it only
+// opens for that license on that device.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/prompts"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usageText()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "pack":
+		err = pack(args)
+	case "unpack":
+		err = unpack(args)
+	default:
+		usageText()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vjal-prompts: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usageText() {
+	fmt.Fprintln(os.Stderr, "usage: vjal-prompts <pack|unpack> [flags]")
+}
+
+// pack encrypts a plaintext prompt file for a license.
+func pack(args []string) error {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	in := fs.String("in", "", "plaintext prompt JSON (required)")
+	licPath := fs.String("license", "", "license the bundle is for (required)")
+	out := fs.String("out", prompts.DefaultPath, "bundle to write")
+	fs.Parse(args)
+	if *in == "" || *licPath == "" {
+		return errors.New("-in and -license are required")
+	}
+
+	lic, err := license.ReadFile(*licPath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	var p prompts.Prompts
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("invalid JSON in %s: %w", *in, err)
+	}
+	bundle, err := prompts.Pack(p, lic.Key, []byte(lic.DeviceID))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, bundle, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "packed %d prompts for %s into %s\n", len(p), lic.Key, *out)
+	return nil
+}
+
+// unpack decrypts a bundle and prints its prompts as JSON.
+func unpack(args []string) error {
+	fs := flag.NewFlagSet("unpack", flag.ExitOnError)
+	in := fs.String("in", prompts.DefaultPath, "bundle to read")
+	licPath := fs.String("license", "", "license the bundle was packed for (required)")
+	out := fs.String("out", "", "write the prompts here instead of stdout")
+	fs.Parse(args)
+	if *licPath == "" {
+		return errors.New("-license is required")
+	}
+
+	lic, err := license.ReadFile(*licPath)
+	if err != nil {
+		return err
+	}
+	p, err := prompts.Load(*in, lic)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0o600)
+}
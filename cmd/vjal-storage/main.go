@@ -0,0 +1,293 @@
+// cmd/vjal-storage/main.go
+//
+// vjal-storage manages encryption at rest of <outputDir>/state.db.
+//
+//	vjal-storage rotate [-config config.json]
+//	vjal-storage rewrap [-config config.json] -new-license license.json
+//	vjal-storage purge-cache [-config config.json] [-prompt-key key | -expired]
+//	vjal-storage backup [-config config.json] -out state.db.vjss
+//	vjal-storage restore [-config config.json] -in state.db.vjss [-force]
+//
+// rotate makes a new data key active and re-encrypts every form answer with
+// it, including plaintext rows written before encryptState was turned on.
+// rewrap keeps the data keys but wraps them for a renewed license, so the
+// servers can open the database once the new license is installed.
+// purge-cache deletes cached LLM responses: those of one prompt key, the
+// expired ones, or all of them.
+// backup writes a copy of the database encrypted with security.EncryptStream
+// under the license key and device ID; restore decrypts one back in place of
+// state.db while the servers are stopped.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/security"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usageText()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "rotate":
+		err = rotate(args)
+	case "rewrap":
+		err = rewrap(args)
+	case "purge-cache":
+		err = purgeCache(args)
+	case "backup":
+		err = backup(args)
+	case "restore":
+		err = restore(args)
+	default:
+		usageText()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vjal-storage: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usageText() {
+	fmt.Fprintln(os.Stderr, "usage: vjal-storage <rotate|rewrap|purge-cache|backup|restore> [flags]")
+}
+
+// openStore opens the state database with data keys from the configured
+// license. As with storage.Open, encryptState decides whether whole rows or
+// only sensitive fields are sealed.
+func openStore(flags *config.Flags) (*storage.Store, *config.AppConfig, error) {
+	cfg, _, err := config.LoadLayered(config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys})
+	if err != nil {
+		return nil, nil, err
+	}
+	dbPath := filepath.Join(cfg.OutputDir, "state.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, nil, fmt.Errorf("no state database: %w", err)
+	}
+	licenseKey, deviceID, err := license.SecretKeys(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	opt := storage.WithFieldEncryption(licenseKey, deviceID)
+	if cfg.EncryptState {
+		opt = storage.WithEncryption(licenseKey, deviceID)
+	}
+	store, err := storage.New(dbPath, opt)
+	return store, cfg, err
+}
+
+// rotate switches to a new data key and re-encrypts all rows.
+func rotate(args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	fs.Parse(args)
+
+	store, _, err := openStore(flags)
+	if err != nil {
+		return err
+	}
+	id, err := store.RotateDataKey()
+	if err != nil {
+		return err
+	}
+	n, err := store.Reencrypt()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("data key %d active, %d rows re-encrypted\n", id, n)
+	return nil
+}
+
+// rewrap wraps the data keys for another license.
+func rewrap(args []string) error {
+	fs := flag.NewFlagSet("rewrap", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	newLicense := fs.String("new-license", "", "license file the data keys are wrapped for")
+	fs.Parse(args)
+	if *newLicense == "" {
+		return fmt.Errorf("rewrap: -new-license is required")
+	}
+
+	store, cfg, err := openStore(flags)
+	if err != nil {
+		return err
+	}
+	next := *cfg
+	next.LicensePath = *newLicense
+	lic, err := license.NewValidator(&next).Validate(context.Background())
+	if err != nil {
+		return fmt.Errorf("new license: %w", err)
+	}
+	if err := store.RewrapDataKeys(lic.Key, []byte(lic.DeviceID)); err != nil {
+		return err
+	}
+	fmt.Printf("data keys wrapped for license %s\n", lic.Key)
+	return nil
+}
+
+// purgeCache deletes cached LLM responses. Cache entries need no data keys
+// to delete, so the license is only used if it is available.
+func purgeCache(args []string) error {
+	fs := flag.NewFlagSet("purge-cache", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	promptKey := fs.String("prompt-key", "", "only purge responses of this prompt key")
+	expired := fs.Bool("expired", false, "only purge expired responses")
+	fs.Parse(args)
+	if *promptKey != "" && *expired {
+		return fmt.Errorf("purge-cache: -prompt-key and -expired are exclusive")
+	}
+
+	cfg, _, err := config.LoadLayered(config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys})
+	if err != nil {
+		return err
+	}
+	store, err := storage.Open(cfg, license.SecretKeys)
+	if err != nil {
+		return err
+	}
+	var n int64
+	if *expired {
+		n, err = store.PurgeExpiredCache(time.Now())
+	} else {
+		n, err = store.PurgeCache(*promptKey)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d cached responses purged\n", n)
+	return nil
+}
+
+// backup snapshots the database and stream-encrypts the snapshot to -out.
+func backup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	out := fs.String("out", "", "file to write the encrypted backup to")
+	fs.Parse(args)
+	if *out == "" {
+		return fmt.Errorf("backup: -out is required")
+	}
+
+	cfg, _, err := config.LoadLayered(config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys})
+	if err != nil {
+		return err
+	}
+	licenseKey, deviceID, err := license.SecretKeys(cfg)
+	if err != nil {
+		return err
+	}
+	dbPath := filepath.Join(cfg.OutputDir, "state.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("no state database: %w", err)
+	}
+	store, err := storage.New(dbPath)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "vjal-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	snapshot := filepath.Join(tmp, "state.db")
+	if err := store.Snapshot(snapshot); err != nil {
+		return err
+	}
+
+	in, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := security.EncryptStream(f, licenseKey, deviceID, security.Options{})
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(w, in)
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%d bytes backed up to %s\n", n, *out)
+	return nil
+}
+
+// restore decrypts -in next to state.db and only replaces state.db once the
+// whole stream has been read, so a truncated or tampered backup leaves the
+// current database untouched.
+func restore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	in := fs.String("in", "", "encrypted backup written by backup")
+	force := fs.Bool("force", false, "replace an existing state database")
+	fs.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("restore: -in is required")
+	}
+
+	cfg, _, err := config.LoadLayered(config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys})
+	if err != nil {
+		return err
+	}
+	licenseKey, deviceID, err := license.SecretKeys(cfg)
+	if err != nil {
+		return err
+	}
+	dbPath := filepath.Join(cfg.OutputDir, "state.db")
+	if _, err := os.Stat(dbPath); err == nil && !*force {
+		return fmt.Errorf("restore: %s exists, use -force to replace it", dbPath)
+	}
+
+	src, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	r, err := security.DecryptStream(src, licenseKey, deviceID, nil)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(cfg.OutputDir, "state.db.restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dbPath); err != nil {
+		return err
+	}
+	fmt.Printf("%d bytes restored to %s\n", n, dbPath)
+	return nil
+}
@@ -0,0 +1,172 @@
+// cmd/vjal-usage/main.go
+//
+// vjal-usage exports signed usage reports for billing and verifies them.
+//
+//	vjal-usage export [-config config.json] [-from 2026-09-01] [-to 2026-09-30] [-out report.json]
+//	vjal-usage verify -report report.json -license customer-license.json [-pub keys/vjal-prod-2.pub]
+//
+// export runs on the customer's machine and defaults to the previous
+// calendar month. verify runs on the vendor side with the license on record.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+	"github.com/adi-ber/vjal-platform/pkg/usage"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usageText()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "export":
+		err = export(args)
+	case "verify":
+		err = verify(args)
+	default:
+		usageText()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vjal-usage: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usageText() {
+	fmt.Fprintln(os.Stderr, "usage: vjal-usage <export|verify> [flags]")
+}
+
+// export builds a report from the counters in <outputDir>/state.db.
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	firstOfMonth := time.Now().UTC().AddDate(0, 0, 1-time.Now().UTC().Day())
+	from := fs.String("from", firstOfMonth.AddDate(0, -1, 0).Format(usage.DayFormat), "first day of the report")
+	to := fs.String("to", firstOfMonth.AddDate(0, 0, -1).Format(usage.DayFormat), "last day of the report, inclusive")
+	out := fs.String("out", "", "write the report here instead of stdout")
+	fs.Parse(args)
+
+	fromDay, err := time.Parse(usage.DayFormat, *from)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	toDay, err := time.Parse(usage.DayFormat, *to)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+
+	cfg, _, err := config.LoadLayered(config.Options{Path: flags.ConfigPath, Flags: flags, Keys: license.SecretKeys})
+	if err != nil {
+		return err
+	}
+	// An expired license can still report the usage it accrued.
+	lic, err := license.NewValidator(cfg).Validate(context.Background())
+	var expired *license.ExpiredError
+	if err != nil && !errors.As(err, &expired) {
+		return err
+	}
+	deviceID, _ := license.SystemFingerprint{Sources: cfg.DeviceSources}.Fingerprint()
+
+	dbPath := filepath.Join(cfg.OutputDir, "state.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("no usage recorded: %w", err)
+	}
+	store, err := storage.New(dbPath)
+	if err != nil {
+		return err
+	}
+	report, err := usage.BuildReport(store, lic, deviceID, fromDay, toDay)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote usage of %s for %s..%s to %s\n", report.LicenseKey, report.From, report.To, *out)
+	return nil
+}
+
+// verify checks a report against the license it claims to cover.
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	reportPath := fs.String("report", "", "usage report to check (required)")
+	licPath := fs.String("license", "", "the customer's license file (required)")
+	pub := fs.String("pub", "", "verify the license against this public key file instead of the embedded keys")
+	fs.Parse(args)
+	if *reportPath == "" || *licPath == "" {
+		return errors.New("-report and -license are required")
+	}
+
+	lic, err := license.ReadFile(*licPath)
+	if err != nil {
+		return err
+	}
+	keys := license.DefaultKeyring()
+	if *pub != "" {
+		data, err := os.ReadFile(*pub)
+		if err != nil {
+			return err
+		}
+		key, err := license.ParsePublicKey(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", *pub, err)
+		}
+		keys = license.Keyring{lic.KeyID: {Public: key}}
+	}
+	if err := lic.Verify(keys, ""); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*reportPath)
+	if err != nil {
+		return err
+	}
+	var report usage.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("invalid JSON in report: %w", err)
+	}
+	if err := report.Verify(lic); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: OK\n", *reportPath)
+	fmt.Printf("License:   %s\n", report.LicenseKey)
+	fmt.Printf("Period:    %s .. %s (generated %s)\n", report.From, report.To, report.GeneratedAt.Format(time.RFC3339))
+	fmt.Printf("LLM calls: %d\n", report.LLMCalls)
+	fmt.Printf("PDFs:      %d\n", report.PDFs)
+	features := make([]string, 0, len(report.Features))
+	for f := range report.Features {
+		features = append(features, f)
+	}
+	sort.Strings(features)
+	for _, f := range features {
+		fmt.Printf("Feature:   %-14s %d\n", f, report.Features[f])
+	}
+	return nil
+}
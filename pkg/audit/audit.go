@@ -0,0 +1,123 @@
+// pkg/audit/audit.go
+package audit
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/pii"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+// Actions written by the servers.
+const (
+	ActionSubmit  = "submit"   // a form submission produced a result
+	ActionLLMCall = "llm_call" // one prompt sent to an LLM provider
+	ActionLicense = "license"  // the license status changed
+)
+
+// SystemActor is the actor of entries no request caused.
+const SystemActor = "system"
+
+// Log is where entries go; *storage.Store implements it.
+type Log interface {
+	AppendAudit(storage.AuditEntry) (storage.AuditEntry, error)
+}
+
+// Recorder writes audit entries with the actor and prompt key carried by a
+// request context. A nil *Recorder records nothing.
+type Recorder struct {
+	log Log
+}
+
+// New records into l.
+func New(l Log) *Recorder {
+	return &Recorder{log: l}
+}
+
+// Record appends an entry for action. input and output are stored as
+// SHA-256 hashes only; nil leaves the hash empty. Sensitive values carried
+// by ctx (see pii.WithValues) are masked in input, output and detail
+// first, so the hashes cannot be used to confirm a guessed value. Failures
+// are logged, not returned, so auditing never fails a request.
+func (r *Recorder) Record(ctx context.Context, action string, input, output []byte, detail string) {
+	if r == nil {
+		return
+	}
+	sensitive := pii.ValuesFrom(ctx)
+	e := storage.AuditEntry{
+		Actor:     Actor(ctx),
+		Action:    action,
+		PromptKey: PromptKey(ctx),
+		Detail:    pii.Scrub(detail, sensitive),
+	}
+	if input != nil {
+		e.InputHash = storage.HashContent([]byte(pii.Scrub(string(input), sensitive)))
+	}
+	if output != nil {
+		e.OutputHash = storage.HashContent([]byte(pii.Scrub(string(output), sensitive)))
+	}
+	if _, err := r.log.AppendAudit(e); err != nil {
+		log.Printf("⚠️  audit: recording %s: %v", action, err)
+	}
+}
+
+// LicenseEvent records a license status change. Pass it to
+// license.WithStatusHook.
+func (r *Recorder) LicenseEvent(e license.Event) {
+	detail := e.Old.String() + " -> " + e.New.String()
+	if e.License != nil {
+		detail += " license=" + e.License.Key
+	}
+	if e.Err != nil {
+		detail += ": " + e.Err.Error()
+	}
+	r.Record(context.Background(), ActionLicense, nil, nil, detail)
+}
+
+type ctxKey int
+
+const (
+	actorKey ctxKey = iota
+	promptKeyKey
+)
+
+// WithActor returns ctx carrying the actor to record.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the actor carried by ctx, or SystemActor.
+func Actor(ctx context.Context) string {
+	if a, ok := ctx.Value(actorKey).(string); ok && a != "" {
+		return a
+	}
+	return SystemActor
+}
+
+// WithPromptKey returns ctx carrying the prompt key a request runs.
+func WithPromptKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, promptKeyKey, key)
+}
+
+// PromptKey returns the prompt key carried by ctx, or "".
+func PromptKey(ctx context.Context) string {
+	k, _ := ctx.Value(promptKeyKey).(string)
+	return k
+}
+
+// Middleware sets the actor of every request to its client address. The
+// servers have no user accounts, so the address is the best identity they
+// know.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		next.ServeHTTP(w, r.WithContext(WithActor(r.Context(), "ip:"+host)))
+	})
+}
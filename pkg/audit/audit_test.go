@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/pii"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+func newTestRecorder(t *testing.T) (*Recorder, *storage.Store) {
+	t.Helper()
+	store, err := storage.New(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	return New(store), store
+}
+
+func TestRecorder_LicenseEvent(t *testing.T) {
+	rec, store := newTestRecorder(t)
+	rec.LicenseEvent(license.Event{Old: license.StatusActive, New: license.StatusInvalid, Err: errors.New("revoked")})
+
+	entries, _ := store.AuditEntries(0, 0)
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Action != ActionLicense || e.Actor != SystemActor || !strings.Contains(e.Detail, "active -> invalid") {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestMiddleware_SetsActor(t *testing.T) {
+	rec, store := newTestRecorder(t)
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.Record(WithPromptKey(r.Context(), "userSummary"), ActionSubmit, []byte("in"), []byte("out"), "")
+	}))
+	req := httptest.NewRequest("POST", "/process", nil)
+	req.RemoteAddr = "192.0.2.7:5555"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries, _ := store.AuditEntries(0, 0)
+	if len(entries) != 1 || entries[0].Actor != "ip:192.0.2.7" || entries[0].PromptKey != "userSummary" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if _, err := store.VerifyAudit(); err != nil {
+		t.Errorf("VerifyAudit: %v", err)
+	}
+}
+
+func TestRecorder_NilIsNoop(t *testing.T) {
+	var rec *Recorder
+	rec.Record(context.Background(), ActionSubmit, nil, nil, "")
+}
+
+func TestRecorder_ExcludesSensitiveValues(t *testing.T) {
+	rec, store := newTestRecorder(t)
+	ctx := pii.WithValues(context.Background(), []string{"Ada"})
+	rec.Record(ctx, ActionSubmit, []byte(`{"name":"Ada"}`), []byte("Hello Ada"), "for Ada")
+
+	entries, _ := store.AuditEntries(0, 0)
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(entries))
+	}
+	if want := storage.HashContent([]byte(`{"name":"[REDACTED]"}`)); entries[0].InputHash != want {
+		t.Errorf("input hash covers the sensitive value")
+	}
+	if entries[0].Detail != "for [REDACTED]" {
+		t.Errorf("Detail = %q", entries[0].Detail)
+	}
+}
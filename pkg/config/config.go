@@ -1,13 +1,30 @@
 // pkg/config/config.go
+
+// Package config loads AppConfig from layered sources. Later layers override
+// earlier ones, in this order of precedence (lowest first):
+//
+//  1. built-in defaults
+//  2. the base config file (e.g. config.json)
+//  3. the profile file next to it, named after the effective env
+//     (e.g. config.production.json); a missing profile file is not an error
+//  4. VJAL_* environment variables, including VJAL_LLM_CONFIG_<KEY> for
+//     individual llmConfig entries
+//  5. command-line flags registered with RegisterFlags
+//
+// The effective env, which selects the profile file, is itself resolved from
+// the -env flag, then VJAL_ENV, then the base file.
+//
+// Once every layer is applied, llmConfig values written as secret references
+// (env:NAME, file:/path or enc:<base64>) are replaced by the secret they point
+// at. Resolved secrets are redacted whenever the config is formatted or
+// marshalled.
 package config
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"log"
+	"strings"
 
 	"github.com/adi-ber/vjal-platform/pkg/metrics"
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,83 +32,107 @@ import (
 
 // AppConfig holds application configuration loaded from JSON and environment variables.
 type AppConfig struct {
-	Env             string            `json:"env"`             // "development" or "production"
-	HTTPPort        int               `json:"httpPort"`        // port for HTTP server
-	LicensePath     string            `json:"licensePath"`     // path to license.json
-	LLMProvider     string            `json:"llmProvider"`     // "openai", "vjal", or "offline"
-	LLMConfig       map[string]string `json:"llmConfig"`       // provider-specific settings
-	FormSchema      string            `json:"formSchema"`      // path to JSON form schema
-	OutputDir       string            `json:"outputDir"`       // path to write outputs
-	MetricsEndpoint string            `json:"metricsEndpoint"` // pushgateway URL or empty
+	Env             string            `json:"env"`                     // "development" or "production"
+	HTTPPort        int               `json:"httpPort"`                // port for HTTP server
+	AdminAddr       string            `json:"adminAddr,omitempty"`     // listen address for /admin endpoints; empty disables them
+	LicensePath     string            `json:"licensePath"`             // path to license.json
+	LLMProvider     string            `json:"llmProvider"`             // "openai", "vjal", or "offline"
+	LLMConfig       map[string]string `json:"llmConfig"`               // provider-specific settings
+	FormSchema      string            `json:"formSchema"`              // path to JSON form schema
+	OutputDir       string            `json:"outputDir"`               // path to write outputs
+	MetricsEndpoint string            `json:"metricsEndpoint"`         // pushgateway URL or empty
+	DeviceSources   []string          `json:"deviceSources,omitempty"` // device fingerprint sources; empty means all
+
+	LicenseGracePeriod   Duration `json:"licenseGracePeriod,omitempty"`   // how long features keep working past expiry
+	LicenseCheckInterval Duration `json:"licenseCheckInterval,omitempty"` // how often the license is revalidated
+	LicenseServer        string   `json:"licenseServer,omitempty"`        // base URL of the lease server; empty means no activation
+
+	AssetManifest string `json:"assetManifest,omitempty"` // signed asset manifest; empty means manifest.json
+	EncryptState  bool   `json:"encryptState,omitempty"`  // encrypt stored form answers with license-derived keys
+	TokenizePII   bool   `json:"tokenizePII,omitempty"`   // replace sensitive values with tokens before external LLM calls
+
+	LLMFallbacks  []LLMFallback `json:"llmFallbacks,omitempty"`  // providers tried in order when llmProvider fails
+	LLMFailoverOn []string      `json:"llmFailoverOn,omitempty"` // error classes that move on to the next provider; empty means the default set
+	LLMCacheTTL   Duration      `json:"llmCacheTTL,omitempty"`   // how long LLM responses are cached; 0 disables the cache
+
+	LLMPrices        map[string]LLMPrice `json:"llmPrices,omitempty"`        // per-model prices, keyed "provider/model" or "model"
+	LLMDailyBudget   float64             `json:"llmDailyBudget,omitempty"`   // LLM spend allowed per UTC day; 0 means no ceiling
+	LLMMonthlyBudget float64             `json:"llmMonthlyBudget,omitempty"` // LLM spend allowed per calendar month; 0 means no ceiling
+
+	secrets map[string]bool // llmConfig keys resolved from secret references
+}
+
+// LLMFallback is one provider in the chain after llmProvider, with its own
+// settings. Nothing is inherited from the top-level llmConfig.
+type LLMFallback struct {
+	Provider string            `json:"provider"`
+	Config   map[string]string `json:"llmConfig,omitempty"`
 }
 
-// Load reads the JSON config file at the given path, overrides via env vars,
-// validates required fields, and records metrics on load duration and errors.
+// LLMPrice is what a model costs per million tokens, in whatever currency
+// the budgets use.
+type LLMPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Options controls which layers LoadLayered reads.
+type Options struct {
+	Path  string    // base config file
+	Flags *Flags    // optional command-line overrides from RegisterFlags
+	Keys  KeySource // optional license keys for enc: secret references
+}
+
+// Load reads the JSON config file at the given path together with its
+// profile file and VJAL_* environment overrides, validates required fields,
+// and records metrics on load duration and errors.
 func Load(path string) (*AppConfig, error) {
+	cfg, _, err := LoadLayered(Options{Path: path})
+	return cfg, err
+}
+
+// LoadLayered merges every configured layer into one AppConfig and reports
+// where each effective value came from.
+func LoadLayered(opts Options) (*AppConfig, Provenance, error) {
 	// Start timer for load duration
 	timer := prometheus.NewTimer(metrics.ConfigLoadDuration)
 	defer timer.ObserveDuration()
 
-	// Resolve absolute path
-	absPath, err := filepath.Abs(path)
+	cfg, prov, warnings, err := load(opts)
 	if err != nil {
 		metrics.ConfigLoadErrors.Inc()
-		return nil, fmt.Errorf("invalid config path %q: %w", path, err)
+		return nil, nil, err
 	}
-
-	// Read file
-	data, err := ioutil.ReadFile(absPath)
-	if err != nil {
-		metrics.ConfigLoadErrors.Inc()
-		return nil, fmt.Errorf("failed to read config file %s: %w", absPath, err)
+	for _, w := range warnings {
+		log.Printf("⚠️  config: %s", w)
 	}
 
-	// Parse JSON
-	var cfg AppConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	// Validate required fields
+	if err := validateRequired(cfg); err != nil {
 		metrics.ConfigLoadErrors.Inc()
-		return nil, fmt.Errorf("invalid JSON in config file: %w", err)
+		return nil, nil, err
 	}
+	return cfg, prov, nil
+}
 
-	// Override with environment variables
-	overrideEnv(&cfg)
-
-	// Validate required fields
+// validateRequired reports every missing required field at once.
+func validateRequired(cfg *AppConfig) error {
+	var missing []string
 	if cfg.LicensePath == "" {
-		metrics.ConfigLoadErrors.Inc()
-		return nil, errors.New("licensePath is required in config")
+		missing = append(missing, "licensePath")
 	}
 	if cfg.FormSchema == "" {
-		metrics.ConfigLoadErrors.Inc()
-		return nil, errors.New("formSchema is required in config")
+		missing = append(missing, "formSchema")
 	}
 	if cfg.LLMProvider == "" {
-		metrics.ConfigLoadErrors.Inc()
-		return nil, errors.New("llmProvider is required in config")
+		missing = append(missing, "llmProvider")
 	}
-	if cfg.HTTPPort == 0 {
-		cfg.HTTPPort = 8080 // default port
+	switch len(missing) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s is required in config", missing[0])
+	default:
+		return errors.New(strings.Join(missing, ", ") + " are required in config")
 	}
-
-	return &cfg, nil
 }
-
-// overrideEnv checks for environment variables prefixed with VJAL_ and overrides fields.
-func overrideEnv(cfg *AppConfig) {
-	if v := os.Getenv("VJAL_ENV"); v != "" {
-		cfg.Env = v
-	}
-	if v := os.Getenv("VJAL_HTTP_PORT"); v != "" {
-		fmt.Sscanf(v, "%d", &cfg.HTTPPort)
-	}
-	if v := os.Getenv("VJAL_LICENSE_PATH"); v != "" {
-		cfg.LicensePath = v
-	}
-	if v := os.Getenv("VJAL_LLM_PROVIDER"); v != "" {
-		cfg.LLMProvider = v
-	}
-	if v := os.Getenv("VJAL_FORM_SCHEMA"); v != "" {
-		cfg.FormSchema = v
-	}
-	// Add overrides for other fields as needed
-}
\ No newline at end of file
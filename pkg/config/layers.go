@@ -0,0 +1,374 @@
+// pkg/config/layers.go
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// llmConfigEnvPrefix prefixes environment variables that set a single
+// llmConfig entry, e.g. VJAL_LLM_CONFIG_OPENAI_KEY sets llmConfig.openai_key.
+const llmConfigEnvPrefix = "VJAL_LLM_CONFIG_"
+
+// DefaultAdminAddr keeps the unauthenticated /admin endpoints on loopback
+// unless adminAddr says otherwise.
+const DefaultAdminAddr = "127.0.0.1:8081"
+
+// Source identifies the layer an effective config value came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceProfile Source = "profile"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Origin records the layer and location (file path, env var or flag name)
+// that set an effective value, and the kind of secret reference it held.
+type Origin struct {
+	Source   Source
+	Location string
+	Ref      string // "env", "file", "enc" or empty for plain values
+}
+
+// Provenance maps a field path such as "httpPort" or "llmConfig.openai_key"
+// to the origin of its effective value. It never holds the values themselves.
+type Provenance map[string]Origin
+
+// String renders the provenance as an aligned, sorted table.
+func (p Provenance) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, k := range keys {
+		o := p[k]
+		ref := ""
+		if o.Ref != "" {
+			ref = "secret:" + o.Ref
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k, o.Source, o.Location, ref)
+	}
+	tw.Flush()
+	return buf.String()
+}
+
+// field describes one scalar AppConfig field and how each layer addresses it.
+type field struct {
+	key  string // JSON key and provenance path
+	env  string // environment variable
+	flag string // command-line flag name
+	set  func(cfg *AppConfig, v string) error
+}
+
+var fields = []field{
+	{"env", "VJAL_ENV", "env", func(c *AppConfig, v string) error { c.Env = v; return nil }},
+	{"httpPort", "VJAL_HTTP_PORT", "http-port", func(c *AppConfig, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		c.HTTPPort = n
+		return nil
+	}},
+	{"adminAddr", "VJAL_ADMIN_ADDR", "admin-addr", func(c *AppConfig, v string) error { c.AdminAddr = v; return nil }},
+	{"licensePath", "VJAL_LICENSE_PATH", "license-path", func(c *AppConfig, v string) error { c.LicensePath = v; return nil }},
+	{"llmProvider", "VJAL_LLM_PROVIDER", "llm-provider", func(c *AppConfig, v string) error { c.LLMProvider = v; return nil }},
+	{"formSchema", "VJAL_FORM_SCHEMA", "form-schema", func(c *AppConfig, v string) error { c.FormSchema = v; return nil }},
+	{"outputDir", "VJAL_OUTPUT_DIR", "output-dir", func(c *AppConfig, v string) error { c.OutputDir = v; return nil }},
+	{"metricsEndpoint", "VJAL_METRICS_ENDPOINT", "metrics-endpoint", func(c *AppConfig, v string) error { c.MetricsEndpoint = v; return nil }},
+	{"deviceSources", "VJAL_DEVICE_SOURCES", "device-sources", func(c *AppConfig, v string) error {
+		c.DeviceSources = strings.Split(v, ",")
+		return nil
+	}},
+	{"licenseGracePeriod", "VJAL_LICENSE_GRACE_PERIOD", "license-grace-period", func(c *AppConfig, v string) error {
+		return c.LicenseGracePeriod.Set(v)
+	}},
+	{"licenseCheckInterval", "VJAL_LICENSE_CHECK_INTERVAL", "license-check-interval", func(c *AppConfig, v string) error {
+		return c.LicenseCheckInterval.Set(v)
+	}},
+	{"licenseServer", "VJAL_LICENSE_SERVER", "license-server", func(c *AppConfig, v string) error {
+		c.LicenseServer = v
+		return nil
+	}},
+	{"assetManifest", "VJAL_ASSET_MANIFEST", "asset-manifest", func(c *AppConfig, v string) error {
+		c.AssetManifest = v
+		return nil
+	}},
+	{"encryptState", "VJAL_ENCRYPT_STATE", "encrypt-state", func(c *AppConfig, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		c.EncryptState = b
+		return nil
+	}},
+	{"tokenizePII", "VJAL_TOKENIZE_PII", "tokenize-pii", func(c *AppConfig, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		c.TokenizePII = b
+		return nil
+	}},
+	{"llmFallbacks", "VJAL_LLM_FALLBACKS", "llm-fallbacks", func(c *AppConfig, v string) error {
+		// Only provider names can be given this way; each gets empty settings.
+		c.LLMFallbacks = nil
+		for _, name := range strings.Split(v, ",") {
+			c.LLMFallbacks = append(c.LLMFallbacks, LLMFallback{Provider: strings.TrimSpace(name)})
+		}
+		return nil
+	}},
+	{"llmFailoverOn", "VJAL_LLM_FAILOVER_ON", "llm-failover-on", func(c *AppConfig, v string) error {
+		c.LLMFailoverOn = strings.Split(v, ",")
+		return nil
+	}},
+	{"llmCacheTTL", "VJAL_LLM_CACHE_TTL", "llm-cache-ttl", func(c *AppConfig, v string) error {
+		return c.LLMCacheTTL.Set(v)
+	}},
+	{"llmDailyBudget", "VJAL_LLM_DAILY_BUDGET", "llm-daily-budget", func(c *AppConfig, v string) error {
+		return setBudget(&c.LLMDailyBudget, v)
+	}},
+	{"llmMonthlyBudget", "VJAL_LLM_MONTHLY_BUDGET", "llm-monthly-budget", func(c *AppConfig, v string) error {
+		return setBudget(&c.LLMMonthlyBudget, v)
+	}},
+}
+
+func setBudget(dst *float64, v string) error {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("invalid amount %q", v)
+	}
+	*dst = f
+	return nil
+}
+
+// lookupField finds the field for a JSON key, matching case-insensitively
+// as encoding/json does.
+func lookupField(key string) (field, bool) {
+	for _, f := range fields {
+		if strings.EqualFold(f.key, key) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Flags holds the command-line overrides registered by RegisterFlags.
+// Only flags that were actually passed take part in loading.
+type Flags struct {
+	ConfigPath string // value of -config
+
+	values map[string]string // JSON key → value
+	llm    map[string]string // llmConfig key → value
+}
+
+// RegisterFlags adds -config, one flag per AppConfig field and a repeatable
+// -llm-config key=value flag to fs.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{values: make(map[string]string), llm: make(map[string]string)}
+	fs.StringVar(&f.ConfigPath, "config", "config.json", "path to the base config file")
+	for _, fd := range fields {
+		fs.Func(fd.flag, "override "+fd.key, func(v string) error {
+			f.values[fd.key] = v
+			return nil
+		})
+	}
+	fs.Func("llm-config", "override an llmConfig entry as key=value (repeatable)", func(v string) error {
+		k, val, ok := strings.Cut(v, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("expected key=value, got %q", v)
+		}
+		f.llm[k] = val
+		return nil
+	})
+	return f
+}
+
+// load applies every layer in precedence order and resolves secret
+// references, without validating the result. Unknown keys in the config
+// files are returned as warnings.
+func load(opts Options) (*AppConfig, Provenance, []Problem, error) {
+	r := &Report{}
+	cfg, prov, err := layers(opts, r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := r.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	// Secret references may come from any layer, so resolve them last
+	if err := resolveSecrets(cfg, opts.Keys, prov); err != nil {
+		return nil, nil, nil, err
+	}
+	return cfg, prov, r.Warnings, nil
+}
+
+// layers applies every layer in precedence order and leaves secret
+// references unresolved. Unknown keys in the config files are added to r as
+// warnings and unusable override values as errors, and loading goes on. An
+// error return means a config file could not be read at all.
+func layers(opts Options, r *Report) (*AppConfig, Provenance, error) {
+	absPath, err := filepath.Abs(opts.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid config path %q: %w", opts.Path, err)
+	}
+
+	prov := make(Provenance)
+
+	// 1) Defaults
+	cfg := &AppConfig{HTTPPort: 8080, AdminAddr: DefaultAdminAddr, LLMConfig: make(map[string]string)}
+	prov["httpPort"] = Origin{Source: SourceDefault}
+	prov["adminAddr"] = Origin{Source: SourceDefault}
+
+	// 2) Base file
+	if err := mergeFile(cfg, absPath, SourceFile, prov, &r.Warnings); err != nil {
+		return nil, nil, err
+	}
+
+	// 3) Profile file, selected by the env the higher layers will end up with
+	env := cfg.Env
+	if v, ok := os.LookupEnv("VJAL_ENV"); ok && v != "" {
+		env = v
+	}
+	if opts.Flags != nil && opts.Flags.values["env"] != "" {
+		env = opts.Flags.values["env"]
+	}
+	if env != "" {
+		profile := profilePath(absPath, env)
+		if err := mergeFile(cfg, profile, SourceProfile, prov, &r.Warnings); err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+
+	// 4) Environment variables
+	overrideEnv(cfg, prov, r)
+
+	// 5) Command-line flags
+	if opts.Flags != nil {
+		overrideFlags(cfg, opts.Flags, prov, r)
+	}
+	return cfg, prov, nil
+}
+
+// profilePath returns the profile file for env next to the base file,
+// e.g. /etc/vjal/config.json → /etc/vjal/config.production.json.
+func profilePath(base, env string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + env + ext
+}
+
+// mergeFile unmarshals the JSON file at path over cfg and records which keys
+// it set. llmConfig entries are merged key by key rather than replaced.
+// Keys that do not exactly match an AppConfig field are reported as warnings.
+func mergeFile(cfg *AppConfig, path string, src Source, prov Provenance, warnings *[]Problem) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && src == SourceProfile {
+			return err
+		}
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid JSON in config file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("invalid JSON in config file %s: %w", path, err)
+	}
+
+	origin := Origin{Source: src, Location: path}
+	for key, val := range raw {
+		if w, ok := unknownKey(key, path); ok {
+			*warnings = append(*warnings, w)
+		}
+		if strings.EqualFold(key, "llmConfig") {
+			var entries map[string]json.RawMessage
+			if err := json.Unmarshal(val, &entries); err == nil {
+				for k := range entries {
+					prov["llmConfig."+k] = origin
+				}
+			}
+			continue
+		}
+		if f, ok := lookupField(key); ok {
+			prov[f.key] = origin
+		}
+	}
+	return nil
+}
+
+// overrideEnv applies VJAL_* environment variables, including one
+// VJAL_LLM_CONFIG_<KEY> variable per llmConfig entry. Values that cannot be
+// applied are reported in r.
+func overrideEnv(cfg *AppConfig, prov Provenance, r *Report) {
+	for _, f := range fields {
+		v := os.Getenv(f.env)
+		if v == "" {
+			continue
+		}
+		if err := f.set(cfg, v); err != nil {
+			r.errorf(f.env, "%v", err)
+			continue
+		}
+		prov[f.key] = Origin{Source: SourceEnv, Location: f.env}
+	}
+
+	for _, kv := range os.Environ() {
+		name, v, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, llmConfigEnvPrefix) || len(name) == len(llmConfigEnvPrefix) {
+			continue
+		}
+		key := llmConfigKey(cfg.LLMConfig, strings.TrimPrefix(name, llmConfigEnvPrefix))
+		cfg.LLMConfig[key] = v
+		prov["llmConfig."+key] = Origin{Source: SourceEnv, Location: name}
+	}
+}
+
+// overrideFlags applies the flags that were passed on the command line.
+// Values that cannot be applied are reported in r.
+func overrideFlags(cfg *AppConfig, fl *Flags, prov Provenance, r *Report) {
+	for _, f := range fields {
+		v, ok := fl.values[f.key]
+		if !ok {
+			continue
+		}
+		if err := f.set(cfg, v); err != nil {
+			r.errorf("-"+f.flag, "%v", err)
+			continue
+		}
+		prov[f.key] = Origin{Source: SourceFlag, Location: "-" + f.flag}
+	}
+	for k, v := range fl.llm {
+		key := llmConfigKey(cfg.LLMConfig, k)
+		cfg.LLMConfig[key] = v
+		prov["llmConfig."+key] = Origin{Source: SourceFlag, Location: "-llm-config"}
+	}
+}
+
+// llmConfigKey maps an override name onto an existing llmConfig key when one
+// matches case-insensitively, so OPENAI_KEY overrides "openai_key". New keys
+// are lower-cased.
+func llmConfigKey(m map[string]string, name string) string {
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+const baseConfig = `{
+	"env": "staging",
+	"httpPort": 9090,
+	"licensePath": "license.json",
+	"llmProvider": "openai",
+	"llmConfig": {"openai_key": "base", "model": "gpt-4o"},
+	"formSchema": "schema.json",
+	"outputDir": "out"
+}`
+
+func TestLoadLayered_ProfileEnvAndFlags(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"config.json":         baseConfig,
+		"config.staging.json": `{"outputDir": "staging-out", "llmConfig": {"model": "gpt-4o-mini"}}`,
+	})
+	t.Setenv("VJAL_METRICS_ENDPOINT", "http://push:9091")
+	t.Setenv("VJAL_LLM_CONFIG_OPENAI_KEY", "from-env")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	if err := fs.Parse([]string{"-http-port", "7070", "-llm-config", "org=acme"}); err != nil {
+		t.Fatalf("flag parse: %v", err)
+	}
+
+	cfg, prov, err := LoadLayered(Options{Path: filepath.Join(dir, "config.json"), Flags: flags})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.OutputDir != "staging-out" {
+		t.Errorf("expected profile outputDir, got %q", cfg.OutputDir)
+	}
+	if cfg.LLMConfig["model"] != "gpt-4o-mini" {
+		t.Errorf("expected profile model, got %q", cfg.LLMConfig["model"])
+	}
+	if cfg.LLMConfig["openai_key"] != "from-env" {
+		t.Errorf("expected env openai_key, got %q", cfg.LLMConfig["openai_key"])
+	}
+	if cfg.MetricsEndpoint != "http://push:9091" {
+		t.Errorf("expected env metricsEndpoint, got %q", cfg.MetricsEndpoint)
+	}
+	if cfg.HTTPPort != 7070 {
+		t.Errorf("expected flag httpPort 7070, got %d", cfg.HTTPPort)
+	}
+	if cfg.LLMConfig["org"] != "acme" {
+		t.Errorf("expected flag llmConfig.org, got %q", cfg.LLMConfig["org"])
+	}
+	if cfg.AdminAddr != DefaultAdminAddr {
+		t.Errorf("expected default adminAddr %s, got %q", DefaultAdminAddr, cfg.AdminAddr)
+	}
+
+	want := map[string]Source{
+		"env":                  SourceFile,
+		"outputDir":            SourceProfile,
+		"llmConfig.model":      SourceProfile,
+		"llmConfig.openai_key": SourceEnv,
+		"metricsEndpoint":      SourceEnv,
+		"httpPort":             SourceFlag,
+		"adminAddr":            SourceDefault,
+		"llmConfig.org":        SourceFlag,
+	}
+	for key, src := range want {
+		if got := prov[key].Source; got != src {
+			t.Errorf("provenance of %s: expected %s, got %s", key, src, got)
+		}
+	}
+	if report := prov.String(); strings.Contains(report, "from-env") {
+		t.Errorf("provenance report must not contain values:\n%s", report)
+	}
+}
+
+func TestLoadLayered_EnvSelectsProfile(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"config.json":            baseConfig,
+		"config.production.json": `{"httpPort": 80}`,
+	})
+	t.Setenv("VJAL_ENV", "production")
+
+	cfg, prov, err := LoadLayered(Options{Path: filepath.Join(dir, "config.json")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.HTTPPort != 80 {
+		t.Errorf("expected production profile port 80, got %d", cfg.HTTPPort)
+	}
+	if prov["env"].Location != "VJAL_ENV" {
+		t.Errorf("expected env from VJAL_ENV, got %+v", prov["env"])
+	}
+}
+
+func TestLoadLayered_InvalidEnvPort(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{"config.json": baseConfig})
+	t.Setenv("VJAL_HTTP_PORT", "eighty")
+
+	if _, _, err := LoadLayered(Options{Path: filepath.Join(dir, "config.json")}); err == nil {
+		t.Fatal("expected error for non-numeric VJAL_HTTP_PORT, got nil")
+	}
+}
+
+func TestLoadLayered_Durations(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"config.json": strings.Replace(baseConfig, `"outputDir": "out"`, `"outputDir": "out", "licenseGracePeriod": "3d"`, 1),
+	})
+	t.Setenv("VJAL_LICENSE_CHECK_INTERVAL", "15m")
+
+	cfg, _, err := LoadLayered(Options{Path: filepath.Join(dir, "config.json")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if time.Duration(cfg.LicenseGracePeriod) != 72*time.Hour {
+		t.Errorf("expected 72h grace period, got %v", time.Duration(cfg.LicenseGracePeriod))
+	}
+	if time.Duration(cfg.LicenseCheckInterval) != 15*time.Minute {
+		t.Errorf("expected 15m check interval, got %v", time.Duration(cfg.LicenseCheckInterval))
+	}
+
+	t.Setenv("VJAL_LICENSE_CHECK_INTERVAL", "soon")
+	if _, _, err := LoadLayered(Options{Path: filepath.Join(dir, "config.json")}); err == nil {
+		t.Fatal("expected error for invalid duration, got nil")
+	}
+}
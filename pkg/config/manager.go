@@ -0,0 +1,209 @@
+// pkg/config/manager.go
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/metrics"
+)
+
+// Subscriber is notified after a new config has been swapped in, so it can
+// rebuild whatever it derived from the old one. If it returns an error the
+// reload is rolled back, and it is called again with the configs reversed.
+type Subscriber interface {
+	OnConfigChange(old, new *AppConfig) error
+}
+
+// SubscriberFunc adapts a plain function to Subscriber.
+type SubscriberFunc func(old, new *AppConfig) error
+
+// OnConfigChange calls f(old, new).
+func (f SubscriberFunc) OnConfigChange(old, new *AppConfig) error {
+	return f(old, new)
+}
+
+type subscription struct {
+	name string
+	sub  Subscriber
+}
+
+// Manager owns the active AppConfig. It polls the config files for changes,
+// validates each new version before swapping it in atomically, and notifies
+// subscribers. A reload that fails to load or validate, or that a
+// subscriber refuses, is rejected and the previous config stays active.
+type Manager struct {
+	opts     Options
+	interval time.Duration
+
+	current atomic.Pointer[AppConfig]
+	prov    atomic.Pointer[Provenance]
+
+	mu     sync.Mutex // serialises reloads and guards subs and digest
+	subs   []subscription
+	digest [sha256.Size]byte
+}
+
+// NewManager loads and validates the initial config with opts. interval is
+// how often Run polls the config files; zero disables polling.
+func NewManager(opts Options, interval time.Duration) (*Manager, error) {
+	cfg, prov, err := loadValid(opts)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{opts: opts, interval: interval}
+	m.current.Store(cfg)
+	m.prov.Store(&prov)
+	m.digest = m.fileDigest(cfg)
+	return m, nil
+}
+
+// Current returns the active config. Callers must treat it as read-only and
+// should call Current again rather than caching the pointer.
+func (m *Manager) Current() *AppConfig {
+	return m.current.Load()
+}
+
+// Provenance returns where each value of the active config came from.
+func (m *Manager) Provenance() Provenance {
+	return *m.prov.Load()
+}
+
+// Subscribe registers s to be notified of every successful reload. Subscribers
+// run in registration order; name identifies them in logs.
+func (m *Manager) Subscribe(name string, s Subscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = append(m.subs, subscription{name: name, sub: s})
+}
+
+// Run polls the config files every interval until ctx is cancelled, reloading
+// whenever their contents change.
+func (m *Manager) Run(ctx context.Context) {
+	if m.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			changed := m.fileDigest(m.Current()) != m.digest
+			m.mu.Unlock()
+			if !changed {
+				continue
+			}
+			if err := m.Reload(); err != nil {
+				log.Printf("⚠️  config reload rejected: %v", err)
+			}
+		}
+	}
+}
+
+// Reload loads and validates the config now. On success the new config is
+// swapped in and subscribers are notified in order. If one fails, the old
+// config is swapped back and every subscriber notified so far, including
+// the failing one, is notified again with old and new reversed; the
+// subscriber's error is returned. On load or validation failure the old
+// config stays active and no subscriber is called.
+func (m *Manager) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cfg, prov, err := loadValid(m.opts)
+	if err != nil {
+		// Record the failing contents so an unchanged bad file is not retried every tick.
+		if old := m.Current(); old != nil {
+			m.digest = m.fileDigest(old)
+		}
+		return err
+	}
+
+	old := m.current.Swap(cfg)
+	oldProv := m.prov.Swap(&prov)
+	m.digest = m.fileDigest(cfg)
+
+	for i, s := range m.subs {
+		if err := s.sub.OnConfigChange(old, cfg); err != nil {
+			m.current.Store(old)
+			m.prov.Store(oldProv)
+			m.rollback(m.subs[:i+1], cfg, old)
+			return fmt.Errorf("subscriber %s: %w", s.name, err)
+		}
+	}
+	metrics.ConfigReloadsTotal.Inc()
+	return nil
+}
+
+// rollback tells subs, most recent first, that the config went from
+// rejected back to restored. Their errors can only be logged.
+func (m *Manager) rollback(subs []subscription, rejected, restored *AppConfig) {
+	for i := len(subs) - 1; i >= 0; i-- {
+		if err := subs[i].sub.OnConfigChange(rejected, restored); err != nil {
+			log.Printf("⚠️  config rollback: subscriber %s: %v", subs[i].name, err)
+		}
+	}
+}
+
+// loadValid loads opts and rejects configs with validation errors.
+func loadValid(opts Options) (*AppConfig, Provenance, error) {
+	cfg, prov, err := LoadLayered(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	report := Validate(cfg)
+	for _, w := range report.Warnings {
+		log.Printf("⚠️  config: %s", w)
+	}
+	if err := report.Err(); err != nil {
+		metrics.ConfigLoadErrors.Inc()
+		return nil, nil, err
+	}
+	return cfg, prov, nil
+}
+
+// fileDigest hashes everything a reload reads: the base file, the profile
+// file selected by cfg.Env, the VJAL_* environment and the files named by
+// file: secret references. Missing files hash as empty so that creating a
+// profile file counts as a change.
+func (m *Manager) fileDigest(cfg *AppConfig) [sha256.Size]byte {
+	h := sha256.New()
+	base, err := filepath.Abs(m.opts.Path)
+	if err != nil {
+		base = m.opts.Path
+	}
+	paths := []string{base}
+	if cfg != nil && cfg.Env != "" {
+		paths = append(paths, profilePath(base, cfg.Env))
+	}
+	if raw, _, err := layers(m.opts, &Report{}); err == nil {
+		paths = append(paths, secretFiles(raw)...)
+	}
+	for _, p := range paths {
+		data, _ := os.ReadFile(p)
+		fmt.Fprintf(h, "%s\x00%d\x00", p, len(data))
+		h.Write(data)
+	}
+	env := os.Environ()
+	sort.Strings(env)
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "VJAL_") {
+			fmt.Fprintf(h, "%s\x00", kv)
+		}
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
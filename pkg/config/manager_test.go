@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// managedConfigDir writes baseConfig and the files it references, and
+// returns the config path. Relative paths resolve against the test's cwd.
+func managedConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := writeConfigDir(t, map[string]string{
+		"config.json":  baseConfig,
+		"license.json": `{}`,
+		"schema.json":  `{}`,
+	})
+	t.Chdir(dir)
+	return filepath.Join(dir, "config.json")
+}
+
+func TestManager_ReloadNotifiesSubscribers(t *testing.T) {
+	path := managedConfigDir(t)
+
+	m, err := NewManager(Options{Path: path}, 0)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	var gotOld, gotNew *AppConfig
+	m.Subscribe("test", SubscriberFunc(func(old, new *AppConfig) error {
+		gotOld, gotNew = old, new
+		return nil
+	}))
+
+	updated := `{"licensePath": "license.json", "llmProvider": "echo", "formSchema": "schema.json"}`
+	if err := os.WriteFile(path, []byte(updated), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := m.Reload(); err != nil {
+		t.Fatalf("Reload error: %v", err)
+	}
+
+	if m.Current().LLMProvider != "echo" {
+		t.Errorf("expected new provider echo, got %q", m.Current().LLMProvider)
+	}
+	if gotOld == nil || gotOld.LLMProvider != "openai" || gotNew != m.Current() {
+		t.Errorf("subscriber got old=%+v new=%+v", gotOld, gotNew)
+	}
+}
+
+func TestManager_InvalidReloadKeepsOldConfig(t *testing.T) {
+	path := managedConfigDir(t)
+
+	m, err := NewManager(Options{Path: path}, 0)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	notified := false
+	m.Subscribe("test", SubscriberFunc(func(old, new *AppConfig) error {
+		notified = true
+		return nil
+	}))
+	before := m.Current()
+
+	invalid := []string{
+		`{"env": "staging"}`,
+		`{not json`,
+		`{"licensePath": "missing.json", "llmProvider": "echo", "formSchema": "schema.json"}`,
+	}
+	for _, bad := range invalid {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := m.Reload(); err == nil {
+			t.Errorf("expected reload of %q to fail", bad)
+		}
+	}
+
+	if m.Current() != before {
+		t.Error("expected previous config to stay active after rejected reload")
+	}
+	if notified {
+		t.Error("subscribers must not be notified of rejected reloads")
+	}
+}
+
+func TestManager_FailedSubscriberRollsBack(t *testing.T) {
+	path := managedConfigDir(t)
+
+	m, err := NewManager(Options{Path: path}, 0)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	before := m.Current()
+	var calls []string
+	record := func(name string, fail bool) Subscriber {
+		return SubscriberFunc(func(old, new *AppConfig) error {
+			calls = append(calls, name+":"+old.LLMProvider+"->"+new.LLMProvider)
+			if fail && new.LLMProvider == "echo" {
+				return errors.New("cannot switch")
+			}
+			return nil
+		})
+	}
+	m.Subscribe("first", record("first", false))
+	m.Subscribe("second", record("second", true))
+	m.Subscribe("third", record("third", false))
+
+	updated := `{"licensePath": "license.json", "llmProvider": "echo", "formSchema": "schema.json"}`
+	if err := os.WriteFile(path, []byte(updated), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := m.Reload(); err == nil {
+		t.Fatal("expected the failing subscriber's error")
+	}
+	if m.Current() != before {
+		t.Errorf("expected rollback to the previous config, got provider %q", m.Current().LLMProvider)
+	}
+	want := []string{"first:openai->echo", "second:openai->echo", "second:echo->openai", "first:echo->openai"}
+	if strings.Join(calls, " ") != strings.Join(want, " ") {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestManager_DigestCoversEnvAndSecretFiles(t *testing.T) {
+	path := managedConfigDir(t)
+	secret := filepath.Join(filepath.Dir(path), "openai.key")
+	os.WriteFile(secret, []byte("sk-1\n"), 0o600)
+	t.Setenv("VJAL_LLM_CONFIG_OPENAI_KEY", "file:"+secret)
+
+	m, err := NewManager(Options{Path: path}, 0)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	digest := m.fileDigest(m.Current())
+
+	os.WriteFile(secret, []byte("sk-2\n"), 0o600)
+	if m.fileDigest(m.Current()) == digest {
+		t.Error("a changed secret file should change the digest")
+	}
+	digest = m.fileDigest(m.Current())
+	t.Setenv("VJAL_HTTP_PORT", "9191")
+	if m.fileDigest(m.Current()) == digest {
+		t.Error("a changed VJAL_* variable should change the digest")
+	}
+}
@@ -0,0 +1,262 @@
+// pkg/config/secrets.go
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/adi-ber/vjal-platform/pkg/security"
+)
+
+// redacted replaces secret values wherever a config is printed or dumped.
+const redacted = "[REDACTED]"
+
+// Secret reference prefixes accepted in llmConfig values.
+const (
+	refEnv  = "env:"  // env:NAME reads environment variable NAME
+	refFile = "file:" // file:/run/secrets/x reads a file, trailing newline trimmed
+	refEnc  = "enc:"  // enc:<base64> decrypts with security.Decrypt
+)
+
+// KeySource returns the license key and device ID used to decrypt enc:
+// references. It is only called when such a reference is present, after
+// every layer has been applied.
+type KeySource func(cfg *AppConfig) (licenseKey string, deviceID []byte, err error)
+
+// sensitiveKeyHints mark llmConfig keys whose plain values are redacted too.
+var sensitiveKeyHints = []string{"key", "secret", "token", "password"}
+
+// resolveSecrets replaces every secret reference in cfg.LLMConfig and in the
+// fallback providers' llmConfig with the value it points at, and remembers
+// which keys held secrets. Errors name the field and the reference, never
+// the resolved value.
+func resolveSecrets(cfg *AppConfig, keys KeySource, prov Provenance) error {
+	r := &secretResolver{cfg: cfg, keys: keys, prov: prov}
+	if err := r.resolve(cfg.LLMConfig, "llmConfig."); err != nil {
+		return err
+	}
+	for i, fb := range cfg.LLMFallbacks {
+		if err := r.resolve(fb.Config, fallbackPrefix(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fallbackPrefix is the field path prefix of the i-th fallback's llmConfig.
+func fallbackPrefix(i int) string {
+	return fmt.Sprintf("llmFallbacks[%d].llmConfig.", i)
+}
+
+// secretResolver fetches the license keys at most once per load.
+type secretResolver struct {
+	cfg  *AppConfig
+	keys KeySource
+	prov Provenance
+
+	licenseKey string
+	deviceID   []byte
+	haveKeys   bool
+}
+
+// resolve resolves the references in one settings map whose field paths
+// start with prefix.
+func (r *secretResolver) resolve(settings map[string]string, prefix string) error {
+	for k, v := range settings {
+		path := prefix + k
+		var (
+			val string
+			ref string
+		)
+		switch {
+		case strings.HasPrefix(v, refEnv):
+			name := strings.TrimPrefix(v, refEnv)
+			env, ok := os.LookupEnv(name)
+			if !ok {
+				return fmt.Errorf("%s: environment variable %s is not set", path, name)
+			}
+			val, ref = env, "env"
+		case strings.HasPrefix(v, refFile):
+			file := strings.TrimPrefix(v, refFile)
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("%s: failed to read secret file %s: %w", path, file, err)
+			}
+			val, ref = strings.TrimRight(string(data), "\r\n"), "file"
+		case strings.HasPrefix(v, refEnc):
+			if !r.haveKeys {
+				if r.keys == nil {
+					return fmt.Errorf("%s: enc: secrets need a license key source", path)
+				}
+				var err error
+				if r.licenseKey, r.deviceID, err = r.keys(r.cfg); err != nil {
+					return fmt.Errorf("%s: cannot obtain license keys: %w", path, err)
+				}
+				r.haveKeys = true
+			}
+			data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, refEnc))
+			if err != nil {
+				return fmt.Errorf("%s: enc: secret is not valid base64", path)
+			}
+			plain, err := security.Decrypt(data, r.licenseKey, r.deviceID)
+			if err != nil {
+				return fmt.Errorf("%s: enc: secret could not be decrypted with this license", path)
+			}
+			val, ref = string(plain), "enc"
+		default:
+			continue
+		}
+
+		settings[k] = val
+		if r.cfg.secrets == nil {
+			r.cfg.secrets = make(map[string]bool)
+		}
+		r.cfg.secrets[path] = true
+		o, ok := r.prov[path]
+		if !ok {
+			// Fallback entries are only tracked as a whole.
+			o = r.prov["llmFallbacks"]
+		}
+		o.Ref = ref
+		r.prov[path] = o
+	}
+	return nil
+}
+
+// checkSecrets reports malformed secret references in cfg without resolving
+// them, so a config can be checked away from the environment, files and
+// license its references point at.
+func checkSecrets(r *Report, cfg *AppConfig) {
+	checkRefs(r, cfg.LLMConfig, "llmConfig.")
+	for i, fb := range cfg.LLMFallbacks {
+		checkRefs(r, fb.Config, fallbackPrefix(i))
+	}
+}
+
+func checkRefs(r *Report, settings map[string]string, prefix string) {
+	for _, k := range slices.Sorted(maps.Keys(settings)) {
+		v, path := settings[k], prefix+k
+		switch {
+		case strings.HasPrefix(v, refEnv):
+			if name := strings.TrimPrefix(v, refEnv); name == "" || strings.ContainsAny(name, "= \t") {
+				r.errorf(path, "env: reference needs an environment variable name, got %q", name)
+			}
+		case strings.HasPrefix(v, refFile):
+			if strings.TrimPrefix(v, refFile) == "" {
+				r.errorf(path, "file: reference needs a file path")
+			}
+		case strings.HasPrefix(v, refEnc):
+			if _, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, refEnc)); err != nil {
+				r.errorf(path, "enc: secret is not valid base64")
+			}
+		}
+	}
+}
+
+// secretFiles returns the files that file: references in cfg point at,
+// sorted. cfg must not have been resolved yet.
+func secretFiles(cfg *AppConfig) []string {
+	var files []string
+	add := func(settings map[string]string) {
+		for _, v := range settings {
+			if file, ok := strings.CutPrefix(v, refFile); ok && file != "" {
+				files = append(files, file)
+			}
+		}
+	}
+	add(cfg.LLMConfig)
+	for _, fb := range cfg.LLMFallbacks {
+		add(fb.Config)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// EncryptSecret encrypts plaintext for use as an enc: reference bound to the
+// given license key and device ID.
+func EncryptSecret(plaintext, licenseKey string, deviceID []byte) (string, error) {
+	data, err := security.Encrypt([]byte(plaintext), licenseKey, deviceID)
+	if err != nil {
+		return "", err
+	}
+	return refEnc + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// IsSecret reports whether llmConfig[key] holds a secret: either it was
+// resolved from a reference or its name suggests a credential.
+func (c *AppConfig) IsSecret(key string) bool {
+	return c.isSecret("llmConfig."+key, key)
+}
+
+func (c *AppConfig) isSecret(path, key string) bool {
+	if c.secrets[path] {
+		return true
+	}
+	lk := strings.ToLower(key)
+	for _, hint := range sensitiveKeyHints {
+		if strings.Contains(lk, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy of the config with every secret llmConfig value,
+// including the fallback providers', replaced by a placeholder. Use it for
+// anything that leaves the process.
+func (c *AppConfig) Redacted() *AppConfig {
+	out := *c
+	out.LLMConfig = c.redact(c.LLMConfig, "llmConfig.")
+	if c.LLMFallbacks != nil {
+		out.LLMFallbacks = make([]LLMFallback, len(c.LLMFallbacks))
+		for i, fb := range c.LLMFallbacks {
+			out.LLMFallbacks[i] = LLMFallback{Provider: fb.Provider, Config: c.redact(fb.Config, fallbackPrefix(i))}
+		}
+	}
+	out.secrets = nil
+	return &out
+}
+
+// redact copies one settings map with its secret values replaced.
+func (c *AppConfig) redact(settings map[string]string, prefix string) map[string]string {
+	if settings == nil {
+		return nil
+	}
+	out := make(map[string]string, len(settings))
+	for k, v := range settings {
+		if v != "" && c.isSecret(prefix+k, k) {
+			v = redacted
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// appConfigFields has AppConfig's fields without its methods, so formatting
+// a redacted copy does not recurse.
+type appConfigFields AppConfig
+
+// String formats the config as redacted JSON, which keeps %v and %+v safe.
+func (c AppConfig) String() string {
+	data, err := c.MarshalJSON()
+	if err != nil {
+		return fmt.Sprintf("<config: %v>", err)
+	}
+	return string(data)
+}
+
+// GoString keeps %#v redacted as well.
+func (c AppConfig) GoString() string {
+	return c.String()
+}
+
+// MarshalJSON dumps the config with secrets redacted. The output is meant for
+// display and is not a substitute for the original config file.
+func (c AppConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(appConfigFields(*c.Redacted()))
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func secretConfig(llmConfig string) string {
+	return `{
+		"licensePath": "license.json",
+		"llmProvider": "openai",
+		"formSchema": "schema.json",
+		"llmConfig": ` + llmConfig + `
+	}`
+}
+
+func TestLoad_SecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "org")
+	if err := os.WriteFile(secretFile, []byte("org-from-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+	enc, err := EncryptSecret("enc-secret", "LIC", []byte("DEV"))
+	if err != nil {
+		t.Fatalf("EncryptSecret error: %v", err)
+	}
+	t.Setenv("TEST_OPENAI_KEY", "env-secret")
+
+	path := writeConfigDir(t, map[string]string{"config.json": secretConfig(fmt.Sprintf(
+		`{"openai_key": "env:TEST_OPENAI_KEY", "org": "file:%s", "proxy": %q, "model": "gpt-4o"}`,
+		secretFile, enc,
+	))})
+	keys := func(*AppConfig) (string, []byte, error) { return "LIC", []byte("DEV"), nil }
+
+	cfg, prov, err := LoadLayered(Options{Path: filepath.Join(path, "config.json"), Keys: keys})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := map[string]string{
+		"openai_key": "env-secret",
+		"org":        "org-from-file",
+		"proxy":      "enc-secret",
+		"model":      "gpt-4o",
+	}
+	for k, v := range want {
+		if cfg.LLMConfig[k] != v {
+			t.Errorf("llmConfig.%s: expected %q, got %q", k, v, cfg.LLMConfig[k])
+		}
+	}
+	if prov["llmConfig.proxy"].Ref != "enc" {
+		t.Errorf("expected enc ref in provenance, got %+v", prov["llmConfig.proxy"])
+	}
+
+	dumped, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, out := range []string{cfg.String(), fmt.Sprintf("%v", cfg), fmt.Sprintf("%+v", *cfg), fmt.Sprintf("%#v", cfg), string(dumped), prov.String()} {
+		for _, secret := range []string{"env-secret", "org-from-file", "enc-secret"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("secret %q leaked into %s", secret, out)
+			}
+		}
+	}
+	if !strings.Contains(string(dumped), "gpt-4o") {
+		t.Errorf("expected non-secret values in dump, got %s", dumped)
+	}
+}
+
+func TestLoad_FallbackSecrets(t *testing.T) {
+	t.Setenv("TEST_BACKUP_URL", "http://user:pw@backup")
+	path := writeConfigDir(t, map[string]string{"config.json": `{
+		"licensePath": "license.json",
+		"llmProvider": "openai",
+		"formSchema": "schema.json",
+		"llmConfig": {"openai_key": "primary-key"},
+		"llmFallbacks": [
+			{"provider": "openai", "llmConfig": {"openai_key": "backup-key", "base_url": "env:TEST_BACKUP_URL"}},
+			{"provider": "offline"}
+		]
+	}`})
+
+	cfg, prov, err := LoadLayered(Options{Path: filepath.Join(path, "config.json")})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.LLMFallbacks) != 2 || cfg.LLMFallbacks[0].Config["base_url"] != "http://user:pw@backup" || cfg.LLMFallbacks[1].Provider != "offline" {
+		t.Fatalf("fallbacks not loaded: %+v", cfg.LLMFallbacks)
+	}
+	if o := prov["llmFallbacks[0].llmConfig.base_url"]; o.Source != SourceFile || o.Ref != "env" {
+		t.Errorf("expected file origin with env ref, got %+v", o)
+	}
+	dumped := cfg.String()
+	for _, secret := range []string{"primary-key", "backup-key", "user:pw"} {
+		if strings.Contains(dumped, secret) {
+			t.Errorf("secret %q leaked into %s", secret, dumped)
+		}
+	}
+	if cfg.LLMFallbacks[0].Config["openai_key"] != "backup-key" {
+		t.Error("redacting must not change the loaded config")
+	}
+}
+
+func TestLoad_SecretErrorsDoNotLeak(t *testing.T) {
+	enc, err := EncryptSecret("top-secret", "LIC", nil)
+	if err != nil {
+		t.Fatalf("EncryptSecret error: %v", err)
+	}
+	dir := writeConfigDir(t, map[string]string{"config.json": secretConfig(fmt.Sprintf(`{"openai_key": %q}`, enc))})
+	wrongKeys := func(*AppConfig) (string, []byte, error) { return "OTHER", nil, nil }
+
+	_, _, err = LoadLayered(Options{Path: filepath.Join(dir, "config.json"), Keys: wrongKeys})
+	if err == nil {
+		t.Fatal("expected decryption error with wrong license key, got nil")
+	}
+	if !strings.Contains(err.Error(), "llmConfig.openai_key") || strings.Contains(err.Error(), "top-secret") {
+		t.Errorf("unexpected error text: %v", err)
+	}
+
+	failing := func(*AppConfig) (string, []byte, error) { return "", nil, errors.New("no license") }
+	if _, _, err := LoadLayered(Options{Path: filepath.Join(dir, "config.json"), Keys: failing}); err == nil {
+		t.Fatal("expected error when license keys are unavailable, got nil")
+	}
+}
+
+func TestLoad_MissingEnvSecret(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{"config.json": secretConfig(`{"openai_key": "env:VJAL_TEST_UNSET_SECRET"}`)})
+	if _, _, err := LoadLayered(Options{Path: filepath.Join(dir, "config.json")}); err == nil {
+		t.Fatal("expected error for unset env secret, got nil")
+	}
+}
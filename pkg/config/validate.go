@@ -0,0 +1,285 @@
+// pkg/config/validate.go
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Problem is a single validation finding, addressed by its field path
+// (e.g. "llmConfig.openai_key").
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// Report collects every error and warning found in a config.
+type Report struct {
+	Errors   []Problem
+	Warnings []Problem
+}
+
+// OK reports whether the config has no errors. Warnings do not count.
+func (r *Report) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// Err returns a *ValidationError holding every error, or nil.
+func (r *Report) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return &ValidationError{Problems: r.Errors}
+}
+
+func (r *Report) errorf(path, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) warnf(path, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidationError lists every problem that made a config invalid.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// ProviderSpec describes what an LLM provider needs from llmConfig.
+type ProviderSpec struct {
+	Required    []string          // llmConfig keys that must be non-empty
+	EnvFallback map[string]string // required key → env var accepted in its place
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]ProviderSpec)
+
+	failoverClasses []string
+)
+
+// RegisterProvider declares an LLM provider name as valid for llmProvider.
+// pkg/llm registers its providers at init; until something registers, the
+// provider name is not checked.
+func RegisterProvider(name string, spec ProviderSpec) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = spec
+}
+
+// Providers returns the registered provider names, sorted.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterFailoverClasses declares the error classes valid in
+// llmFailoverOn. Like providers, they are not checked until registered.
+func RegisterFailoverClasses(classes ...string) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	failoverClasses = append(failoverClasses, classes...)
+}
+
+// FailoverClasses returns the registered error classes.
+func FailoverClasses() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return slices.Clone(failoverClasses)
+}
+
+func lookupProvider(name string) (ProviderSpec, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	spec, ok := providers[name]
+	return spec, ok
+}
+
+// knownEnvs are the env values the code base distinguishes.
+var knownEnvs = []string{"development", "production"}
+
+// Validate checks cfg as a whole and reports every problem it finds: missing
+// required fields, files that do not exist, a bad adminAddr, unsupported
+// providers in llmProvider or llmFallbacks, missing provider settings and
+// unknown llmFailoverOn classes.
+func Validate(cfg *AppConfig) *Report {
+	r := &Report{}
+
+	checkFile(r, "licensePath", cfg.LicensePath)
+	checkFile(r, "formSchema", cfg.FormSchema)
+
+	if cfg.HTTPPort < 1 || cfg.HTTPPort > 65535 {
+		r.errorf("httpPort", "must be between 1 and 65535, got %d", cfg.HTTPPort)
+	}
+
+	if cfg.AdminAddr != "" {
+		if host, _, err := net.SplitHostPort(cfg.AdminAddr); err != nil {
+			r.errorf("adminAddr", "invalid listen address %q: %v", cfg.AdminAddr, err)
+		} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			r.warnf("adminAddr", "%q is reachable from other hosts; the /admin endpoints have no authentication", cfg.AdminAddr)
+		}
+	}
+
+	if cfg.Env != "" && !slices.Contains(knownEnvs, cfg.Env) {
+		r.warnf("env", "unrecognised env %q (expected one of %s)", cfg.Env, strings.Join(knownEnvs, ", "))
+	}
+
+	if cfg.LLMProvider == "" {
+		r.errorf("llmProvider", "is required")
+	} else {
+		checkProvider(r, "llmProvider", "llmConfig.", cfg.LLMProvider, cfg.LLMConfig)
+	}
+	for i, fb := range cfg.LLMFallbacks {
+		path := fmt.Sprintf("llmFallbacks[%d]", i)
+		if fb.Provider == "" {
+			r.errorf(path+".provider", "is required")
+			continue
+		}
+		checkProvider(r, path+".provider", fallbackPrefix(i), fb.Provider, fb.Config)
+	}
+	for _, class := range cfg.LLMFailoverOn {
+		if known := FailoverClasses(); len(known) > 0 && !slices.Contains(known, class) {
+			r.errorf("llmFailoverOn", "unknown error class %q (known: %s)", class, strings.Join(known, ", "))
+		}
+	}
+
+	for key, p := range cfg.LLMPrices {
+		if p.Prompt < 0 || p.Completion < 0 {
+			r.errorf("llmPrices."+key, "prices must not be negative")
+		}
+	}
+	if cfg.LLMDailyBudget < 0 {
+		r.errorf("llmDailyBudget", "must not be negative")
+	}
+	if cfg.LLMMonthlyBudget < 0 {
+		r.errorf("llmMonthlyBudget", "must not be negative")
+	}
+
+	checkURL(r, "metricsEndpoint", cfg.MetricsEndpoint)
+	checkURL(r, "licenseServer", cfg.LicenseServer)
+	return r
+}
+
+// checkProvider reports an unsupported provider name at path and any
+// setting the provider requires that is missing from settings, whose field
+// paths start with prefix.
+func checkProvider(r *Report, path, prefix, name string, settings map[string]string) {
+	spec, ok := lookupProvider(name)
+	if !ok {
+		if len(Providers()) > 0 {
+			r.errorf(path, "unsupported provider %q (supported: %s)", name, strings.Join(Providers(), ", "))
+		}
+		return
+	}
+	for _, key := range spec.Required {
+		if settings[key] != "" {
+			continue
+		}
+		if env := spec.EnvFallback[key]; env != "" {
+			if os.Getenv(env) != "" {
+				continue
+			}
+			r.errorf(prefix+key, "is required by provider %q (or set %s)", name, env)
+			continue
+		}
+		r.errorf(prefix+key, "is required by provider %q", name)
+	}
+}
+
+// checkURL reports value unless it is empty or an http(s) URL.
+func checkURL(r *Report, path, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		r.errorf(path, "must be an http(s) URL, got %q", value)
+	}
+}
+
+// Check loads opts like LoadLayered and validates the result, reporting
+// every problem rather than stopping at the first. Secret references are
+// checked for form but not resolved. Unknown keys in the config files are
+// reported as warnings. A config file that cannot be read at all yields a
+// single error.
+func Check(opts Options) *Report {
+	r := &Report{}
+	cfg, _, err := layers(opts, r)
+	if err != nil {
+		return &Report{Errors: []Problem{{Path: opts.Path, Message: err.Error()}}}
+	}
+	checkSecrets(r, cfg)
+	v := Validate(cfg)
+	r.Errors = append(r.Errors, v.Errors...)
+	r.Warnings = append(r.Warnings, v.Warnings...)
+	return r
+}
+
+func checkFile(r *Report, path, file string) {
+	if file == "" {
+		r.errorf(path, "is required")
+		return
+	}
+	info, err := os.Stat(file)
+	switch {
+	case os.IsNotExist(err):
+		r.errorf(path, "file %s does not exist", file)
+	case err != nil:
+		r.errorf(path, "cannot stat %s: %v", file, err)
+	case info.IsDir():
+		r.errorf(path, "%s is a directory, expected a file", file)
+	}
+}
+
+// jsonKeys lists the JSON keys of AppConfig's exported fields.
+var jsonKeys = func() []string {
+	var keys []string
+	t := reflect.TypeOf(AppConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}()
+
+// unknownKey returns a warning when a config file key does not exactly match
+// an AppConfig field. encoding/json matches case-insensitively, so a near
+// miss such as "httpport" is flagged with the intended spelling.
+func unknownKey(key, file string) (Problem, bool) {
+	if slices.Contains(jsonKeys, key) {
+		return Problem{}, false
+	}
+	for _, k := range jsonKeys {
+		if strings.EqualFold(k, key) {
+			return Problem{Path: key, Message: fmt.Sprintf("unknown key in %s (did you mean %q?)", file, k)}, true
+		}
+	}
+	return Problem{Path: key, Message: fmt.Sprintf("unknown key in %s is ignored", file)}, true
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func problemPaths(ps []Problem) map[string]string {
+	out := make(map[string]string, len(ps))
+	for _, p := range ps {
+		out[p.Path] = p.Message
+	}
+	return out
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	RegisterProvider("test-provider", ProviderSpec{Required: []string{"endpoint"}})
+
+	cfg := &AppConfig{
+		HTTPPort:        70000,
+		AdminAddr:       "8081",
+		LicensePath:     filepath.Join(t.TempDir(), "missing-license.json"),
+		LLMProvider:     "test-provider",
+		LLMConfig:       map[string]string{},
+		MetricsEndpoint: "not a url",
+	}
+	report := Validate(cfg)
+	errs := problemPaths(report.Errors)
+	for _, path := range []string{"licensePath", "formSchema", "httpPort", "adminAddr", "llmConfig.endpoint", "metricsEndpoint"} {
+		if _, ok := errs[path]; !ok {
+			t.Errorf("expected an error for %s, got %v", path, report.Errors)
+		}
+	}
+
+	var verr *ValidationError
+	if err := report.Err(); !errors.As(err, &verr) || len(verr.Problems) != len(report.Errors) {
+		t.Errorf("expected ValidationError with every problem, got %v", err)
+	}
+
+	cfg.LLMFallbacks = []LLMFallback{{Provider: "test-provider"}, {Provider: "no-such"}, {}}
+	cfg.LLMFailoverOn = []string{"sometimes"}
+	RegisterFailoverClasses("server")
+	errs = problemPaths(Validate(cfg).Errors)
+	for _, path := range []string{"llmFallbacks[0].llmConfig.endpoint", "llmFallbacks[1].provider", "llmFallbacks[2].provider", "llmFailoverOn"} {
+		if _, ok := errs[path]; !ok {
+			t.Errorf("expected an error for %s, got %v", path, errs)
+		}
+	}
+
+	cfg.LLMProvider = "no-such"
+	if msg := problemPaths(Validate(cfg).Errors)["llmProvider"]; !strings.Contains(msg, "unsupported") {
+		t.Errorf("expected unsupported provider error, got %q", msg)
+	}
+}
+
+func TestCheck_UnknownKeysWarn(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"config.json": `{
+			"httpport": 9000,
+			"licencePath": "license.json",
+			"licensePath": "license.json",
+			"llmProvider": "echo",
+			"formSchema": "schema.json"
+		}`,
+		"license.json": `{}`,
+		"schema.json":  `{}`,
+	})
+	t.Chdir(dir)
+	RegisterProvider("echo", ProviderSpec{})
+
+	report := Check(Options{Path: "config.json"})
+	if !report.OK() {
+		t.Fatalf("expected no errors, got %v", report.Errors)
+	}
+	warns := problemPaths(report.Warnings)
+	if msg := warns["httpport"]; !strings.Contains(msg, `"httpPort"`) {
+		t.Errorf("expected did-you-mean warning for httpport, got %q", msg)
+	}
+	if _, ok := warns["licencePath"]; !ok {
+		t.Errorf("expected warning for licencePath, got %v", report.Warnings)
+	}
+}
+
+func TestCheck_CollectsProblemsWithoutResolvingSecrets(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"config.json": `{
+			"licensePath": "license.json",
+			"llmProvider": "echo",
+			"formSchema": "missing.json",
+			"llmConfig": {"api_key": "env:VJAL_TEST_UNSET_SECRET", "token": "enc:not base64!", "path": "file:"},
+			"llmFallbacks": [{"provider": "echo", "llmConfig": {"api_key": "env:"}}]
+		}`,
+		"license.json": `{}`,
+	})
+	t.Chdir(dir)
+	t.Setenv("VJAL_HTTP_PORT", "eighty")
+	t.Setenv("VJAL_LLM_CACHE_TTL", "soon")
+	RegisterProvider("echo", ProviderSpec{})
+
+	report := Check(Options{Path: "config.json"})
+	errs := problemPaths(report.Errors)
+	for _, path := range []string{
+		"VJAL_HTTP_PORT", "VJAL_LLM_CACHE_TTL", "formSchema",
+		"llmConfig.token", "llmConfig.path", "llmFallbacks[0].llmConfig.api_key",
+	} {
+		if _, ok := errs[path]; !ok {
+			t.Errorf("expected an error for %s, got %v", path, report.Errors)
+		}
+	}
+	if msg, ok := errs["llmConfig.api_key"]; ok {
+		t.Errorf("well-formed reference to an unset variable reported: %s", msg)
+	}
+}
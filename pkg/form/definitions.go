@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+
+	"github.com/adi-ber/vjal-platform/pkg/pii"
 )
 
 // PromptField describes one input in your form.
@@ -18,6 +20,18 @@ type PromptField struct {
 	LLMValidation *LLMValidation `json:"llmValidation,omitempty"` // optional LLM‑based check
 	Options       []string       `json:"options,omitempty"`       // for selects/radios
 	Placeholder   string         `json:"placeholder,omitempty"`   // optional placeholder text
+	Sensitivity   pii.Class      `json:"sensitivity,omitempty"`   // "pii" or "secret": masked, sealed, never audited
+}
+
+// SensitiveFields returns the fields marked pii or secret, by ID.
+func SensitiveFields(fields []PromptField) pii.Fields {
+	out := make(pii.Fields)
+	for _, f := range fields {
+		if f.Sensitivity != pii.None {
+			out[f.ID] = f.Sensitivity
+		}
+	}
+	return out
 }
 
 // Validations holds basic client/server rules.
@@ -78,6 +92,15 @@ func LoadDefinitionsDir(dir string) (FormDefinitions, error) {
 				log.Printf("⚠️  duplicate form key %q in %s – skipping", key, path)
 				continue
 			}
+			for i, f := range fields {
+				c, err := pii.ParseClass(string(f.Sensitivity))
+				if err != nil {
+					// Unknown markings are treated as secret rather than as not sensitive.
+					log.Printf("⚠️  field %s.%s in %s: %v – treating as secret", key, f.ID, path, err)
+					c = pii.Secret
+				}
+				fields[i].Sensitivity = c
+			}
 			defs[key] = fields
 		}
 	}
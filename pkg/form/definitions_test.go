@@ -0,0 +1,30 @@
+package form
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adi-ber/vjal-platform/pkg/pii"
+)
+
+func TestLoadDefinitionsDir_Sensitivity(t *testing.T) {
+	dir := t.TempDir()
+	def := `{"payroll": [
+  {"id": "name", "label": "Name", "type": "text", "sensitivity": "pii"},
+  {"id": "token", "label": "Token", "type": "text", "sensitivity": "confidential"},
+  {"id": "dept", "label": "Department", "type": "text"}
+]}`
+	if err := os.WriteFile(filepath.Join(dir, "payroll.json"), []byte(def), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defs, err := LoadDefinitionsDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDefinitionsDir: %v", err)
+	}
+	got := SensitiveFields(defs["payroll"])
+	want := pii.Fields{"name": pii.PII, "token": pii.Secret}
+	if len(got) != len(want) || got["name"] != want["name"] || got["token"] != want["token"] {
+		t.Errorf("SensitiveFields = %v, want %v", got, want)
+	}
+}
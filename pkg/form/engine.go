@@ -9,6 +9,7 @@ import (
 
 	"github.com/adi-ber/vjal-platform/pkg/storage"
 	"github.com/adi-ber/vjal-platform/pkg/metrics"
+	"github.com/adi-ber/vjal-platform/pkg/pii"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -18,6 +19,10 @@ type Form struct {
 	Values map[string]interface{} // in-memory merged values
 	store  *storage.Store         // storage backend for state
 	formID string                 // namespace for persisted state
+
+	// Sensitive lists fields whose values are sealed individually in
+	// stored state; see SensitiveFields.
+	Sensitive pii.Fields
 }
 
 // New loads the form schema, attaches storage under formID, and registers metrics.
@@ -69,8 +74,9 @@ func (f *Form) NextPage(currentPageID string) (string, error) {
 }
 
 // SaveState persists the input map for the given pageID and updates metrics.
+// Sensitive fields are sealed on their own.
 func (f *Form) SaveState(ctx context.Context, pageID string, input map[string]interface{}) error {
-	if err := f.store.Save(f.formID, pageID, input); err != nil {
+	if err := f.store.SaveFields(f.formID, pageID, input, f.Sensitive.IDs()); err != nil {
 		return fmt.Errorf("failed to save state: %w", err)
 	}
 	return nil
@@ -78,8 +84,8 @@ func (f *Form) SaveState(ctx context.Context, pageID string, input map[string]in
 
 // LoadState retrieves the persisted input map for pageID and updates metrics.
 func (f *Form) LoadState(ctx context.Context, pageID string) (map[string]interface{}, error) {
-	data := make(map[string]interface{})
-	if err := f.store.Load(f.formID, pageID, &data); err != nil {
+	data, err := f.store.LoadFields(f.formID, pageID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to load state: %w", err)
 	}
 	// Merge into in-memory Values
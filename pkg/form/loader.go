@@ -0,0 +1,43 @@
+package form
+
+import (
+	"sync/atomic"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+// DefinitionsLoader keeps the definitions from one directory in memory and
+// reloads them on demand, e.g. when the app config changes.
+type DefinitionsLoader struct {
+	dir  string
+	defs atomic.Pointer[FormDefinitions]
+}
+
+// NewDefinitionsLoader loads dir once and returns a loader for it.
+func NewDefinitionsLoader(dir string) (*DefinitionsLoader, error) {
+	l := &DefinitionsLoader{dir: dir}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Definitions returns the most recently loaded definitions. Treat the map as read-only.
+func (l *DefinitionsLoader) Definitions() FormDefinitions {
+	return *l.defs.Load()
+}
+
+// Reload re-reads the directory. On error the previous definitions are kept.
+func (l *DefinitionsLoader) Reload() error {
+	defs, err := LoadDefinitionsDir(l.dir)
+	if err != nil {
+		return err
+	}
+	l.defs.Store(&defs)
+	return nil
+}
+
+// OnConfigChange implements config.Subscriber.
+func (l *DefinitionsLoader) OnConfigChange(old, new *config.AppConfig) error {
+	return l.Reload()
+}
@@ -0,0 +1,11 @@
+//go:build devkeys
+
+// pkg/license/devkeys.go
+package license
+
+// The development issuer key signs the bundled license.json. It is compiled
+// in only with -tags devkeys, so a release binary never trusts it, whatever
+// its config says. Its private half is not kept in the repository.
+func init() {
+	issuerKeys["vjal-dev-1"] = embeddedKey{pub: "9bYly10o0xM9nov8MTk7dH8HUYE/efoDu5qButGPEt4=", devOnly: true}
+}
@@ -0,0 +1,22 @@
+//go:build devkeys
+
+package license
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+func TestDefaultKeyring_VerifiesBundledLicense(t *testing.T) {
+	cfg := config.AppConfig{LicensePath: "../../license.json"}
+	if _, err := NewValidator(&cfg).Validate(context.Background()); err != nil {
+		t.Fatalf("expected bundled license.json to verify against embedded keys, got %v", err)
+	}
+
+	cfg.Env = "production"
+	if _, err := NewValidator(&cfg).Validate(context.Background()); err == nil {
+		t.Error("expected the development key to be refused in production")
+	}
+}
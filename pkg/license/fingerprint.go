@@ -0,0 +1,153 @@
+// pkg/license/fingerprint.go
+package license
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fingerprint sources understood by SystemFingerprint.
+const (
+	SourceMachineID = "machine-id" // /etc/machine-id (or the dbus copy)
+	SourceHostname  = "hostname"   // kernel hostname
+	SourceMAC       = "mac"        // MAC of the default-route interface
+)
+
+// DefaultSources is used when the config does not list deviceSources.
+var DefaultSources = []string{SourceMachineID, SourceHostname, SourceMAC}
+
+// fingerprintContext versions the derivation so IDs can change format later.
+const fingerprintContext = "vjal-device-v1\n"
+
+// Fingerprinter derives the ID of the device the app runs on.
+type Fingerprinter interface {
+	Fingerprint() (string, error)
+}
+
+// StaticFingerprint is a fixed device ID, for tests and tools that need to
+// act on behalf of a known device.
+type StaticFingerprint string
+
+// Fingerprint returns the fixed ID.
+func (s StaticFingerprint) Fingerprint() (string, error) {
+	return string(s), nil
+}
+
+// SystemFingerprint derives a stable device ID on Linux by hashing the
+// configured host identifiers. Every listed source must be readable, so the
+// ID never silently changes because one source went missing.
+type SystemFingerprint struct {
+	Sources []string // defaults to DefaultSources
+	Root    string   // filesystem root for file-based sources; "" means "/"
+}
+
+// Fingerprint returns the hex-encoded device ID.
+func (s SystemFingerprint) Fingerprint() (string, error) {
+	sources := s.Sources
+	if len(sources) == 0 {
+		sources = DefaultSources
+	}
+	sorted := append([]string(nil), sources...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	h.Write([]byte(fingerprintContext))
+	for _, src := range sorted {
+		v, err := s.read(src)
+		if err != nil {
+			return "", fmt.Errorf("device fingerprint source %s: %w", src, err)
+		}
+		fmt.Fprintf(h, "%s=%s\n", src, v)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32], nil
+}
+
+func (s SystemFingerprint) read(src string) (string, error) {
+	switch src {
+	case SourceMachineID:
+		for _, p := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+			data, err := os.ReadFile(s.path(p))
+			if id := strings.TrimSpace(string(data)); err == nil && id != "" {
+				return id, nil
+			}
+		}
+		return "", fmt.Errorf("no machine-id found")
+	case SourceHostname:
+		return os.Hostname()
+	case SourceMAC:
+		return s.primaryMAC()
+	default:
+		return "", fmt.Errorf("unknown source (valid: %s)", strings.Join(DefaultSources, ", "))
+	}
+}
+
+func (s SystemFingerprint) path(p string) string {
+	if s.Root == "" {
+		return p
+	}
+	return filepath.Join(s.Root, p)
+}
+
+// primaryMAC returns the hardware address of the interface carrying the
+// default route, falling back to the first non-loopback interface by name.
+func (s SystemFingerprint) primaryMAC() (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	byName := make(map[string]net.Interface, len(ifaces))
+	var names []string
+	for _, ifc := range ifaces {
+		if ifc.Flags&net.FlagLoopback != 0 || len(ifc.HardwareAddr) == 0 {
+			continue
+		}
+		byName[ifc.Name] = ifc
+		names = append(names, ifc.Name)
+	}
+	if name := s.defaultRouteInterface(); name != "" {
+		if ifc, ok := byName[name]; ok {
+			return ifc.HardwareAddr.String(), nil
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no network interface with a hardware address")
+	}
+	sort.Strings(names)
+	return byName[names[0]].HardwareAddr.String(), nil
+}
+
+// defaultRouteInterface reads /proc/net/route for the interface whose
+// destination is 0.0.0.0. It returns "" when that cannot be determined.
+func (s SystemFingerprint) defaultRouteInterface() string {
+	f, err := os.Open(s.path("/proc/net/route"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		cols := strings.Fields(sc.Text())
+		if len(cols) > 1 && cols[1] == "00000000" {
+			return cols[0]
+		}
+	}
+	return ""
+}
+
+// DeviceMismatchError reports a license bound to a different device.
+type DeviceMismatchError struct {
+	Licensed string // DeviceID in the license
+	Actual   string // fingerprint of this device
+}
+
+func (e *DeviceMismatchError) Error() string {
+	return fmt.Sprintf("license is bound to device %s, but this device is %s", e.Licensed, e.Actual)
+}
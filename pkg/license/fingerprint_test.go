@@ -0,0 +1,77 @@
+package license
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+func writeMachineID(t *testing.T, root, id string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc", "machine-id"), []byte(id+"\n"), 0o644); err != nil {
+		t.Fatalf("write machine-id: %v", err)
+	}
+}
+
+func TestSystemFingerprint_StableAndSourceDependent(t *testing.T) {
+	root := t.TempDir()
+	writeMachineID(t, root, "0123456789abcdef")
+	fp := SystemFingerprint{Sources: []string{SourceMachineID}, Root: root}
+
+	first, err := fp.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint error: %v", err)
+	}
+	second, _ := fp.Fingerprint()
+	if first != second || len(first) != 32 {
+		t.Errorf("expected stable 32-char ID, got %q and %q", first, second)
+	}
+
+	writeMachineID(t, root, "fedcba9876543210")
+	changed, _ := fp.Fingerprint()
+	if changed == first {
+		t.Error("expected a different machine-id to change the fingerprint")
+	}
+}
+
+func TestSystemFingerprint_MissingOrUnknownSource(t *testing.T) {
+	root := t.TempDir()
+	if _, err := (SystemFingerprint{Sources: []string{SourceMachineID}, Root: root}).Fingerprint(); err == nil {
+		t.Error("expected error when machine-id is missing, got nil")
+	}
+	if _, err := (SystemFingerprint{Sources: []string{"serial"}, Root: root}).Fingerprint(); err == nil {
+		t.Error("expected error for unknown source, got nil")
+	}
+}
+
+func TestValidate_DeviceBinding(t *testing.T) {
+	jsonData := signedLicenseJSON(t, License{
+		Key: "BOUND", Expires: time.Now().Add(24 * time.Hour), Features: []string{"a"}, DeviceID: "device-a",
+	})
+	path := writeTempLicenseFile(t, jsonData)
+	defer os.Remove(path)
+	cfg := config.AppConfig{LicensePath: path}
+
+	same := NewValidator(&cfg, WithKeyring(testKeyring), WithFingerprinter(StaticFingerprint("device-a")))
+	if _, err := same.Validate(context.Background()); err != nil {
+		t.Fatalf("expected bound license to validate on its device, got %v", err)
+	}
+
+	other := NewValidator(&cfg, WithKeyring(testKeyring), WithFingerprinter(StaticFingerprint("device-b")))
+	_, err := other.Validate(context.Background())
+	var mismatch *DeviceMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Actual != "device-b" {
+		t.Fatalf("expected DeviceMismatchError, got %v", err)
+	}
+	if other.CheckFeature("a") {
+		t.Error("expected no features on a different device")
+	}
+}
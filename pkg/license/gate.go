@@ -0,0 +1,157 @@
+// pkg/license/gate.go
+package license
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/adi-ber/vjal-platform/pkg/problem"
+)
+
+// License features known to the platform.
+const (
+	FeatureCloudLLM     = "cloud_llm"     // hosted LLM providers
+	FeatureOfflineLLM   = "offline_llm"   // the bundled offline model
+	FeatureCVAnalysis   = "cv_analysis"   // CV and résumé prompts
+	FeatureAccounting   = "accounting"    // accounting prompts
+	FeaturePDFExport    = "pdf_export"    // PDF output
+	FeatureDynamicForms = "dynamic_forms" // LLM-driven multi-round forms
+)
+
+// DefaultGate declares, in one place, which feature unlocks each provider,
+// prompt key, output format and route. Anything not listed is ungated.
+var DefaultGate = Gate{
+	Providers: map[string]string{
+		"openai":  FeatureCloudLLM,
+		"offline": FeatureOfflineLLM,
+	},
+	PromptKeys: map[string]string{
+		"accountingClassifier": FeatureAccounting,
+		"cvAnalysis":           FeatureCVAnalysis,
+	},
+	Formats: map[string]string{
+		"pdf": FeaturePDFExport,
+	},
+	Routes: map[string]string{
+		"/dynamic":         FeatureDynamicForms,
+		"/dynamic-submit":  FeatureDynamicForms,
+		"/dynamic-submit/": FeatureDynamicForms, // and its sub-routes, e.g. /stream
+	},
+}
+
+// Checker reports whether a feature is granted. *License, *Validator and
+// *Manager all implement it.
+type Checker interface {
+	CheckFeature(feature string) bool
+}
+
+// Gate maps names to the license feature each one requires. Route entries
+// ending in "/" match every path below them.
+type Gate struct {
+	Providers  map[string]string // llmProvider → feature
+	PromptKeys map[string]string // prompt key → feature
+	Formats    map[string]string // output format → feature
+	Routes     map[string]string // URL path → feature
+
+	// OnAllow, if set, is called with the feature each time a prompt key,
+	// format or route check passes on a gated name, e.g. to count feature
+	// use per request. Provider checks happen at client construction and are
+	// not reported.
+	OnAllow func(feature string)
+}
+
+// FeatureError reports something the license does not unlock.
+type FeatureError struct {
+	Kind    string // "provider", "prompt key", "format" or "route"
+	Name    string
+	Feature string
+}
+
+func (e *FeatureError) Error() string {
+	return fmt.Sprintf("%s %q requires license feature %q", e.Kind, e.Name, e.Feature)
+}
+
+// Provider checks that c unlocks the LLM provider name.
+func (g Gate) Provider(c Checker, name string) error {
+	return check(c, "provider", name, g.Providers[name])
+}
+
+// PromptKey checks that c unlocks the prompt key.
+func (g Gate) PromptKey(c Checker, key string) error {
+	return g.allow(check(c, "prompt key", key, g.PromptKeys[key]), g.PromptKeys[key])
+}
+
+// Format checks that c unlocks the output format.
+func (g Gate) Format(c Checker, format string) error {
+	return g.allow(check(c, "format", format, g.Formats[format]), g.Formats[format])
+}
+
+// Route checks that c unlocks the URL path.
+func (g Gate) Route(c Checker, path string) error {
+	feature := g.routeFeature(path)
+	return g.allow(check(c, "route", path, feature), feature)
+}
+
+func (g Gate) routeFeature(path string) string {
+	if f, ok := g.Routes[path]; ok {
+		return f
+	}
+	best, feature := 0, ""
+	for prefix, f := range g.Routes {
+		if strings.HasSuffix(prefix, "/") && strings.HasPrefix(path, prefix) && len(prefix) > best {
+			best, feature = len(prefix), f
+		}
+	}
+	return feature
+}
+
+func check(c Checker, kind, name, feature string) error {
+	if feature == "" || (c != nil && c.CheckFeature(feature)) {
+		return nil
+	}
+	return &FeatureError{Kind: kind, Name: name, Feature: feature}
+}
+
+// allow reports a passed check on a gated feature to OnAllow.
+func (g Gate) allow(err error, feature string) error {
+	if err == nil && feature != "" && g.OnAllow != nil {
+		g.OnAllow(feature)
+	}
+	return err
+}
+
+// Middleware rejects requests to gated routes that c does not unlock.
+func (g Gate) Middleware(c Checker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := g.Route(c, r.URL.Path); err != nil {
+			WriteProblem(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteProblem answers 403 Forbidden with an application/problem+json body
+// describing err, which is usually a *FeatureError.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	d := problem.Details{
+		Type:     "urn:vjal:problem:license",
+		Title:    "Not permitted by license",
+		Status:   http.StatusForbidden,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+	}
+	if fe, ok := err.(*FeatureError); ok {
+		d.Type = "urn:vjal:problem:feature-not-licensed"
+		d.Title = "Feature not licensed"
+		d.Extensions = map[string]interface{}{"feature": fe.Feature}
+	}
+	problem.Write(w, d)
+}
+
+// CheckFeature reports whether l grants feature. A nil License grants nothing.
+func (l *License) CheckFeature(feature string) bool {
+	return l != nil && slices.Contains(l.Features, feature)
+}
@@ -0,0 +1,77 @@
+package license
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGate_Checks(t *testing.T) {
+	g := Gate{
+		Providers:  map[string]string{"cloud": "cloud_llm"},
+		PromptKeys: map[string]string{"cv": "cv_analysis"},
+		Formats:    map[string]string{"pdf": "pdf_export"},
+		Routes:     map[string]string{"/admin/": "admin"},
+	}
+	lic := &License{Features: []string{"cloud_llm"}}
+
+	if err := g.Provider(lic, "cloud"); err != nil {
+		t.Errorf("expected licensed provider to pass, got %v", err)
+	}
+	if err := g.Provider(lic, "echo"); err != nil {
+		t.Errorf("expected ungated provider to pass, got %v", err)
+	}
+	var fe *FeatureError
+	if err := g.PromptKey(lic, "cv"); !errors.As(err, &fe) || fe.Feature != "cv_analysis" {
+		t.Errorf("expected FeatureError for cv_analysis, got %v", err)
+	}
+	if err := g.Format(nil, "pdf"); err == nil {
+		t.Error("expected nil checker to grant nothing")
+	}
+	if err := g.Route(lic, "/admin/usage"); err == nil {
+		t.Error("expected route prefix to be gated")
+	}
+}
+
+func TestDefaultGate_CoversEveryFeature(t *testing.T) {
+	lic := &License{}
+	var fe *FeatureError
+	for path, feature := range map[string]string{
+		"/dynamic":               FeatureDynamicForms,
+		"/dynamic-submit":        FeatureDynamicForms,
+		"/dynamic-submit/stream": FeatureDynamicForms,
+	} {
+		if err := DefaultGate.Route(lic, path); !errors.As(err, &fe) || fe.Feature != feature {
+			t.Errorf("route %s: expected FeatureError for %s, got %v", path, feature, err)
+		}
+	}
+	if err := DefaultGate.PromptKey(lic, "cvAnalysis"); !errors.As(err, &fe) || fe.Feature != FeatureCVAnalysis {
+		t.Errorf("expected cvAnalysis to need %s, got %v", FeatureCVAnalysis, err)
+	}
+}
+
+func TestGate_MiddlewareProblem(t *testing.T) {
+	g := Gate{Routes: map[string]string{"/dynamic": "dynamic_forms"}}
+	h := g.Middleware(&License{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/dynamic", nil))
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected 403 problem+json, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var p map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("invalid problem body: %v", err)
+	}
+	if p["feature"] != "dynamic_forms" || p["instance"] != "/dynamic" {
+		t.Errorf("unexpected problem body: %v", p)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected ungated route to pass, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,366 @@
+// pkg/license/lease.go
+package license
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Signing contexts for the documents a license server issues.
+const (
+	leaseContext      = "vjal-lease-v1\n"
+	revocationContext = "vjal-revocations-v1\n"
+)
+
+// Problem types a license server uses to refuse activation.
+const (
+	ProblemRevoked = "urn:vjal:problem:license-revoked"
+	ProblemRefused = "urn:vjal:problem:activation-refused"
+)
+
+// Lease is a time-limited permission to run a license on one device, signed
+// by the license server with an issuer key.
+type Lease struct {
+	LicenseKey string    `json:"license_key"`
+	DeviceID   string    `json:"deviceID,omitempty"`
+	IssuedAt   time.Time `json:"issuedAt"`
+	Expires    time.Time `json:"expires"`
+
+	KeyID     string `json:"kid,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// RevocationList names license keys that must no longer be used.
+type RevocationList struct {
+	IssuedAt time.Time `json:"issuedAt"`
+	Revoked  []string  `json:"revoked"`
+
+	KeyID     string `json:"kid,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// RevokedError reports a license key on the revocation list.
+type RevokedError struct {
+	Key string
+}
+
+func (e *RevokedError) Error() string {
+	return fmt.Sprintf("license %s has been revoked", e.Key)
+}
+
+// signedBytes returns context followed by the JSON of v, which must have its
+// Signature field cleared.
+func signedBytes(context string, v interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(context), payload...), nil
+}
+
+func (l *Lease) canonicalBytes() ([]byte, error) {
+	c := *l
+	c.Signature = ""
+	return signedBytes(leaseContext, c)
+}
+
+// Sign sets KeyID and the signature.
+func (l *Lease) Sign(keyID string, priv ed25519.PrivateKey) error {
+	l.KeyID = keyID
+	msg, err := l.canonicalBytes()
+	if err != nil {
+		return err
+	}
+	l.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg))
+	return nil
+}
+
+// Verify checks the signature like License.Verify.
+func (l *Lease) Verify(keys Keyring, env string) error {
+	if l.Signature == "" || l.KeyID == "" {
+		return &SignatureError{KeyID: l.KeyID, Reason: "lease is not signed"}
+	}
+	msg, err := l.canonicalBytes()
+	if err != nil {
+		return &SignatureError{KeyID: l.KeyID, Reason: "cannot encode lease"}
+	}
+	return verifySignature(keys, env, l.KeyID, l.Signature, msg, "lease")
+}
+
+// check verifies l and that it covers lic on deviceID at now.
+func (l *Lease) check(keys Keyring, env string, lic *License, deviceID string, now time.Time) error {
+	if err := l.Verify(keys, env); err != nil {
+		return err
+	}
+	if l.LicenseKey != lic.Key {
+		return fmt.Errorf("lease is for license %s, not %s", l.LicenseKey, lic.Key)
+	}
+	if l.DeviceID != "" && l.DeviceID != deviceID {
+		return &DeviceMismatchError{Licensed: l.DeviceID, Actual: deviceID}
+	}
+	if now.After(l.Expires) {
+		return fmt.Errorf("lease expired on %s", l.Expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (r *RevocationList) canonicalBytes() ([]byte, error) {
+	c := *r
+	c.Signature = ""
+	if c.Revoked == nil {
+		c.Revoked = []string{}
+	}
+	return signedBytes(revocationContext, c)
+}
+
+// Sign sets KeyID and the signature.
+func (r *RevocationList) Sign(keyID string, priv ed25519.PrivateKey) error {
+	r.KeyID = keyID
+	msg, err := r.canonicalBytes()
+	if err != nil {
+		return err
+	}
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg))
+	return nil
+}
+
+// Verify checks the signature like License.Verify.
+func (r *RevocationList) Verify(keys Keyring, env string) error {
+	if r.Signature == "" || r.KeyID == "" {
+		return &SignatureError{KeyID: r.KeyID, Reason: "revocation list is not signed"}
+	}
+	msg, err := r.canonicalBytes()
+	if err != nil {
+		return &SignatureError{KeyID: r.KeyID, Reason: "cannot encode revocation list"}
+	}
+	return verifySignature(keys, env, r.KeyID, r.Signature, msg, "revocation list")
+}
+
+// Contains reports whether key is revoked. A nil list revokes nothing.
+func (r *RevocationList) Contains(key string) bool {
+	return r != nil && slices.Contains(r.Revoked, key)
+}
+
+// activateRequest is the body of POST /v1/activate.
+type activateRequest struct {
+	LicenseKey string `json:"license_key"`
+	DeviceID   string `json:"deviceID,omitempty"`
+}
+
+// leaseCache is what LeaseClient keeps on disk.
+type leaseCache struct {
+	Lease       *Lease          `json:"lease,omitempty"`
+	Revocations *RevocationList `json:"revocations,omitempty"`
+}
+
+// LeaseClient activates a license against a license server and caches the
+// lease and revocation list, so the app keeps working while the server is
+// unreachable until the cached lease expires.
+type LeaseClient struct {
+	Server    string       // base URL, e.g. http://licenses.internal:9400
+	CachePath string       // file the lease and revocation list are cached in
+	Keys      Keyring      // keys trusted to sign leases and revocation lists
+	Env       string       // dev-only keys are refused in "production"
+	HTTP      *http.Client // nil means a client with a 10s timeout
+
+	now func() time.Time
+}
+
+// Lease returns a lease for lic on deviceID. It asks the server for a fresh
+// one and falls back to the cached lease only when the server cannot be
+// reached. A revoked license fails with *RevokedError and its cached lease
+// is discarded.
+func (c *LeaseClient) Lease(ctx context.Context, lic *License, deviceID string) (*Lease, error) {
+	cache := c.readCache()
+	now := c.clock()
+
+	// Refresh the revocation list first so a revoked key is caught even if
+	// activation itself cannot reach the server.
+	if err := c.checkRevoked(ctx, &cache, lic); err != nil {
+		c.writeCache(cache)
+		return nil, err
+	}
+
+	lease, err := c.activate(ctx, lic.Key, deviceID)
+	var unreachable *unreachableError
+	switch {
+	case err == nil:
+		if err := lease.check(c.Keys, c.Env, lic, deviceID, now); err != nil {
+			return nil, fmt.Errorf("license server returned an unusable lease: %w", err)
+		}
+		cache.Lease = lease
+		c.writeCache(cache)
+		return lease, nil
+	case errors.As(err, &unreachable):
+		if cache.Lease == nil {
+			c.writeCache(cache)
+			return nil, fmt.Errorf("%w, and no lease is cached", err)
+		}
+		if cerr := cache.Lease.check(c.Keys, c.Env, lic, deviceID, now); cerr != nil {
+			return nil, fmt.Errorf("%w, and the cached lease is unusable: %v", err, cerr)
+		}
+		c.writeCache(cache)
+		log.Printf("⚠️  %v; using cached lease until %s", err, cache.Lease.Expires.Format(time.RFC3339))
+		return cache.Lease, nil
+	default:
+		var revoked *RevokedError
+		if errors.As(err, &revoked) {
+			cache.Lease = nil
+			c.writeCache(cache)
+		}
+		return nil, err
+	}
+}
+
+// CheckRevoked refreshes the revocation list, falling back to the cached one
+// when the server cannot be reached, and fails with *RevokedError if lic is
+// on it. The server issues no leases past a license's expiry, so this is
+// the only check a license in its grace period gets.
+func (c *LeaseClient) CheckRevoked(ctx context.Context, lic *License) error {
+	cache := c.readCache()
+	err := c.checkRevoked(ctx, &cache, lic)
+	c.writeCache(cache)
+	return err
+}
+
+// checkRevoked updates cache with the server's revocation list unless that
+// list is older than the cached one, which would let a replayed list undo
+// a revocation. A revoked key also loses its cached lease.
+func (c *LeaseClient) checkRevoked(ctx context.Context, cache *leaseCache, lic *License) error {
+	list, err := c.fetchRevocations(ctx)
+	switch {
+	case err != nil:
+		log.Printf("⚠️  license server: using cached revocation list: %v", err)
+	case cache.Revocations != nil && list.IssuedAt.Before(cache.Revocations.IssuedAt):
+		log.Printf("⚠️  license server: ignoring revocation list issued %s, older than the cached one from %s",
+			list.IssuedAt.Format(time.RFC3339), cache.Revocations.IssuedAt.Format(time.RFC3339))
+	default:
+		cache.Revocations = list
+	}
+	if cache.Revocations.Contains(lic.Key) {
+		cache.Lease = nil
+		return &RevokedError{Key: lic.Key}
+	}
+	return nil
+}
+
+// unreachableError marks failures worth falling back to the cache for:
+// network errors and server-side (5xx) errors.
+type unreachableError struct {
+	err error
+}
+
+func (e *unreachableError) Error() string { return "license server unreachable: " + e.err.Error() }
+func (e *unreachableError) Unwrap() error { return e.err }
+
+func (c *LeaseClient) activate(ctx context.Context, key, deviceID string) (*Lease, error) {
+	body, _ := json.Marshal(activateRequest{LicenseKey: key, DeviceID: deviceID})
+	var lease Lease
+	if err := c.do(ctx, http.MethodPost, "/v1/activate", body, &lease); err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+func (c *LeaseClient) fetchRevocations(ctx context.Context) (*RevocationList, error) {
+	var list RevocationList
+	if err := c.do(ctx, http.MethodGet, "/v1/revocations", nil, &list); err != nil {
+		return nil, err
+	}
+	if err := list.Verify(c.Keys, c.Env); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// do sends a request and decodes a 200 response into out. Refusals come back
+// as *RevokedError or a plain error carrying the server's detail.
+func (c *LeaseClient) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.Server, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return &unreachableError{err}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return &unreachableError{err}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("license server: invalid response: %w", err)
+		}
+		return nil
+	case resp.StatusCode >= 500:
+		return &unreachableError{fmt.Errorf("%s", resp.Status)}
+	}
+	var p struct {
+		Type   string `json:"type"`
+		Detail string `json:"detail"`
+	}
+	json.Unmarshal(data, &p)
+	if p.Type == ProblemRevoked {
+		var req activateRequest
+		json.Unmarshal(body, &req)
+		return &RevokedError{Key: req.LicenseKey}
+	}
+	if p.Detail == "" {
+		p.Detail = resp.Status
+	}
+	return fmt.Errorf("license server refused activation: %s", p.Detail)
+}
+
+func (c *LeaseClient) readCache() leaseCache {
+	var cache leaseCache
+	if data, err := os.ReadFile(c.CachePath); err == nil {
+		if err := json.Unmarshal(data, &cache); err != nil {
+			log.Printf("⚠️  ignoring corrupt lease cache %s: %v", c.CachePath, err)
+			return leaseCache{}
+		}
+	}
+	if cache.Revocations != nil && cache.Revocations.Verify(c.Keys, c.Env) != nil {
+		cache.Revocations = nil
+	}
+	return cache
+}
+
+func (c *LeaseClient) writeCache(cache leaseCache) {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = os.WriteFile(c.CachePath, data, 0o600)
+	}
+	if err != nil {
+		log.Printf("⚠️  cannot write lease cache %s: %v", c.CachePath, err)
+	}
+}
+
+func (c *LeaseClient) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
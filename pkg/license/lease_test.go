@@ -0,0 +1,171 @@
+package license
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+// newTestLeaseServer serves leases for lic, revoking the keys in *revoked.
+func newTestLeaseServer(t *testing.T, lic *License, revoked *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(&LeaseServer{
+		KeyID: testKeyID,
+		Key:   testKey,
+		TTL:   time.Hour,
+		Lookup: func(key string) (*License, bool) {
+			return lic, key == lic.Key
+		},
+		Revoked: func() ([]string, error) { return *revoked, nil },
+	})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLeaseClient_ActivatesAndFallsBackToCache(t *testing.T) {
+	lic := &License{Key: "LEASE", Expires: time.Now().Add(24 * time.Hour)}
+	var revoked []string
+	srv := newTestLeaseServer(t, lic, &revoked)
+	client := &LeaseClient{Server: srv.URL, CachePath: filepath.Join(t.TempDir(), "license.lease"), Keys: testKeyring}
+
+	lease, err := client.Lease(context.Background(), lic, "device-a")
+	if err != nil {
+		t.Fatalf("activation failed: %v", err)
+	}
+	if lease.DeviceID != "device-a" || time.Until(lease.Expires) > time.Hour {
+		t.Errorf("unexpected lease %+v", lease)
+	}
+
+	// Server goes away: the cached lease keeps the license usable.
+	srv.Close()
+	cached, err := client.Lease(context.Background(), lic, "device-a")
+	if err != nil || cached.Signature != lease.Signature {
+		t.Fatalf("expected cached lease while offline, got %v", err)
+	}
+
+	// ...but not on another device, nor once it has expired.
+	if _, err := client.Lease(context.Background(), lic, "device-b"); err == nil {
+		t.Error("expected cached lease to be refused on another device")
+	}
+	client.now = func() time.Time { return lease.Expires.Add(time.Minute) }
+	if _, err := client.Lease(context.Background(), lic, "device-a"); err == nil {
+		t.Error("expected expired cached lease to be refused")
+	}
+}
+
+func TestLeaseClient_Revocation(t *testing.T) {
+	lic := &License{Key: "GONE", Expires: time.Now().Add(24 * time.Hour)}
+	var revoked []string
+	srv := newTestLeaseServer(t, lic, &revoked)
+	cache := filepath.Join(t.TempDir(), "license.lease")
+	client := &LeaseClient{Server: srv.URL, CachePath: cache, Keys: testKeyring}
+
+	if _, err := client.Lease(context.Background(), lic, ""); err != nil {
+		t.Fatalf("activation failed: %v", err)
+	}
+	revoked = []string{"GONE"}
+	var rerr *RevokedError
+	if _, err := client.Lease(context.Background(), lic, ""); !errors.As(err, &rerr) {
+		t.Fatalf("expected *RevokedError, got %v", err)
+	}
+
+	// The cached lease was discarded and the cached list still revokes the
+	// key while the server is unreachable.
+	srv.Close()
+	if _, err := client.Lease(context.Background(), lic, ""); !errors.As(err, &rerr) {
+		t.Fatalf("expected *RevokedError from the cached list, got %v", err)
+	}
+	data, _ := os.ReadFile(cache)
+	if strings.Contains(string(data), `"lease"`) {
+		t.Errorf("expected cached lease to be removed, cache is %s", data)
+	}
+}
+
+func TestLeaseClient_ForgedRevocationListIgnored(t *testing.T) {
+	list := RevocationList{Revoked: []string{"X"}}
+	if err := list.Sign(testKeyID, testKey); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	list.Revoked = nil
+	if err := list.Verify(testKeyring, ""); err == nil {
+		t.Error("expected an edited revocation list to fail verification")
+	}
+}
+
+func TestManager_RequiresLease(t *testing.T) {
+	lic := License{Key: "MGR", Expires: time.Now().Add(30 * 24 * time.Hour), Features: []string{"a"}}
+	path := writeTempLicenseFile(t, signedLicenseJSON(t, lic))
+	defer os.Remove(path)
+	defer os.Remove(path + ".lease")
+	var revoked []string
+	srv := newTestLeaseServer(t, &lic, &revoked)
+
+	cfg := config.AppConfig{LicensePath: path, LicenseServer: srv.URL}
+	m := NewManager(NewValidator(&cfg, WithKeyring(testKeyring), WithFingerprinter(StaticFingerprint("dev"))))
+	if _, err := m.Refresh(context.Background()); err != nil || m.Lease() == nil {
+		t.Fatalf("expected an active license with a lease, got %v", err)
+	}
+
+	revoked = []string{"MGR"}
+	m.Refresh(context.Background())
+	if m.Active() || m.CheckFeature("a") {
+		t.Error("expected a revoked license to disable features")
+	}
+}
+
+func TestLeaseClient_RejectsOlderRevocationList(t *testing.T) {
+	lic := &License{Key: "GONE", Expires: time.Now().Add(24 * time.Hour)}
+	revoked := []string{"GONE"}
+	issued := time.Now()
+	srv := httptest.NewServer(&LeaseServer{
+		KeyID:   testKeyID,
+		Key:     testKey,
+		Lookup:  func(key string) (*License, bool) { return lic, key == lic.Key },
+		Revoked: func() ([]string, error) { return revoked, nil },
+		now:     func() time.Time { return issued },
+	})
+	t.Cleanup(srv.Close)
+	client := &LeaseClient{Server: srv.URL, CachePath: filepath.Join(t.TempDir(), "license.lease"), Keys: testKeyring}
+
+	var rerr *RevokedError
+	if err := client.CheckRevoked(context.Background(), lic); !errors.As(err, &rerr) {
+		t.Fatalf("expected *RevokedError, got %v", err)
+	}
+	// A list signed before the cached one, as a replay would be, is ignored.
+	revoked, issued = nil, issued.Add(-time.Hour)
+	if _, err := client.Lease(context.Background(), lic, ""); !errors.As(err, &rerr) {
+		t.Fatalf("expected the older list to be ignored, got %v", err)
+	}
+	// A newer list replaces it.
+	issued = issued.Add(2 * time.Hour)
+	if _, err := client.Lease(context.Background(), lic, ""); err != nil {
+		t.Fatalf("expected the newer list to lift the revocation, got %v", err)
+	}
+}
+
+func TestManager_GraceNeedsNoLease(t *testing.T) {
+	lic := License{Key: "LATE", Expires: time.Now().Add(-time.Hour), Features: []string{"a"}}
+	path := writeTempLicenseFile(t, signedLicenseJSON(t, lic))
+	defer os.Remove(path)
+	defer os.Remove(path + ".lease")
+	var revoked []string
+	srv := newTestLeaseServer(t, &lic, &revoked)
+
+	cfg := config.AppConfig{LicensePath: path, LicenseServer: srv.URL, LicenseGracePeriod: config.Duration(48 * time.Hour)}
+	m := NewManager(NewValidator(&cfg, WithKeyring(testKeyring), WithFingerprinter(StaticFingerprint("dev"))))
+	if status, _ := m.Refresh(context.Background()); status != StatusGrace || !m.CheckFeature("a") {
+		t.Fatalf("expected grace without a lease, got %s", status)
+	}
+
+	revoked = []string{"LATE"}
+	if status, _ := m.Refresh(context.Background()); status != StatusInvalid || m.Active() {
+		t.Errorf("expected a revoked license in grace to be invalid, got %s", status)
+	}
+}
@@ -0,0 +1,145 @@
+// pkg/license/leaseserver.go
+package license
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/problem"
+)
+
+// DefaultLeaseTTL is how long a lease lasts when LeaseServer.TTL is zero.
+const DefaultLeaseTTL = 72 * time.Hour
+
+// LeaseServer is the server side of activation. It serves
+//
+//	POST /v1/activate     {"license_key": "...", "deviceID": "..."} → Lease
+//	GET  /v1/revocations  → RevocationList
+//
+// Leases never outlive the license they are issued for.
+type LeaseServer struct {
+	KeyID string
+	Key   ed25519.PrivateKey
+	TTL   time.Duration
+
+	// Lookup returns the verified license with the given key, or false if the
+	// server does not know it.
+	Lookup func(key string) (*License, bool)
+	// Revoked returns the revoked license keys. It is called per request, so
+	// edits take effect without a restart.
+	Revoked func() ([]string, error)
+
+	now func() time.Time
+}
+
+// ServeHTTP implements http.Handler.
+func (s *LeaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v1/activate" && r.Method == http.MethodPost:
+		s.activate(w, r)
+	case r.URL.Path == "/v1/revocations" && r.Method == http.MethodGet:
+		s.revocations(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *LeaseServer) activate(w http.ResponseWriter, r *http.Request) {
+	var req activateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LicenseKey == "" {
+		refuse(w, r, http.StatusBadRequest, ProblemRefused, "body must be JSON with a license_key")
+		return
+	}
+	revoked, err := s.revokedKeys()
+	if err != nil {
+		log.Printf("[activate] revocation list: %v", err)
+		http.Error(w, "revocation list unavailable", http.StatusInternalServerError)
+		return
+	}
+	if revoked.Contains(req.LicenseKey) {
+		log.Printf("[activate] refused revoked license %s", req.LicenseKey)
+		refuse(w, r, http.StatusForbidden, ProblemRevoked, (&RevokedError{Key: req.LicenseKey}).Error())
+		return
+	}
+	lic, ok := s.Lookup(req.LicenseKey)
+	now := s.clock()
+	switch {
+	case !ok:
+		refuse(w, r, http.StatusForbidden, ProblemRefused, "unknown license key")
+		return
+	case now.After(lic.Expires):
+		refuse(w, r, http.StatusForbidden, ProblemRefused, (&ExpiredError{Expires: lic.Expires}).Error())
+		return
+	case lic.DeviceID != "" && lic.DeviceID != req.DeviceID:
+		refuse(w, r, http.StatusForbidden, ProblemRefused, (&DeviceMismatchError{Licensed: lic.DeviceID, Actual: req.DeviceID}).Error())
+		return
+	}
+
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	lease := Lease{
+		LicenseKey: lic.Key,
+		DeviceID:   req.DeviceID,
+		IssuedAt:   now.UTC().Truncate(time.Second),
+		Expires:    now.Add(ttl).UTC().Truncate(time.Second),
+	}
+	if lease.Expires.After(lic.Expires) {
+		lease.Expires = lic.Expires.UTC()
+	}
+	if err := lease.Sign(s.KeyID, s.Key); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[activate] leased %s to device %q until %s", lease.LicenseKey, lease.DeviceID, lease.Expires.Format(time.RFC3339))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lease)
+}
+
+func (s *LeaseServer) revocations(w http.ResponseWriter, r *http.Request) {
+	list, err := s.revokedKeys()
+	if err != nil {
+		log.Printf("[revocations] %v", err)
+		http.Error(w, "revocation list unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// revokedKeys builds and signs the current revocation list.
+func (s *LeaseServer) revokedKeys() (*RevocationList, error) {
+	var keys []string
+	if s.Revoked != nil {
+		var err error
+		if keys, err = s.Revoked(); err != nil {
+			return nil, err
+		}
+	}
+	list := &RevocationList{IssuedAt: s.clock().UTC().Truncate(time.Second), Revoked: keys}
+	if err := list.Sign(s.KeyID, s.Key); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *LeaseServer) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func refuse(w http.ResponseWriter, r *http.Request, status int, typ, detail string) {
+	problem.Write(w, problem.Details{
+		Type:     typ,
+		Title:    "Activation refused",
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
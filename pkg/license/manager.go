@@ -0,0 +1,280 @@
+// pkg/license/manager.go
+package license
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/metrics"
+)
+
+// DefaultCheckInterval is used when the config sets no licenseCheckInterval.
+const DefaultCheckInterval = time.Hour
+
+// DefaultWarnBefore is how long before expiry the Manager starts warning.
+const DefaultWarnBefore = 14 * 24 * time.Hour
+
+// Status summarises the cached license.
+type Status int
+
+const (
+	StatusInvalid  Status = iota // unreadable, untrusted or for another device
+	StatusActive                 // valid and not close to expiry
+	StatusExpiring               // valid, but expires within the warning window
+	StatusGrace                  // expired, but inside the grace period
+	StatusExpired                // expired and past the grace period
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusActive:
+		return "active"
+	case StatusExpiring:
+		return "expiring"
+	case StatusGrace:
+		return "grace"
+	case StatusExpired:
+		return "expired"
+	default:
+		return "invalid"
+	}
+}
+
+// Event describes a change of Status, passed to the WithStatusHook callback.
+type Event struct {
+	Old, New Status
+	License  *License // nil when the license could not be read or trusted
+	Err      error    // why the license is not active, if it is not
+}
+
+// ManagerOption customises a Manager.
+type ManagerOption func(*Manager)
+
+// WithWarnBefore sets how long before expiry warnings start.
+func WithWarnBefore(d time.Duration) ManagerOption {
+	return func(m *Manager) { m.warnBefore = d }
+}
+
+// WithStatusHook calls fn, outside the Manager's lock, whenever Status changes.
+func WithStatusHook(fn func(Event)) ManagerOption {
+	return func(m *Manager) { m.hooks = append(m.hooks, fn) }
+}
+
+// Manager caches the validated license and revalidates it on a schedule, so
+// feature checks are cheap and a server that outlives its license notices.
+// Once a license expires, features keep working for the configured
+// licenseGracePeriod and then switch off; nothing panics or exits.
+type Manager struct {
+	v          *Validator
+	warnBefore time.Duration
+	hooks      []func(Event)
+
+	mu     sync.RWMutex
+	lic    *License
+	lease  *Lease
+	err    error
+	status Status
+}
+
+// NewManager wraps v. Call Refresh before first use.
+func NewManager(v *Validator, opts ...ManagerOption) *Manager {
+	m := &Manager{v: v, warnBefore: DefaultWarnBefore}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Refresh revalidates the license now and updates the cached state. The
+// error explains why the license is not fully valid; check Active to see
+// whether features are still granted.
+func (m *Manager) Refresh(ctx context.Context) (Status, error) {
+	cfg := m.v.cfg.Load()
+	lic, err := m.v.Validate(ctx)
+	now := time.Now()
+
+	var expired *ExpiredError
+	var status Status
+	switch {
+	case errors.As(err, &expired):
+		if now.Before(lic.Expires.Add(time.Duration(cfg.LicenseGracePeriod))) {
+			status = StatusGrace
+		} else {
+			status = StatusExpired
+		}
+	case err != nil:
+		status, lic = StatusInvalid, nil
+	case lic.Expires.Sub(now) <= m.warnBefore:
+		status = StatusExpiring
+	default:
+		status = StatusActive
+	}
+	if lic != nil {
+		metrics.LicenseDaysRemaining.Set(lic.Expires.Sub(now).Hours() / 24)
+	}
+
+	// With a license server configured, a usable license also needs a lease.
+	// Leases end with the license, so in grace it only must not be revoked.
+	var lease *Lease
+	if cfg.LicenseServer != "" {
+		var lerr error
+		switch status {
+		case StatusActive, StatusExpiring:
+			lease, lerr = m.leaseClient(cfg).Lease(ctx, lic, m.deviceID())
+		case StatusGrace:
+			lerr = m.leaseClient(cfg).CheckRevoked(ctx, lic)
+		}
+		if lerr != nil {
+			status, lic, err = StatusInvalid, nil, lerr
+		}
+	}
+
+	m.mu.Lock()
+	old := m.status
+	m.lic, m.lease, m.err, m.status = lic, lease, err, status
+	m.mu.Unlock()
+
+	m.warn(status, lic, err, now, time.Duration(cfg.LicenseGracePeriod))
+	if old != status {
+		for _, fn := range m.hooks {
+			fn(Event{Old: old, New: status, License: lic, Err: err})
+		}
+	}
+	return status, err
+}
+
+// leaseClient talks to cfg.LicenseServer and caches next to the license file.
+func (m *Manager) leaseClient(cfg *config.AppConfig) *LeaseClient {
+	return &LeaseClient{
+		Server:    cfg.LicenseServer,
+		CachePath: cfg.LicensePath + ".lease",
+		Keys:      m.v.keys,
+		Env:       cfg.Env,
+	}
+}
+
+// deviceID is the fingerprint leases are bound to, or "" if it cannot be
+// determined; leases are then valid on any device.
+func (m *Manager) deviceID() string {
+	id, err := m.v.fingerprinter().Fingerprint()
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// warn logs every refresh that leaves the license short of fully active.
+func (m *Manager) warn(status Status, lic *License, err error, now time.Time, grace time.Duration) {
+	switch status {
+	case StatusExpiring:
+		log.Printf("⚠️  license %s expires in %s (%s)", lic.Key, days(lic.Expires.Sub(now)), lic.Expires.Format(time.RFC3339))
+	case StatusGrace:
+		log.Printf("⚠️  license %s expired %s ago; licensed features stop in %s", lic.Key, days(now.Sub(lic.Expires)), days(lic.Expires.Add(grace).Sub(now)))
+	case StatusExpired:
+		log.Printf("⚠️  license %s expired %s ago; licensed features are disabled", lic.Key, days(now.Sub(lic.Expires)))
+	case StatusInvalid:
+		log.Printf("⚠️  license invalid; licensed features are disabled: %v", err)
+	}
+}
+
+func days(d time.Duration) string {
+	if d < 24*time.Hour {
+		return d.Truncate(time.Minute).String()
+	}
+	return fmt.Sprintf("%d days", int(math.Floor(d.Hours()/24)))
+}
+
+// Run revalidates every licenseCheckInterval until ctx is cancelled. The
+// interval is re-read from the config each time, so reloads apply.
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		interval := time.Duration(m.v.cfg.Load().LicenseCheckInterval)
+		if interval <= 0 {
+			interval = DefaultCheckInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			m.Refresh(ctx)
+		}
+	}
+}
+
+// OnConfigChange implements config.Subscriber. The license is revalidated
+// against the new config; an error is returned only if it is no longer active.
+func (m *Manager) OnConfigChange(old, new *config.AppConfig) error {
+	m.v.cfg.Store(new)
+	if _, err := m.Refresh(context.Background()); !m.Active() {
+		return err
+	}
+	return nil
+}
+
+// Status returns the cached status.
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Active reports whether licensed features are currently granted.
+func (m *Manager) Active() bool {
+	switch m.Status() {
+	case StatusActive, StatusExpiring, StatusGrace:
+		return true
+	}
+	return false
+}
+
+// License returns the cached license, which may be expired. It is nil if the
+// license could not be read or trusted.
+func (m *Manager) License() *License {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lic
+}
+
+// Lease returns the lease the license currently runs under, or nil when no
+// license server is configured or none could be obtained.
+func (m *Manager) Lease() *Lease {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lease
+}
+
+// CheckFeature reports whether the cached license is active and grants feature.
+func (m *Manager) CheckFeature(feature string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status != StatusExpired && m.status != StatusInvalid && m.lic.CheckFeature(feature)
+}
+
+// HealthCheck returns why the license is not active, or nil if it is.
+func (m *Manager) HealthCheck(ctx context.Context) error {
+	if m.Active() {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.err
+}
+
+// Require wraps next so that requests fail with a 403 problem response
+// while the license is not active.
+func (m *Manager) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Active() {
+			WriteProblem(w, r, fmt.Errorf("license %s: licensed features are disabled", m.Status()))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,100 @@
+package license
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+func TestManager_Status(t *testing.T) {
+	cases := []struct {
+		name    string
+		expires time.Duration
+		grace   time.Duration
+		want    Status
+		active  bool
+	}{
+		{"active", 90 * 24 * time.Hour, 0, StatusActive, true},
+		{"expiring", 24 * time.Hour, 0, StatusExpiring, true},
+		{"grace", -time.Hour, 48 * time.Hour, StatusGrace, true},
+		{"expired", -time.Hour, 0, StatusExpired, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeTempLicenseFile(t, signedLicenseJSON(t, License{
+				Key: "M", Expires: time.Now().Add(tc.expires), Features: []string{"a"},
+			}))
+			defer os.Remove(path)
+			cfg := config.AppConfig{LicensePath: path, LicenseGracePeriod: config.Duration(tc.grace)}
+			m := NewManager(newTestValidator(&cfg))
+
+			got, _ := m.Refresh(context.Background())
+			if got != tc.want {
+				t.Errorf("expected status %s, got %s", tc.want, got)
+			}
+			if m.Active() != tc.active || m.CheckFeature("a") != tc.active {
+				t.Errorf("expected active=%v, got Active=%v CheckFeature=%v", tc.active, m.Active(), m.CheckFeature("a"))
+			}
+			if m.License() == nil {
+				t.Error("expected the expired license to stay cached for display")
+			}
+		})
+	}
+}
+
+func TestManager_DegradesWhenLicenseExpiresOrBreaks(t *testing.T) {
+	path := writeTempLicenseFile(t, signedLicenseJSON(t, License{
+		Key: "D", Expires: time.Now().Add(365 * 24 * time.Hour), Features: []string{"a"},
+	}))
+	defer os.Remove(path)
+	cfg := config.AppConfig{LicensePath: path}
+
+	var events []Event
+	m := NewManager(newTestValidator(&cfg), WithStatusHook(func(e Event) { events = append(events, e) }))
+	if _, err := m.Refresh(context.Background()); err != nil {
+		t.Fatalf("initial refresh: %v", err)
+	}
+	handler := m.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// The license is replaced by an expired one while the server runs.
+	if err := os.WriteFile(path, []byte(signedLicenseJSON(t, License{
+		Key: "D", Expires: time.Now().Add(-time.Hour), Features: []string{"a"},
+	})), 0o644); err != nil {
+		t.Fatalf("rewrite license: %v", err)
+	}
+	_, err := m.Refresh(context.Background())
+	var expired *ExpiredError
+	if !errors.As(err, &expired) {
+		t.Fatalf("expected *ExpiredError, got %v", err)
+	}
+	if m.CheckFeature("a") || m.HealthCheck(context.Background()) == nil {
+		t.Error("expected features and health to degrade after expiry")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 from Require, got %d", rec.Code)
+	}
+
+	// A missing file makes the license invalid rather than crashing.
+	os.Remove(path)
+	if status, _ := m.Refresh(context.Background()); status != StatusInvalid || m.License() != nil {
+		t.Errorf("expected invalid status with no cached license, got %s", status)
+	}
+
+	want := []Status{StatusActive, StatusExpired, StatusInvalid}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d status events, got %+v", len(want), events)
+	}
+	for i, e := range events {
+		if e.New != want[i] {
+			t.Errorf("event %d: expected %s, got %s", i, want[i], e.New)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// pkg/license/manifest.go
+package license
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/security"
+)
+
+// manifestContext is the signing context of asset manifests.
+const manifestContext = "vjal-manifest-v1\n"
+
+// DefaultManifestPath is used when the config sets no assetManifest.
+const DefaultManifestPath = "manifest.json"
+
+// Manifest lists the SHA-256 of every shipped asset, signed with an issuer
+// key at build time. Paths are slash-separated and relative to the
+// directory holding the manifest.
+type Manifest struct {
+	CreatedAt time.Time      `json:"createdAt"`
+	Files     []ManifestFile `json:"files"`
+
+	KeyID     string `json:"kid,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// ManifestFile is one asset in a Manifest.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// AssetError reports a listed asset that is missing or has changed.
+type AssetError struct {
+	Path string
+	Err  error
+}
+
+func (e *AssetError) Error() string { return fmt.Sprintf("asset %s: %v", e.Path, e.Err) }
+func (e *AssetError) Unwrap() error { return e.Err }
+
+// BuildManifest hashes the files and directory trees named by paths, which
+// are relative to root. Missing paths are an error.
+func BuildManifest(root string, paths []string) (*Manifest, error) {
+	m := &Manifest{CreatedAt: time.Now().UTC().Truncate(time.Second), Files: []ManifestFile{}}
+	seen := map[string]bool{}
+	for _, p := range paths {
+		err := filepath.WalkDir(filepath.Join(root, p), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+			sum, size, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			m.Files = append(m.Files, ManifestFile{Path: rel, SHA256: sum, Size: size})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func (m *Manifest) canonicalBytes() ([]byte, error) {
+	c := *m
+	c.Signature = ""
+	return signedBytes(manifestContext, c)
+}
+
+// Sign sets KeyID and the signature.
+func (m *Manifest) Sign(keyID string, priv ed25519.PrivateKey) error {
+	m.KeyID = keyID
+	msg, err := m.canonicalBytes()
+	if err != nil {
+		return err
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg))
+	return nil
+}
+
+// Verify checks the signature against keys, refusing dev-only keys in
+// production.
+func (m *Manifest) Verify(keys Keyring, env string) error {
+	if m.Signature == "" || m.KeyID == "" {
+		return &SignatureError{KeyID: m.KeyID, Reason: "manifest is not signed"}
+	}
+	msg, err := m.canonicalBytes()
+	if err != nil {
+		return &SignatureError{KeyID: m.KeyID, Reason: "cannot encode manifest"}
+	}
+	return verifySignature(keys, env, m.KeyID, m.Signature, msg, "manifest")
+}
+
+// CheckFiles hashes every listed asset under root and returns an
+// *AssetError for each one that is missing or modified.
+func (m *Manifest) CheckFiles(root string) []error {
+	var errs []error
+	for _, f := range m.Files {
+		path := filepath.Join(root, filepath.FromSlash(f.Path))
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, &AssetError{Path: f.Path, Err: err})
+			continue
+		}
+		if err := security.ValidateHash(path, f.SHA256); err != nil {
+			errs = append(errs, &AssetError{Path: f.Path, Err: err})
+		}
+	}
+	return errs
+}
+
+// ReadManifest reads a manifest file without verifying it.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid JSON in manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// VerifyAssets checks the signature of the manifest at path and every asset
+// it lists, relative to the manifest's directory.
+func VerifyAssets(path string, keys Keyring, env string) error {
+	m, err := ReadManifest(path)
+	if err != nil {
+		return err
+	}
+	if err := m.Verify(keys, env); err != nil {
+		return err
+	}
+	return errors.Join(m.CheckFiles(filepath.Dir(path))...)
+}
+
+// CheckAssets runs VerifyAssets on cfg's asset manifest with the embedded
+// keys. In production any failure is returned; otherwise it is logged and
+// CheckAssets returns nil.
+func CheckAssets(cfg *config.AppConfig) error {
+	path := cfg.AssetManifest
+	if path == "" {
+		path = DefaultManifestPath
+	}
+	err := VerifyAssets(path, DefaultKeyring(), cfg.Env)
+	if err == nil || cfg.Env == "production" {
+		return err
+	}
+	log.Printf("⚠️  asset manifest: %v", err)
+	return nil
+}
@@ -0,0 +1,78 @@
+package license
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+// writeTestAssets creates root/definitions/a.json and root/llm_prompts.enc,
+// plus a signed manifest over both at root/manifest.json.
+func writeTestAssets(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "definitions"), 0o755)
+	os.WriteFile(filepath.Join(root, "definitions", "a.json"), []byte(`{}`), 0o644)
+	os.WriteFile(filepath.Join(root, "llm_prompts.enc"), []byte("bundle"), 0o644)
+
+	m, err := BuildManifest(root, []string{"definitions", "llm_prompts.enc"})
+	if err != nil {
+		t.Fatalf("BuildManifest: %v", err)
+	}
+	if len(m.Files) != 2 || m.Files[0].Path != "definitions/a.json" {
+		t.Fatalf("unexpected files %+v", m.Files)
+	}
+	if err := m.Sign(testKeyID, testKey); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	data, _ := json.Marshal(m)
+	os.WriteFile(filepath.Join(root, "manifest.json"), data, 0o644)
+	return root
+}
+
+func TestVerifyAssets(t *testing.T) {
+	root := writeTestAssets(t)
+	manifest := filepath.Join(root, "manifest.json")
+	if err := VerifyAssets(manifest, testKeyring, "production"); err != nil {
+		t.Fatalf("expected untouched assets to verify, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(root, "definitions", "a.json"), []byte(`{"x":1}`), 0o644)
+	os.Remove(filepath.Join(root, "llm_prompts.enc"))
+	err := VerifyAssets(manifest, testKeyring, "production")
+	var aerr *AssetError
+	if !errors.As(err, &aerr) {
+		t.Fatalf("expected *AssetError, got %v", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the missing file to be reported, got %v", err)
+	}
+}
+
+func TestManifest_EditedListFailsSignature(t *testing.T) {
+	root := writeTestAssets(t)
+	m, err := ReadManifest(filepath.Join(root, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Files = m.Files[1:]
+	var serr *SignatureError
+	if err := m.Verify(testKeyring, ""); !errors.As(err, &serr) {
+		t.Errorf("expected *SignatureError, got %v", err)
+	}
+}
+
+func TestCheckAssets_WarnsOutsideProduction(t *testing.T) {
+	cfg := &config.AppConfig{Env: "development", AssetManifest: filepath.Join(t.TempDir(), "missing.json")}
+	if err := CheckAssets(cfg); err != nil {
+		t.Errorf("expected only a warning in development, got %v", err)
+	}
+	cfg.Env = "production"
+	if err := CheckAssets(cfg); err == nil {
+		t.Error("expected a missing manifest to fail in production")
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !devkeys
+
+package license
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+func TestDefaultKeyring_OmitsDevKeys(t *testing.T) {
+	for id, k := range DefaultKeyring() {
+		if k.DevOnly {
+			t.Errorf("development key %s embedded without -tags devkeys", id)
+		}
+	}
+	cfg := config.AppConfig{LicensePath: "../../license.json", Env: "development"}
+	var sigErr *SignatureError
+	if _, err := NewValidator(&cfg).Validate(context.Background()); !errors.As(err, &sigErr) {
+		t.Fatalf("expected the dev-signed license.json to be untrusted, got %v", err)
+	}
+}
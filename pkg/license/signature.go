@@ -0,0 +1,181 @@
+// pkg/license/signature.go
+package license
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// signingContext prefixes the canonical encoding so a license signature can
+// never be replayed as a signature over some other kind of document.
+const signingContext = "vjal-license-v1\n"
+
+// embeddedKey is a base64 public key compiled into the binary.
+type embeddedKey struct {
+	pub     string
+	devOnly bool
+}
+
+// issuerKeys are the public keys trusted to sign licenses, by key ID.
+// Development keys are only added by builds tagged devkeys (see devkeys.go),
+// and even those builds reject them when the app runs with env "production".
+var issuerKeys = map[string]embeddedKey{}
+
+// extraIssuerKeys adds production keys at build time without editing source:
+//
+//	go build -ldflags "-X github.com/adi-ber/vjal-platform/pkg/license.extraIssuerKeys=kid=BASE64,kid2=BASE64"
+var extraIssuerKeys string
+
+// IssuerKey is a public key trusted to sign licenses.
+type IssuerKey struct {
+	Public  ed25519.PublicKey
+	DevOnly bool // only accepted outside production
+}
+
+// Keyring maps key IDs to trusted issuer keys.
+type Keyring map[string]IssuerKey
+
+// DefaultKeyring returns the issuer keys embedded in the binary.
+func DefaultKeyring() Keyring {
+	k := make(Keyring)
+	for id, ik := range issuerKeys {
+		pub, err := ParsePublicKey(ik.pub)
+		if err != nil {
+			panic(fmt.Sprintf("license: embedded issuer key %s: %v", id, err))
+		}
+		k[id] = IssuerKey{Public: pub, DevOnly: ik.devOnly}
+	}
+	for _, entry := range strings.Split(extraIssuerKeys, ",") {
+		if entry == "" {
+			continue
+		}
+		id, b64, _ := strings.Cut(entry, "=")
+		pub, err := ParsePublicKey(b64)
+		if err != nil || id == "" {
+			log.Printf("⚠️  ignoring malformed build-time issuer key %q", id)
+			continue
+		}
+		k[id] = IssuerKey{Public: pub}
+	}
+	return k
+}
+
+// SignatureError reports a license whose signature cannot be trusted. It is
+// distinct from parse and expiry errors so callers can tell tampering apart.
+type SignatureError struct {
+	KeyID  string
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	if e.KeyID == "" {
+		return "license signature invalid: " + e.Reason
+	}
+	return fmt.Sprintf("license signature invalid: %s (kid %q)", e.Reason, e.KeyID)
+}
+
+// canonicalLicense fixes the field order and formats of the signed payload.
+// Fields added to License later must be added here with omitempty so that
+// licenses signed before they existed still verify.
+type canonicalLicense struct {
+	Key      string   `json:"license_key"`
+	Expires  string   `json:"expires"`
+	Features []string `json:"features"`
+	DeviceID string   `json:"deviceID,omitempty"`
+	Limits   *Limits  `json:"limits,omitempty"`
+	KeyID    string   `json:"kid"`
+}
+
+// CanonicalBytes returns the exact bytes covered by the signature. The key ID
+// is included so a signature cannot be moved to another key.
+func (l *License) CanonicalBytes() ([]byte, error) {
+	features := l.Features
+	if features == nil {
+		features = []string{}
+	}
+	payload, err := json.Marshal(canonicalLicense{
+		Key:      l.Key,
+		Expires:  l.Expires.UTC().Format(time.RFC3339),
+		Features: features,
+		DeviceID: l.DeviceID,
+		Limits:   l.Limits,
+		KeyID:    l.KeyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(signingContext), payload...), nil
+}
+
+// Sign sets KeyID and a detached signature over the canonical encoding.
+func (l *License) Sign(keyID string, priv ed25519.PrivateKey) error {
+	l.KeyID = keyID
+	msg, err := l.CanonicalBytes()
+	if err != nil {
+		return err
+	}
+	l.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg))
+	return nil
+}
+
+// Verify checks the signature against keys. Dev-only keys are refused when
+// env is "production". Every failure is a *SignatureError.
+func (l *License) Verify(keys Keyring, env string) error {
+	if l.Signature == "" || l.KeyID == "" {
+		return &SignatureError{KeyID: l.KeyID, Reason: "license is not signed"}
+	}
+	msg, err := l.CanonicalBytes()
+	if err != nil {
+		return &SignatureError{KeyID: l.KeyID, Reason: "cannot encode license"}
+	}
+	return verifySignature(keys, env, l.KeyID, l.Signature, msg, "license")
+}
+
+// verifySignature checks a base64 signature by key kid over msg. what names
+// the signed document in error messages.
+func verifySignature(keys Keyring, env, kid, signature string, msg []byte, what string) error {
+	ik, ok := keys[kid]
+	if !ok {
+		return &SignatureError{KeyID: kid, Reason: "unknown issuer key"}
+	}
+	if ik.DevOnly && env == "production" {
+		return &SignatureError{KeyID: kid, Reason: "development key not accepted in production"}
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return &SignatureError{KeyID: kid, Reason: "malformed signature"}
+	}
+	if !ed25519.Verify(ik.Public, msg, sig) {
+		return &SignatureError{KeyID: kid, Reason: "signature does not match " + what + " contents"}
+	}
+	return nil
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length %d", len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ParsePrivateKey decodes a base64 Ed25519 private key.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key encoding: %w", err)
+	}
+	if len(b) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key length %d", len(b))
+	}
+	return ed25519.PrivateKey(b), nil
+}
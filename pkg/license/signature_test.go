@@ -0,0 +1,90 @@
+package license
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+func TestValidate_SignatureFailures(t *testing.T) {
+	valid := signedLicenseJSON(t, License{
+		Key: "SIG", Expires: time.Now().Add(24 * time.Hour), Features: []string{"basic"},
+	})
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	forged := License{Key: "SIG", Expires: time.Now().Add(24 * time.Hour), Features: []string{"basic"}}
+	if err := forged.Sign(testKeyID, otherKey); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	unknownKid := strings.Replace(valid, `"kid":"test-1"`, `"kid":"retired-0"`, 1)
+
+	cases := map[string]string{
+		"unsigned":    `{"license_key":"SIG","expires":"2099-12-31T23:59:59Z","features":["basic"]}`,
+		"tampered":    strings.Replace(valid, `"basic"`, `"basic","premium"`, 1),
+		"wrong key":   mustJSON(t, forged),
+		"unknown kid": unknownKid,
+		"garbage sig": strings.Replace(valid, `"signature":"`, `"signature":"!!`, 1),
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := writeTempLicenseFile(t, content)
+			defer os.Remove(path)
+
+			cfg := config.AppConfig{LicensePath: path}
+			v := newTestValidator(&cfg)
+			_, err := v.Validate(context.Background())
+			var sigErr *SignatureError
+			if !errors.As(err, &sigErr) {
+				t.Fatalf("expected *SignatureError, got %v", err)
+			}
+			if v.CheckFeature("basic") {
+				t.Error("expected no features from an untrusted license")
+			}
+		})
+	}
+}
+
+func TestVerify_LimitsAreSigned(t *testing.T) {
+	lic := License{Key: "LIM", Expires: time.Now().Add(time.Hour), Limits: &Limits{LLMCallsPerMonth: 100}}
+	if err := lic.Sign(testKeyID, testKey); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := lic.Verify(testKeyring, ""); err != nil {
+		t.Fatalf("expected signed limits to verify, got %v", err)
+	}
+	lic.Limits.LLMCallsPerMonth = 1000000
+	if err := lic.Verify(testKeyring, ""); err == nil {
+		t.Error("expected raised limits to break the signature")
+	}
+}
+
+func TestVerify_DevKeyRejectedInProduction(t *testing.T) {
+	lic := License{Key: "DEV", Expires: time.Now().Add(time.Hour)}
+	if err := lic.Sign(testKeyID, testKey); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	keys := Keyring{testKeyID: {Public: testPub, DevOnly: true}}
+
+	if err := lic.Verify(keys, "development"); err != nil {
+		t.Errorf("expected dev key to verify in development, got %v", err)
+	}
+	var sigErr *SignatureError
+	if err := lic.Verify(keys, "production"); !errors.As(err, &sigErr) {
+		t.Errorf("expected *SignatureError in production, got %v", err)
+	}
+}
+
+func mustJSON(t *testing.T, lic License) string {
+	t.Helper()
+	data, err := json.Marshal(lic)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"sync/atomic"
 	"time"
 
 	"github.com/adi-ber/vjal-platform/pkg/config"
@@ -18,63 +19,160 @@ type License struct {
 	Expires  time.Time `json:"expires"`          // RFC3339 timestamp
 	Features []string  `json:"features"`         // list of enabled features
 	DeviceID string    `json:"deviceID,omitempty"` // optional device fingerprint
+	Limits   *Limits   `json:"limits,omitempty"`   // usage quotas; nil means unlimited
+
+	KeyID     string `json:"kid,omitempty"`       // issuer key that signed the license
+	Signature string `json:"signature,omitempty"` // base64 Ed25519 signature over CanonicalBytes
+}
+
+// Limits caps how much a license may be used. Zero means unlimited.
+type Limits struct {
+	LLMCallsPerMonth int64 `json:"llmCallsPerMonth,omitempty"`
+	PDFsPerMonth     int64 `json:"pdfsPerMonth,omitempty"`
+	MaxDefinitions   int64 `json:"maxDefinitions,omitempty"`
 }
 
 // Validator handles license validation and feature checks.
 type Validator struct {
-	cfg *config.AppConfig
+	cfg  atomic.Pointer[config.AppConfig]
+	keys Keyring
+	fp   Fingerprinter // nil means SystemFingerprint with the config's deviceSources
+}
+
+// Option customises a Validator.
+type Option func(*Validator)
+
+// WithKeyring replaces the embedded issuer keys, e.g. in tests or tools that
+// verify against a key file.
+func WithKeyring(keys Keyring) Option {
+	return func(v *Validator) { v.keys = keys }
+}
+
+// WithFingerprinter overrides device detection, e.g. to inject a fixed
+// fingerprint in tests.
+func WithFingerprinter(fp Fingerprinter) Option {
+	return func(v *Validator) { v.fp = fp }
 }
 
 // NewValidator creates a license Validator using the application config.
-func NewValidator(cfg *config.AppConfig) *Validator {
-	return &Validator{cfg: cfg}
+func NewValidator(cfg *config.AppConfig, opts ...Option) *Validator {
+	v := &Validator{keys: DefaultKeyring()}
+	v.cfg.Store(cfg)
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// OnConfigChange implements config.Subscriber so a reloaded licensePath
+// takes effect on the next validation.
+func (v *Validator) OnConfigChange(old, new *config.AppConfig) error {
+	v.cfg.Store(new)
+	if old == nil || old.LicensePath != new.LicensePath {
+		_, err := v.Validate(context.Background())
+		return err
+	}
+	return nil
 }
 
 // Validate reads, parses, and checks the license file. It records
 // total attempts and errors in Prometheus, and returns the License.
+// A missing or tampered signature yields a *SignatureError.
 func (v *Validator) Validate(ctx context.Context) (*License, error) {
 	metrics.LicenseValidationTotal.Inc()
 
-	// Read the file
-	data, err := ioutil.ReadFile(v.cfg.LicensePath)
+	lic, err := v.load()
 	if err != nil {
 		metrics.LicenseValidationErrors.Inc()
-		return nil, fmt.Errorf("failed to read license file %s: %w", v.cfg.LicensePath, err)
-	}
-
-	// Parse JSON
-	var lic License
-	if err := json.Unmarshal(data, &lic); err != nil {
-		metrics.LicenseValidationErrors.Inc()
-		return nil, fmt.Errorf("invalid JSON in license file: %w", err)
+		return nil, err
 	}
 
 	// Check expiry
 	if time.Now().After(lic.Expires) {
 		metrics.LicenseValidationErrors.Inc()
-		return &lic, fmt.Errorf("license expired on %s", lic.Expires.Format(time.RFC3339))
+		return lic, &ExpiredError{Expires: lic.Expires}
 	}
 
-	return &lic, nil
+	return lic, nil
 }
 
-// CheckFeature returns true if the given feature is present in the license.
-// It re-reads the license file for simplicity; a real implementation might cache.
-func (v *Validator) CheckFeature(feature string) bool {
-	data, err := ioutil.ReadFile(v.cfg.LicensePath)
+// load reads, parses and verifies the signature of the license file.
+func (v *Validator) load() (*License, error) {
+	cfg := v.cfg.Load()
+	lic, err := ReadFile(cfg.LicensePath)
 	if err != nil {
-		return false
+		return nil, err
+	}
+
+	// Verify the issuer signature
+	if err := lic.Verify(v.keys, cfg.Env); err != nil {
+		return nil, err
+	}
+
+	// Check the device binding, if any
+	if lic.DeviceID != "" {
+		id, err := v.fingerprinter().Fingerprint()
+		if err != nil {
+			return nil, fmt.Errorf("license is device-bound but %w", err)
+		}
+		if id != lic.DeviceID {
+			return nil, &DeviceMismatchError{Licensed: lic.DeviceID, Actual: id}
+		}
+	}
+	return lic, nil
+}
+
+// fingerprinter returns the injected Fingerprinter or the system one.
+func (v *Validator) fingerprinter() Fingerprinter {
+	if v.fp != nil {
+		return v.fp
+	}
+	return SystemFingerprint{Sources: v.cfg.Load().DeviceSources}
+}
+
+// ExpiredError reports a correctly signed license past its expiry date.
+// Validate returns the License alongside it.
+type ExpiredError struct {
+	Expires time.Time
+}
+
+func (e *ExpiredError) Error() string {
+	return fmt.Sprintf("license expired on %s", e.Expires.Format(time.RFC3339))
+}
+
+// ReadFile reads and parses a license file without verifying it.
+func ReadFile(path string) (*License, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read license file %s: %w", path, err)
 	}
 	var lic License
 	if err := json.Unmarshal(data, &lic); err != nil {
+		return nil, fmt.Errorf("invalid JSON in license file: %w", err)
+	}
+	return &lic, nil
+}
+
+// CheckFeature returns true if the given feature is present in the license.
+// It re-reads the license file on every call; long-running servers should
+// use Manager.CheckFeature, which caches. Licenses with an invalid signature
+// grant no features.
+func (v *Validator) CheckFeature(feature string) bool {
+	lic, err := v.load()
+	if err != nil {
 		return false
 	}
-	for _, f := range lic.Features {
-		if f == feature {
-			return true
-		}
+	return lic.CheckFeature(feature)
+}
+
+// SecretKeys implements config.KeySource. It validates the license named by
+// cfg and returns the key and device ID that enc: config secrets are bound to.
+func SecretKeys(cfg *config.AppConfig) (string, []byte, error) {
+	lic, err := NewValidator(cfg).Validate(context.Background())
+	if err != nil {
+		return "", nil, err
 	}
-	return false
+	return lic.Key, []byte(lic.DeviceID), nil
 }
 
 // HealthCheck allows you to verify license validity as a readiness check.
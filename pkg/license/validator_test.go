@@ -2,7 +2,8 @@ package license
 
 import (
 	"context"
-	"fmt"
+	"crypto/ed25519"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -11,6 +12,31 @@ import (
 	"github.com/adi-ber/vjal-platform/pkg/config"
 )
 
+// testKeyID and testKey sign licenses in tests; testKeyring trusts them.
+const testKeyID = "test-1"
+
+var (
+	testPub, testKey, _ = ed25519.GenerateKey(nil)
+	testKeyring         = Keyring{testKeyID: {Public: testPub}}
+)
+
+// signedLicenseJSON signs lic with the test key and returns it as JSON.
+func signedLicenseJSON(t *testing.T, lic License) string {
+	t.Helper()
+	if err := lic.Sign(testKeyID, testKey); err != nil {
+		t.Fatalf("failed to sign license: %v", err)
+	}
+	data, err := json.Marshal(lic)
+	if err != nil {
+		t.Fatalf("failed to marshal license: %v", err)
+	}
+	return string(data)
+}
+
+func newTestValidator(cfg *config.AppConfig) *Validator {
+	return NewValidator(cfg, WithKeyring(testKeyring))
+}
+
 func writeTempLicenseFile(t *testing.T, content string) string {
 	t.Helper()
 	f, err := ioutil.TempFile("", "license-*.json")
@@ -26,15 +52,14 @@ func writeTempLicenseFile(t *testing.T, content string) string {
 }
 
 func TestValidate_Valid(t *testing.T) {
-	expires := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
-	jsonData := fmt.Sprintf(
-		`{"license_key":"TEST","expires":"%s","features":["f1"]}`, expires,
-	)
+	jsonData := signedLicenseJSON(t, License{
+		Key: "TEST", Expires: time.Now().Add(24 * time.Hour), Features: []string{"f1"},
+	})
 	path := writeTempLicenseFile(t, jsonData)
 	defer os.Remove(path)
 
 	cfg := config.AppConfig{LicensePath: path}
-	v := NewValidator(&cfg)
+	v := newTestValidator(&cfg)
 	lic, err := v.Validate(context.Background())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
@@ -45,15 +70,14 @@ func TestValidate_Valid(t *testing.T) {
 }
 
 func TestValidate_Expired(t *testing.T) {
-	expires := time.Now().Add(-1 * time.Hour).Format(time.RFC3339)
-	jsonData := fmt.Sprintf(
-		`{"license_key":"OLD","expires":"%s","features":[]}`, expires,
-	)
+	jsonData := signedLicenseJSON(t, License{
+		Key: "OLD", Expires: time.Now().Add(-1 * time.Hour), Features: []string{},
+	})
 	path := writeTempLicenseFile(t, jsonData)
 	defer os.Remove(path)
 
 	cfg := config.AppConfig{LicensePath: path}
-	v := NewValidator(&cfg)
+	v := newTestValidator(&cfg)
 	_, err := v.Validate(context.Background())
 	if err == nil {
 		t.Fatal("expected error for expired license, got nil")
@@ -61,12 +85,14 @@ func TestValidate_Expired(t *testing.T) {
 }
 
 func TestCheckFeature(t *testing.T) {
-	jsonData := `{"license_key":"F","expires":"2099-12-31T23:59:59Z","features":["a","b"]}`
+	jsonData := signedLicenseJSON(t, License{
+		Key: "F", Expires: time.Date(2099, 12, 31, 23, 59, 59, 0, time.UTC), Features: []string{"a", "b"},
+	})
 	path := writeTempLicenseFile(t, jsonData)
 	defer os.Remove(path)
 
 	cfg := config.AppConfig{LicensePath: path}
-	v := NewValidator(&cfg)
+	v := newTestValidator(&cfg)
 	if !v.CheckFeature("a") {
 		t.Error("expected feature 'a' to be present")
 	}
@@ -76,12 +102,14 @@ func TestCheckFeature(t *testing.T) {
 }
 
 func TestHealthCheck(t *testing.T) {
-	jsonData := `{"license_key":"H","expires":"2099-12-31T23:59:59Z","features":[]}`
+	jsonData := signedLicenseJSON(t, License{
+		Key: "H", Expires: time.Date(2099, 12, 31, 23, 59, 59, 0, time.UTC), Features: []string{},
+	})
 	path := writeTempLicenseFile(t, jsonData)
 	defer os.Remove(path)
 
 	cfg := config.AppConfig{LicensePath: path}
-	v := NewValidator(&cfg)
+	v := newTestValidator(&cfg)
 	if err := v.HealthCheck(context.Background()); err != nil {
 		t.Errorf("expected HealthCheck to succeed, got %v", err)
 	}
@@ -0,0 +1,150 @@
+// pkg/llm/cache.go
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/audit"
+	"github.com/adi-ber/vjal-platform/pkg/metrics"
+	"github.com/adi-ber/vjal-platform/pkg/pii"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+type noCacheKey struct{}
+
+// WithoutCache returns ctx for a call that must reach the provider even if
+// a cached response exists. The fresh response still replaces the cached one.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// CacheMiddleware bypasses the response cache for requests sent with
+// "Cache-Control: no-cache".
+func CacheMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+			r = r.WithContext(WithoutCache(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	b, _ := ctx.Value(noCacheKey{}).(bool)
+	return b
+}
+
+// cacheKey hashes everything that determines a response: the provider, the
+// model, the sampling parameters and the messages.
+func cacheKey(provider string, messages []Message, opts ChatOptions) string {
+	payload, _ := json.Marshal(struct {
+		Provider    string    `json:"provider"`
+		Model       string    `json:"model"`
+		Temperature *float64  `json:"temperature"`
+		MaxTokens   int       `json:"maxTokens"`
+		Stop        []string  `json:"stop"`
+		Messages    []Message `json:"messages"`
+	}{provider, opts.Model, opts.Temperature, opts.MaxTokens, opts.Stop, messages})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// --------------------
+// cacheClient decorates any Client to answer repeated requests from
+// storage.Store.
+// --------------------
+type cacheClient struct {
+	provider string // the configured provider; answers from fallbacks are not cached
+	store    *storage.Store
+	ttl      time.Duration
+	now      func() time.Time
+	next     Client
+}
+
+func (c *cacheClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	key, read, write := c.use(ctx, messages, opts)
+	if resp := c.get(key, read); resp != nil {
+		return resp, nil
+	}
+	resp, err := c.next.Chat(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.put(ctx, key, write, resp)
+	return resp, nil
+}
+
+// ChatStream streams a cached response a word at a time, like the local
+// providers do.
+func (c *cacheClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	key, read, write := c.use(ctx, messages, opts)
+	if resp := c.get(key, read); resp != nil {
+		if err := streamText(ctx, resp.Message.Content, fn); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+	resp, err := c.next.ChatStream(ctx, messages, opts, fn)
+	if err != nil {
+		return nil, err
+	}
+	c.put(ctx, key, write, resp)
+	return resp, nil
+}
+
+func (c *cacheClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return promptVia(ctx, c, prompt)
+}
+
+func (c *cacheClient) HealthCheck(ctx context.Context) error {
+	return c.next.HealthCheck(ctx)
+}
+
+// use returns the cache key of a call and whether the call may read and
+// write the cache. Calls carrying sensitive values never touch it; bypassed
+// calls only write.
+func (c *cacheClient) use(ctx context.Context, messages []Message, opts ChatOptions) (key string, read, write bool) {
+	if len(pii.ValuesFrom(ctx)) > 0 {
+		return "", false, false
+	}
+	return cacheKey(c.provider, messages, opts), !cacheBypassed(ctx), true
+}
+
+// get returns the cached response for key, or nil on a miss. Storage
+// errors count as misses.
+func (c *cacheClient) get(key string, read bool) *ChatResponse {
+	if !read {
+		return nil
+	}
+	data, found, err := c.store.GetCached(key, c.now())
+	if err != nil {
+		log.Printf("⚠️  llm cache: %v", err)
+	}
+	var resp ChatResponse
+	if !found || json.Unmarshal(data, &resp) != nil {
+		metrics.LLMCacheMissesTotal.WithLabelValues(c.provider).Inc()
+		return nil
+	}
+	metrics.LLMCacheHitsTotal.WithLabelValues(c.provider).Inc()
+	resp.Cached = true
+	return &resp
+}
+
+// put caches resp unless a fallback provider produced it.
+func (c *cacheClient) put(ctx context.Context, key string, write bool, resp *ChatResponse) {
+	if !write || (resp.Provider != "" && resp.Provider != c.provider) {
+		return
+	}
+	data, _ := json.Marshal(resp)
+	entry := storage.CacheEntry{Key: key, PromptKey: audit.PromptKey(ctx), Data: data, Expires: c.now().Add(c.ttl)}
+	if err := c.store.PutCached(entry); err != nil {
+		log.Printf("⚠️  llm cache: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+// pkg/llm/cache_test.go
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/audit"
+	"github.com/adi-ber/vjal-platform/pkg/pii"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+// testCache puts a cache with a controllable clock in front of next.
+func testCache(t *testing.T, next Client) (*cacheClient, *storage.Store, *time.Time) {
+	t.Helper()
+	store, err := storage.New(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	clock := time.Now()
+	c := &cacheClient{provider: "test", store: store, ttl: time.Hour, now: func() time.Time { return clock }, next: next}
+	return c, store, &clock
+}
+
+func TestCache_AnswersRepeatedRequests(t *testing.T) {
+	next := &flakyClient{}
+	c, store, clock := testCache(t, next)
+	ctx := audit.WithPromptKey(context.Background(), "userSummary")
+	chat := func(ctx context.Context, opts ChatOptions) *ChatResponse {
+		t.Helper()
+		resp, err := c.Chat(ctx, UserPrompt("hi"), opts)
+		if err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+		return resp
+	}
+
+	if resp := chat(ctx, ChatOptions{}); resp.Cached || next.calls != 1 {
+		t.Fatalf("first call should miss")
+	}
+	if resp := chat(ctx, ChatOptions{}); !resp.Cached || resp.Message.Content != "hi" || next.calls != 1 {
+		t.Errorf("second call should hit, got %+v after %d calls", resp, next.calls)
+	}
+	if chat(ctx, ChatOptions{MaxTokens: 5}); next.calls != 2 {
+		t.Error("different options must not share an entry")
+	}
+	if chat(WithoutCache(ctx), ChatOptions{}); next.calls != 3 {
+		t.Error("bypass should reach the provider")
+	}
+
+	*clock = clock.Add(2 * time.Hour)
+	if chat(ctx, ChatOptions{}); next.calls != 4 {
+		t.Error("expired entry used")
+	}
+
+	if n, _ := store.PurgeCache("userSummary"); n != 2 {
+		t.Errorf("expected 2 entries under the prompt key, purged %d", n)
+	}
+}
+
+func TestCache_SkipsSensitiveAndFallbackAnswers(t *testing.T) {
+	next := &flakyClient{}
+	c, _, _ := testCache(t, next)
+	ctx := pii.WithValues(context.Background(), []string{"Ada"})
+	c.Chat(ctx, UserPrompt("hi"), ChatOptions{})
+	c.Chat(ctx, UserPrompt("hi"), ChatOptions{})
+	if next.calls != 2 {
+		t.Errorf("calls with sensitive values must not be cached")
+	}
+
+	c.provider = "primary"
+	c.next = &chainClient{links: []link{{"fallback", next}}}
+	c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{})
+	c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{})
+	if next.calls != 4 {
+		t.Errorf("answers from a fallback provider must not be cached")
+	}
+}
+
+func TestCache_StreamsHits(t *testing.T) {
+	c, _, _ := testCache(t, &echoClient{})
+	c.Chat(context.Background(), UserPrompt("one two"), ChatOptions{})
+	var deltas []string
+	resp, err := c.ChatStream(context.Background(), UserPrompt("one two"), ChatOptions{}, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil || !resp.Cached || strings.Join(deltas, "") != "one two" {
+		t.Errorf("ChatStream = %+v, %v, deltas %q", resp, err, deltas)
+	}
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	var bypassed bool
+	h := CacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bypassed = cacheBypassed(r.Context())
+	}))
+	r := httptest.NewRequest("POST", "/process", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if bypassed {
+		t.Error("bypassed without a header")
+	}
+	r.Header.Set("Cache-Control", "no-cache")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if !bypassed {
+		t.Error("Cache-Control: no-cache ignored")
+	}
+}
@@ -0,0 +1,84 @@
+// pkg/llm/chat.go
+package llm
+
+import "context"
+
+// Role is who wrote a chat message.
+type Role string
+
+const (
+	RoleSystem    Role = "system"    // instructions for the model
+	RoleUser      Role = "user"      // the end user's words
+	RoleAssistant Role = "assistant" // earlier model replies
+)
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatOptions tunes a single Chat call. Zero values leave the provider's
+// defaults in place.
+type ChatOptions struct {
+	Model       string   // provider model name, e.g. "gpt-4o"
+	Temperature *float64 // sampling temperature; nil means the default
+	MaxTokens   int      // cap on reply tokens; 0 means no cap
+	Stop        []string // sequences that end the reply
+}
+
+// Usage counts the tokens of one call. Local providers estimate them.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// ChatResponse is the model's reply to a Chat call.
+type ChatResponse struct {
+	Message      Message `json:"message"`
+	Model        string  `json:"model,omitempty"`
+	FinishReason string  `json:"finishReason,omitempty"`
+	Usage        Usage   `json:"usage"`
+	Provider     string  `json:"provider,omitempty"` // the provider that answered, after any failover
+	Cached       bool    `json:"cached,omitempty"`   // served from the response cache
+}
+
+// UserPrompt is the conversation Prompt sends: prompt as one user message.
+func UserPrompt(prompt string) []Message {
+	return []Message{{Role: RoleUser, Content: prompt}}
+}
+
+// promptVia implements Prompt on top of c.Chat with default options.
+func promptVia(ctx context.Context, c Client, prompt string) (string, error) {
+	resp, err := c.Chat(ctx, UserPrompt(prompt), ChatOptions{})
+	if err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+// lastUserMessage returns the content of the newest user message.
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// estimateUsage approximates token counts for providers that do not report
+// them, at about four characters per token.
+func estimateUsage(messages []Message, reply string) Usage {
+	var in int
+	for _, m := range messages {
+		in += estimateTokens(m.Content)
+	}
+	out := estimateTokens(reply)
+	return Usage{PromptTokens: in, CompletionTokens: out, TotalTokens: in + out}
+}
+
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
@@ -0,0 +1,262 @@
+// pkg/llm/cost.go
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/audit"
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/metrics"
+	"github.com/adi-ber/vjal-platform/pkg/problem"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+	"github.com/adi-ber/vjal-platform/pkg/usage"
+)
+
+// Counter names of the persisted totals. Costs are kept in millionths so
+// they fit the integer counters.
+const (
+	counterPromptTokens     = "llm_prompt_tokens"
+	counterCompletionTokens = "llm_completion_tokens"
+	counterCostMicros       = "llm_cost_micros"
+)
+
+// BudgetExceededError is returned, without calling a provider, once the
+// spend of the current day or month has reached its ceiling.
+type BudgetExceededError struct {
+	Period string  // "2006-01-02" for the daily budget, "2006-01" for the monthly one
+	Spent  float64 // spend so far in Period
+	Limit  float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("LLM budget for %s exhausted: spent %.4f of %.4f", e.Period, e.Spent, e.Limit)
+}
+
+// WriteProblem answers 429 Too Many Requests with an
+// application/problem+json body describing a *BudgetExceededError.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err *BudgetExceededError) {
+	problem.Write(w, problem.Details{
+		Type:       "urn:vjal:problem:llm-budget-exceeded",
+		Title:      "LLM budget exceeded",
+		Status:     http.StatusTooManyRequests,
+		Detail:     err.Error(),
+		Instance:   r.URL.Path,
+		Extensions: map[string]interface{}{"period": err.Period, "spent": err.Spent, "limit": err.Limit},
+	})
+}
+
+// Spend is the token use and cost of one period.
+type Spend struct {
+	Period           string  `json:"period"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Ledger keeps daily token and cost totals in a storage.Store, so they
+// survive restarts. Monthly totals are the sum of the days.
+type Ledger struct {
+	store *storage.Store
+	now   func() time.Time
+}
+
+// NewLedger keeps totals in store.
+func NewLedger(store *storage.Store) *Ledger {
+	return &Ledger{store: store, now: time.Now}
+}
+
+// add records one call's usage and cost for today.
+func (l *Ledger) add(u Usage, cost float64) error {
+	day := l.now().UTC().Format(usage.DayFormat)
+	for name, n := range map[string]int64{
+		counterPromptTokens:     int64(u.PromptTokens),
+		counterCompletionTokens: int64(u.CompletionTokens),
+		counterCostMicros:       int64(math.Round(cost * 1e6)),
+	} {
+		if _, err := l.store.AddCounter(name, day, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Totals returns the spend of the current UTC day and calendar month.
+func (l *Ledger) Totals() (day, month Spend, err error) {
+	now := l.now().UTC()
+	d := now.Format(usage.DayFormat)
+	m := now.Format("2006-01")
+	if day, err = l.sum(d, d, d); err != nil {
+		return
+	}
+	month, err = l.sum(m, m+"-01", m+"-31")
+	return
+}
+
+func (l *Ledger) sum(period, from, to string) (Spend, error) {
+	s := Spend{Period: period}
+	var micros int64
+	for name, dst := range map[string]*int64{
+		counterPromptTokens:     &s.PromptTokens,
+		counterCompletionTokens: &s.CompletionTokens,
+		counterCostMicros:       &micros,
+	} {
+		n, err := l.store.SumCounter(name, from, to)
+		if err != nil {
+			return s, err
+		}
+		*dst = n
+	}
+	s.Cost = float64(micros) / 1e6
+	return s, nil
+}
+
+// Handler serves today's and this month's Spend as JSON, for an admin
+// endpoint.
+func (l *Ledger) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		day, month, err := l.Totals()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]Spend{"day": day, "month": month})
+	})
+}
+
+// --------------------
+// costClient decorates any Client to count tokens and spend, and to refuse
+// calls once a budget is used up.
+// --------------------
+type costClient struct {
+	ledger   *Ledger // nil keeps totals in metrics only
+	prices   map[string]config.LLMPrice
+	daily    float64
+	monthly  float64
+	next     Client
+	unpriced sync.Map // "provider/model" already warned about
+}
+
+func (c *costClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	if err := c.checkBudget(); err != nil {
+		return nil, err
+	}
+	resp, err := c.next.Chat(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.record(ctx, messages, opts, resp)
+	return resp, nil
+}
+
+func (c *costClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	if err := c.checkBudget(); err != nil {
+		return nil, err
+	}
+	resp, err := c.next.ChatStream(ctx, messages, opts, fn)
+	if err != nil {
+		return nil, err
+	}
+	c.record(ctx, messages, opts, resp)
+	return resp, nil
+}
+
+func (c *costClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return promptVia(ctx, c, prompt)
+}
+
+func (c *costClient) HealthCheck(ctx context.Context) error {
+	return c.next.HealthCheck(ctx)
+}
+
+// checkBudget fails once the day's or the month's spend has reached its
+// ceiling. Calls already under way may still go past it.
+func (c *costClient) checkBudget() error {
+	if c.ledger == nil || (c.daily <= 0 && c.monthly <= 0) {
+		return nil
+	}
+	day, month, err := c.ledger.Totals()
+	if err != nil {
+		return err
+	}
+	for _, b := range []struct {
+		spend Spend
+		limit float64
+		name  string
+	}{{day, c.daily, "day"}, {month, c.monthly, "month"}} {
+		if b.limit > 0 && b.spend.Cost >= b.limit {
+			metrics.LLMBudgetExceededTotal.WithLabelValues(b.name).Inc()
+			return &BudgetExceededError{Period: b.spend.Period, Spent: b.spend.Cost, Limit: b.limit}
+		}
+	}
+	return nil
+}
+
+// record fills in estimated usage if the provider reported none, then
+// counts the tokens and their cost.
+func (c *costClient) record(ctx context.Context, messages []Message, opts ChatOptions, resp *ChatResponse) {
+	if resp.Usage.TotalTokens == 0 {
+		resp.Usage = estimateUsage(messages, resp.Message.Content)
+	}
+	u := resp.Usage
+	price := c.priceFor(resp.Provider, opts.Model, resp.Model)
+	cost := (float64(u.PromptTokens)*price.Prompt + float64(u.CompletionTokens)*price.Completion) / 1e6
+
+	promptKey := audit.PromptKey(ctx)
+	metrics.LLMTokensTotal.WithLabelValues(resp.Provider, resp.Model, promptKey, "prompt").Add(float64(u.PromptTokens))
+	metrics.LLMTokensTotal.WithLabelValues(resp.Provider, resp.Model, promptKey, "completion").Add(float64(u.CompletionTokens))
+	metrics.LLMCostTotal.WithLabelValues(resp.Provider, resp.Model, promptKey).Add(cost)
+	if c.ledger == nil {
+		return
+	}
+	if err := c.ledger.add(u, cost); err != nil {
+		log.Printf("⚠️  llm cost: %v", err)
+	}
+}
+
+// priceFor prices the model the caller asked for, else the one the
+// provider reported. A model without a price costs nothing; that is logged
+// once per model when any prices are configured.
+func (c *costClient) priceFor(provider, requested, reported string) config.LLMPrice {
+	for _, model := range []string{requested, reported} {
+		if model == "" {
+			continue
+		}
+		if p, ok := c.price(provider, model); ok {
+			return p
+		}
+	}
+	if len(c.prices) > 0 {
+		if _, warned := c.unpriced.LoadOrStore(provider+"/"+reported, true); !warned {
+			log.Printf("⚠️  llm cost: no price for %s/%s in llmPrices, counting it as free", provider, reported)
+		}
+	}
+	return config.LLMPrice{}
+}
+
+// dateSuffix matches the snapshot date of a model name, as in
+// gpt-4o-2024-08-06 or gpt-4-0613.
+var dateSuffix = regexp.MustCompile(`-(\d{4}-\d{2}-\d{2}|\d{4})$`)
+
+// price looks up "provider/model", then "model". Failing that, it drops a
+// date suffix and looks again, so gpt-4o-2024-08-06 gets the price of
+// gpt-4o. Any other extension, such as gpt-4o-mini, is another model and
+// needs its own price.
+func (c *costClient) price(provider, model string) (config.LLMPrice, bool) {
+	for _, m := range []string{model, dateSuffix.ReplaceAllString(model, "")} {
+		for _, name := range []string{provider + "/" + m, m} {
+			if p, ok := c.prices[name]; ok {
+				return p, true
+			}
+		}
+	}
+	return config.LLMPrice{}, false
+}
@@ -0,0 +1,190 @@
+// pkg/llm/cost_test.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+// usageClient answers with a fixed usage, as a provider that reports it.
+type usageClient struct {
+	echoClient
+	usage Usage
+	model string // reported model, gpt-4o if empty
+	calls int
+}
+
+func (u *usageClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	u.calls++
+	model := u.model
+	if model == "" {
+		model = "gpt-4o"
+	}
+	return &ChatResponse{
+		Message:  Message{Role: RoleAssistant, Content: "an answer of some length"},
+		Model:    model,
+		Provider: "openai",
+		Usage:    u.usage,
+	}, nil
+}
+
+func testLedger(t *testing.T) (*Ledger, *time.Time) {
+	t.Helper()
+	store, err := storage.New(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	clock := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	l := NewLedger(store)
+	l.now = func() time.Time { return clock }
+	return l, &clock
+}
+
+func TestCost_CountsAndPrices(t *testing.T) {
+	ledger, clock := testLedger(t)
+	next := &usageClient{usage: Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}}
+	c := &costClient{ledger: ledger, next: next, prices: map[string]config.LLMPrice{
+		"openai/gpt-4o": {Prompt: 2.5, Completion: 10},
+		"gpt-4o":        {Prompt: 100, Completion: 100},
+	}}
+
+	if _, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	day, month, err := ledger.Totals()
+	if err != nil {
+		t.Fatalf("Totals: %v", err)
+	}
+	// 1000 × 2.5/1M + 500 × 10/1M
+	if day.Period != "2026-03-31" || day.PromptTokens != 1000 || day.CompletionTokens != 500 || day.Cost != 0.0075 {
+		t.Errorf("day = %+v", day)
+	}
+	if month.Period != "2026-03" || month != (Spend{Period: "2026-03", PromptTokens: 1000, CompletionTokens: 500, Cost: 0.0075}) {
+		t.Errorf("month = %+v", month)
+	}
+
+	// A new month starts from zero.
+	*clock = clock.Add(2 * time.Hour)
+	if _, month, _ := ledger.Totals(); month.Cost != 0 || month.Period != "2026-04" {
+		t.Errorf("April = %+v", month)
+	}
+}
+
+func TestCost_PricesDatedModels(t *testing.T) {
+	ledger, _ := testLedger(t)
+	next := &usageClient{usage: Usage{PromptTokens: 1000000, TotalTokens: 1000000}, model: "gpt-4o-mini-2024-07-18"}
+	c := &costClient{ledger: ledger, next: next, prices: map[string]config.LLMPrice{
+		"gpt-4o":             {Prompt: 2.5},
+		"openai/gpt-4o-mini": {Prompt: 0.15},
+		"gpt-4":              {Prompt: 30},
+		"other/gpt-4o-mini":  {Prompt: 99},
+	}}
+	spent := func(opts ChatOptions) float64 {
+		t.Helper()
+		_, before, _ := ledger.Totals()
+		if _, err := c.Chat(context.Background(), UserPrompt("hi"), opts); err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+		_, after, _ := ledger.Totals()
+		return math.Round((after.Cost-before.Cost)*100) / 100
+	}
+
+	// The dated name the provider reports is priced without its date.
+	if got := spent(ChatOptions{}); got != 0.15 {
+		t.Errorf("gpt-4o-mini-2024-07-18 cost %v, want 0.15", got)
+	}
+	next.model = "gpt-4o-2024-08-06"
+	if got := spent(ChatOptions{}); got != 2.5 {
+		t.Errorf("gpt-4o-2024-08-06 cost %v, want 2.5", got)
+	}
+	// The requested model wins over the reported one.
+	if got := spent(ChatOptions{Model: "gpt-4"}); got != 30 {
+		t.Errorf("requested gpt-4 cost %v, want 30", got)
+	}
+	next.model = "o1"
+	if got := spent(ChatOptions{}); got != 0 {
+		t.Errorf("unpriced model cost %v", got)
+	}
+}
+
+func TestCost_MiniIsNotItsBase(t *testing.T) {
+	ledger, _ := testLedger(t)
+	next := &usageClient{usage: Usage{PromptTokens: 1000000, TotalTokens: 1000000}, model: "gpt-4o-mini"}
+	c := &costClient{ledger: ledger, next: next, prices: map[string]config.LLMPrice{"gpt-4o": {Prompt: 2.5}}}
+	if _, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if day, _, _ := ledger.Totals(); day.Cost != 0 {
+		t.Errorf("gpt-4o-mini cost %v with only gpt-4o priced, want 0", day.Cost)
+	}
+	if _, warned := c.unpriced.Load("openai/gpt-4o-mini"); !warned {
+		t.Error("gpt-4o-mini was not reported as unpriced")
+	}
+
+	next.model = "gpt-4o"
+	if _, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if day, _, _ := ledger.Totals(); math.Round(day.Cost*100)/100 != 2.5 {
+		t.Errorf("gpt-4o cost %v, want 2.5", day.Cost)
+	}
+}
+
+func TestCost_EstimatesMissingUsage(t *testing.T) {
+	next := &usageClient{}
+	c := &costClient{next: next}
+	resp, err := c.Chat(context.Background(), UserPrompt("a prompt of some length"), ChatOptions{})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if u := resp.Usage; u.PromptTokens == 0 || u.CompletionTokens == 0 || u.TotalTokens != u.PromptTokens+u.CompletionTokens {
+		t.Errorf("usage not estimated: %+v", u)
+	}
+}
+
+func TestCost_BudgetCeiling(t *testing.T) {
+	ledger, clock := testLedger(t)
+	next := &usageClient{usage: Usage{PromptTokens: 1_000_000, TotalTokens: 1_000_000}}
+	c := &costClient{ledger: ledger, next: next, daily: 2, monthly: 3, prices: map[string]config.LLMPrice{"gpt-4o": {Prompt: 1}}}
+	chat := func() error {
+		_, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{})
+		return err
+	}
+
+	chat()
+	chat()
+	var be *BudgetExceededError
+	if err := chat(); !errors.As(err, &be) || be.Period != "2026-03-31" || be.Spent != 2 || be.Limit != 2 {
+		t.Fatalf("expected daily budget error, got %v", err)
+	}
+	if next.calls != 2 {
+		t.Errorf("provider called %d times, want 2", next.calls)
+	}
+
+	// Another day of the month is under its daily ceiling, but the month is
+	// used up.
+	*clock = clock.Add(-24 * time.Hour)
+	chat()
+	if err := chat(); !errors.As(err, &be) || be.Period != "2026-03" || be.Limit != 3 {
+		t.Errorf("expected monthly budget error, got %v", err)
+	}
+}
+
+func TestNew_BudgetNeedsLedger(t *testing.T) {
+	cfg := config.AppConfig{LLMProvider: "echo", LLMMonthlyBudget: 10}
+	if _, err := New(&cfg, &license.License{}); err == nil {
+		t.Error("expected error for a budget without a ledger")
+	}
+	ledger, _ := testLedger(t)
+	if _, err := New(&cfg, &license.License{}, WithLedger(ledger)); err != nil {
+		t.Errorf("New: %v", err)
+	}
+}
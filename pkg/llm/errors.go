@@ -0,0 +1,112 @@
+// pkg/llm/errors.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorClass groups failures by what a caller can do about them.
+type ErrorClass string
+
+const (
+	ClassRateLimit   ErrorClass = "rate_limit"   // 429: back off and retry
+	ClassServer      ErrorClass = "server"       // 5xx: the provider failed
+	ClassTimeout     ErrorClass = "timeout"      // 408 or a request deadline
+	ClassNetwork     ErrorClass = "network"      // the provider could not be reached
+	ClassCircuitOpen ErrorClass = "circuit_open" // the circuit breaker refused the call
+	ClassClient      ErrorClass = "client"       // other 4xx: bad request, key or model
+	ClassCanceled    ErrorClass = "canceled"     // the caller gave up
+	ClassOther       ErrorClass = "other"
+)
+
+// errorClasses lists every class Classify returns for an error.
+var errorClasses = []ErrorClass{
+	ClassRateLimit, ClassServer, ClassTimeout, ClassNetwork,
+	ClassCircuitOpen, ClassClient, ClassCanceled, ClassOther,
+}
+
+// ErrCircuitOpen is returned, wrapped, while a provider's circuit breaker
+// is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ProviderError is a call the provider answered with an HTTP error.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration // from the Retry-After header; 0 if absent
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s error (HTTP %d): %v", e.Provider, e.StatusCode, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// Classify returns the class of an error from a Client.
+func Classify(err error) ErrorClass {
+	var pe *ProviderError
+	var ne net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.Is(err, ErrCircuitOpen):
+		return ClassCircuitOpen
+	case errors.As(err, &pe):
+		switch {
+		case pe.StatusCode == http.StatusTooManyRequests:
+			return ClassRateLimit
+		case pe.StatusCode == http.StatusRequestTimeout:
+			return ClassTimeout
+		case pe.StatusCode >= 500:
+			return ClassServer
+		case pe.StatusCode >= 400:
+			return ClassClient
+		}
+		return ClassOther
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	case errors.As(err, &ne) && ne.Timeout():
+		return ClassTimeout
+	case errors.As(err, &ne), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return ClassNetwork
+	}
+	return ClassOther
+}
+
+// transient reports whether a call failing with class may succeed if
+// repeated; only these count against the circuit breaker.
+func transient(class ErrorClass) bool {
+	switch class {
+	case ClassRateLimit, ClassServer, ClassTimeout, ClassNetwork:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP
+// date, and OpenAI's retry-after-ms.
+func parseRetryAfter(h http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	v := h.Get("Retry-After")
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,123 @@
+// pkg/llm/fallback.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// defaultFailoverOn are the error classes that move a call on to the next
+// provider when llmFailoverOn is empty.
+var defaultFailoverOn = []ErrorClass{ClassRateLimit, ClassServer, ClassTimeout, ClassNetwork, ClassCircuitOpen}
+
+// failoverClasses parses llmFailoverOn.
+func failoverClasses(names []string) (map[ErrorClass]bool, error) {
+	classes := defaultFailoverOn
+	if len(names) > 0 {
+		classes = nil
+		for _, name := range names {
+			class := ErrorClass(name)
+			if !slices.Contains(errorClasses, class) {
+				return nil, fmt.Errorf("llmFailoverOn: unknown error class %q", name)
+			}
+			classes = append(classes, class)
+		}
+	}
+	set := make(map[ErrorClass]bool, len(classes))
+	for _, class := range classes {
+		set[class] = true
+	}
+	return set, nil
+}
+
+// link is one provider in a chain, already wrapped for metrics and retries.
+type link struct {
+	provider string
+	client   Client
+}
+
+// --------------------
+// chainClient tries each provider in turn until one answers, and stamps the
+// response with the provider that did.
+// --------------------
+type chainClient struct {
+	links    []link
+	failover map[ErrorClass]bool
+}
+
+func (c *chainClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	return c.do(ctx, func(ctx context.Context, next Client) (*ChatResponse, bool, error) {
+		resp, err := next.Chat(ctx, messages, opts)
+		return resp, true, err
+	})
+}
+
+// ChatStream fails over only if the stream broke before its first delta.
+func (c *chainClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	return c.do(ctx, func(ctx context.Context, next Client) (*ChatResponse, bool, error) {
+		started := false
+		resp, err := next.ChatStream(ctx, messages, opts, func(delta string) error {
+			started = true
+			return fn(delta)
+		})
+		return resp, !started, err
+	})
+}
+
+func (c *chainClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return promptVia(ctx, c, prompt)
+}
+
+// HealthCheck passes if any provider in the chain is healthy.
+func (c *chainClient) HealthCheck(ctx context.Context) error {
+	var errs []error
+	for _, l := range c.links {
+		err := l.client.HealthCheck(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", l.provider, err))
+	}
+	return errors.Join(errs...)
+}
+
+// do runs call against each provider until one succeeds or fails with an
+// error that is not a failover class. call reports whether its failure may
+// be handed to the next provider at all.
+func (c *chainClient) do(ctx context.Context, call func(ctx context.Context, next Client) (*ChatResponse, bool, error)) (*ChatResponse, error) {
+	from := ""
+	for i, l := range c.links {
+		resp, canFail, err := call(withFailoverFrom(ctx, from), l.client)
+		if err == nil {
+			resp.Provider = l.provider
+			return resp, nil
+		}
+		class := Classify(err)
+		if i == len(c.links)-1 || !canFail || !c.failover[class] || ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("⚠️  llm: %s failed (%s), failing over to %s", l.provider, class, c.links[i+1].provider)
+		from = l.provider
+	}
+	panic("llm: empty provider chain")
+}
+
+type failoverKey struct{}
+
+// withFailoverFrom marks calls made because provider failed, for the
+// failover_from label of metrics.LLMRequestsTotal.
+func withFailoverFrom(ctx context.Context, provider string) context.Context {
+	if provider == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, failoverKey{}, provider)
+}
+
+// failoverFrom returns the provider a call is standing in for, or "".
+func failoverFrom(ctx context.Context) string {
+	p, _ := ctx.Value(failoverKey{}).(string)
+	return p
+}
@@ -0,0 +1,113 @@
+// pkg/llm/fallback_test.go
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+	"github.com/adi-ber/vjal-platform/pkg/license"
+)
+
+// testChain links the clients under made-up provider names, each wrapped for
+// metrics as New would.
+func testChain(t *testing.T, failoverOn []string, clients ...Client) *chainClient {
+	t.Helper()
+	failover, err := failoverClasses(failoverOn)
+	if err != nil {
+		t.Fatalf("failoverClasses: %v", err)
+	}
+	c := &chainClient{failover: failover}
+	for i, next := range clients {
+		name := []string{"first", "second", "third"}[i]
+		c.links = append(c.links, link{name, &metricsClient{provider: name, next: next}})
+	}
+	return c
+}
+
+// chainOf returns the provider chain inside a Client built by New.
+func chainOf(c Client) *chainClient {
+	return c.(*costClient).next.(*chainClient)
+}
+
+func TestChain_FailsOverOnTransientErrors(t *testing.T) {
+	first := &flakyClient{errs: []error{status(503)}}
+	second := &flakyClient{}
+	c := testChain(t, nil, first, second)
+
+	resp, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Provider != "second" || resp.Message.Content != "hi" || first.calls != 1 || second.calls != 1 {
+		t.Errorf("answered by %q after %d/%d calls", resp.Provider, first.calls, second.calls)
+	}
+	if second.from != "first" {
+		t.Errorf("failover labelled as standing in for %q", second.from)
+	}
+
+	resp, err = c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{})
+	if err != nil || resp.Provider != "first" {
+		t.Errorf("healthy primary should answer: %v, %v", resp, err)
+	}
+}
+
+func TestChain_FailoverClasses(t *testing.T) {
+	first := &flakyClient{errs: []error{status(401)}}
+	second := &flakyClient{}
+	c := testChain(t, nil, first, second)
+	if _, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{}); Classify(err) != ClassClient || second.calls != 0 {
+		t.Errorf("client errors should not fail over by default: %v", err)
+	}
+
+	first.errs = []error{status(401)}
+	c = testChain(t, []string{"client"}, first, second)
+	if resp, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{}); err != nil || resp.Provider != "second" {
+		t.Errorf("expected failover on client errors, got %v, %v", resp, err)
+	}
+
+	if _, err := failoverClasses([]string{"sometimes"}); err == nil {
+		t.Error("unknown class accepted")
+	}
+}
+
+func TestChain_StreamFailsOverOnlyBeforeFirstDelta(t *testing.T) {
+	second := &flakyClient{}
+	c := testChain(t, nil, &flakyClient{}, second)
+	_, err := c.ChatStream(context.Background(), UserPrompt("hi"), ChatOptions{}, func(string) error { return nil })
+	if Classify(err) != ClassServer || second.calls != 0 {
+		t.Errorf("stream failed over after a delta: %v", err)
+	}
+}
+
+func TestNew_BuildsFallbackChain(t *testing.T) {
+	cfg := config.AppConfig{
+		LLMProvider:  "echo",
+		LLMFallbacks: []config.LLMFallback{{Provider: "offline"}, {Provider: "echo", Config: map[string]string{"retry_attempts": "1"}}},
+	}
+	c, err := New(&cfg, &license.License{Features: []string{license.FeatureOfflineLLM}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if n := len(chainOf(c).links); n != 3 {
+		t.Errorf("expected 3 providers, got %d", n)
+	}
+	resp, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{})
+	if err != nil || resp.Provider != "echo" {
+		t.Errorf("Chat = %v, %v", resp, err)
+	}
+
+	// A fallback the license does not unlock is skipped.
+	c, err = New(&cfg, &license.License{Features: []string{"cloud_llm"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if n := len(chainOf(c).links); n != 2 {
+		t.Errorf("expected unlicensed offline fallback to be skipped, got %d providers", n)
+	}
+
+	cfg.LLMFallbacks = []config.LLMFallback{{Provider: "no-such"}}
+	if _, err := New(&cfg, &license.License{}); err == nil {
+		t.Error("expected error for unknown fallback provider")
+	}
+}
@@ -3,46 +3,180 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/adi-ber/vjal-platform/pkg/audit"
 	"github.com/adi-ber/vjal-platform/pkg/config"
 	"github.com/adi-ber/vjal-platform/pkg/license"
 	"github.com/adi-ber/vjal-platform/pkg/metrics"
+	"github.com/adi-ber/vjal-platform/pkg/pii"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Client is the interface for our LLM backends.
 type Client interface {
-	// Prompt sends a text prompt and returns the model's reply.
+	// Chat sends a conversation and returns the model's next message.
+	Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error)
+	// ChatStream is Chat, but passes the reply to fn piece by piece as it
+	// is generated. The returned response holds the whole reply.
+	ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error)
+	// Prompt sends a text prompt as a single user message and returns the
+	// model's reply.
 	Prompt(ctx context.Context, prompt string) (string, error)
 	// HealthCheck verifies that the backend is reachable/ready.
 	HealthCheck(ctx context.Context) error
 }
 
-// New selects and instantiates the proper Client, then wraps it for metrics.
-func New(cfg *config.AppConfig, lic *license.License) (Client, error) {
-	var base Client
-	var err error
+// provider couples a backend constructor with what it needs from llmConfig.
+type provider struct {
+	spec     config.ProviderSpec
+	build    func(llmConfig map[string]string) (Client, error)
+	external bool // sends prompts off the machine; see AppConfig.TokenizePII
+}
+
+// providers lists every backend llm.New can build, by llmProvider name.
+var providers = map[string]provider{
+	"openai": {
+		spec: config.ProviderSpec{
+			Required:    []string{"openai_key"},
+			EnvFallback: map[string]string{"openai_key": "OPENAI_API_KEY"},
+		},
+		build:    func(c map[string]string) (Client, error) { return NewOpenAIClient(c["openai_key"]), nil },
+		external: true,
+	},
+	"offline": {build: NewOfflineClient},
+	"echo":    {build: func(map[string]string) (Client, error) { return &echoClient{}, nil }},
+}
+
+// init registers the providers and error classes with pkg/config so config
+// validation can reject unknown provider names, missing settings and unknown
+// llmFailoverOn classes.
+func init() {
+	for name, p := range providers {
+		config.RegisterProvider(name, p.spec)
+	}
+	for _, class := range errorClasses {
+		config.RegisterFailoverClasses(string(class))
+	}
+}
+
+// Option customises the Client built by New.
+type Option func(*options)
+
+type options struct {
+	audit  *audit.Recorder
+	cache  *storage.Store
+	ledger *Ledger
+}
+
+// WithAudit records every prompt, as hashes of the prompt and reply, with
+// the actor and prompt key of the call's context.
+func WithAudit(r *audit.Recorder) Option {
+	return func(o *options) { o.audit = r }
+}
+
+// WithCache answers repeated requests from store for cfg.LLMCacheTTL.
+// Without a TTL it has no effect.
+func WithCache(store *storage.Store) Option {
+	return func(o *options) { o.cache = store }
+}
+
+// WithLedger keeps daily and monthly token and cost totals in l, which
+// cfg.LLMDailyBudget and cfg.LLMMonthlyBudget are enforced against.
+func WithLedger(l *Ledger) Option {
+	return func(o *options) { o.ledger = l }
+}
 
-	switch cfg.LLMProvider {
-	case "openai":
-		base = NewOpenAIClient(cfg.LLMConfig["openai_key"])
-	case "offline":
-		base, err = NewOfflineClient(cfg.LLMConfig)
+// New builds the Client for cfg.LLMProvider, followed by any
+// cfg.LLMFallbacks, each with its own settings and wrapped for metrics,
+// retries and a circuit breaker. A call moves on to the next provider when it
+// fails with one of the cfg.LLMFailoverOn error classes.
+// With cfg.TokenizePII, external providers only see tokens in place of the
+// sensitive values carried by the call's context.
+// Every answer's tokens are counted and priced with cfg.LLMPrices. With
+// WithCache and cfg.LLMCacheTTL, repeated requests are answered from
+// storage.
+// It fails with a *license.FeatureError if lic does not unlock
+// cfg.LLMProvider; fallbacks the license does not unlock are skipped.
+func New(cfg *config.AppConfig, lic *license.License, opts ...Option) (Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	failover, err := failoverClasses(cfg.LLMFailoverOn)
+	if err != nil {
+		return nil, err
+	}
+	primary, err := newProvider(cfg, lic, cfg.LLMProvider, cfg.LLMConfig)
+	if err != nil {
+		return nil, err
+	}
+	chain := &chainClient{links: []link{{cfg.LLMProvider, primary}}, failover: failover}
+	for i, fb := range cfg.LLMFallbacks {
+		c, err := newProvider(cfg, lic, fb.Provider, fb.Config)
+		var fe *license.FeatureError
+		if errors.As(err, &fe) {
+			log.Printf("⚠️  llm: skipping fallback provider %s: %v", fb.Provider, err)
+			continue
+		}
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("llmFallbacks[%d]: %w", i, err)
 		}
-	case "echo":
-		base = &echoClient{}
-	default:
-		return nil, fmt.Errorf("unknown LLM provider: %q", cfg.LLMProvider)
+		chain.links = append(chain.links, link{fb.Provider, c})
+	}
+
+	if o.ledger == nil && (cfg.LLMDailyBudget > 0 || cfg.LLMMonthlyBudget > 0) {
+		return nil, fmt.Errorf("LLM budgets need a ledger to count spend")
+	}
+	var c Client = &costClient{
+		ledger:  o.ledger,
+		prices:  cfg.LLMPrices,
+		daily:   cfg.LLMDailyBudget,
+		monthly: cfg.LLMMonthlyBudget,
+		next:    chain,
+	}
+	if o.cache != nil && cfg.LLMCacheTTL > 0 {
+		c = &cacheClient{provider: cfg.LLMProvider, store: o.cache, ttl: time.Duration(cfg.LLMCacheTTL), now: time.Now, next: c}
+	}
+	if o.audit != nil {
+		c = &auditClient{provider: cfg.LLMProvider, rec: o.audit, next: c}
+	}
+	return c, nil
+}
+
+// newProvider builds one provider of the chain from its own settings.
+func newProvider(cfg *config.AppConfig, lic *license.License, name string, settings map[string]string) (Client, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider: %q", name)
+	}
+	if err := license.DefaultGate.Provider(lic, name); err != nil {
+		return nil, err
+	}
+	base, err := p.build(settings)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := resilienceConfig(settings)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TokenizePII && p.external {
+		base = &tokenizeClient{next: base}
 	}
 
 	// Wrap in metrics collector
-	return &metricsClient{
-		provider: cfg.LLMProvider,
+	var c Client = &metricsClient{
+		provider: name,
 		next:     base,
-	}, nil
+	}
+	// Retry transient failures and stop calling a provider that keeps failing
+	return newResilientClient(name, policy, c), nil
 }
 
 // --------------------
@@ -53,18 +187,46 @@ type metricsClient struct {
 	next     Client
 }
 
-func (m *metricsClient) Prompt(ctx context.Context, prompt string) (string, error) {
+func (m *metricsClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
 	// increment request count
-	metrics.LLMRequestsTotal.WithLabelValues(m.provider).Inc()
+	metrics.LLMRequestsTotal.WithLabelValues(m.provider, failoverFrom(ctx)).Inc()
 	// time the request
 	timer := prometheus.NewTimer(metrics.LLMRequestDuration.WithLabelValues(m.provider))
 	defer timer.ObserveDuration()
 
-	resp, err := m.next.Prompt(ctx, prompt)
+	resp, err := m.next.Chat(ctx, messages, opts)
 	if err != nil {
 		metrics.LLMErrorsTotal.WithLabelValues(m.provider).Inc()
+		return nil, err
 	}
-	return resp, err
+	return resp, nil
+}
+
+// ChatStream counts and times the whole stream, like Chat, and records the
+// time to the first delta separately.
+func (m *metricsClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	metrics.LLMRequestsTotal.WithLabelValues(m.provider, failoverFrom(ctx)).Inc()
+	start := time.Now()
+	timer := prometheus.NewTimer(metrics.LLMRequestDuration.WithLabelValues(m.provider))
+	defer timer.ObserveDuration()
+
+	first := true
+	resp, err := m.next.ChatStream(ctx, messages, opts, func(delta string) error {
+		if first {
+			first = false
+			metrics.LLMFirstTokenDuration.WithLabelValues(m.provider).Observe(time.Since(start).Seconds())
+		}
+		return fn(delta)
+	})
+	if err != nil {
+		metrics.LLMErrorsTotal.WithLabelValues(m.provider).Inc()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (m *metricsClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return promptVia(ctx, m, prompt)
 }
 
 func (m *metricsClient) HealthCheck(ctx context.Context) error {
@@ -72,12 +234,149 @@ func (m *metricsClient) HealthCheck(ctx context.Context) error {
 }
 
 // --------------------
-// echoClient simply echoes back the prompt.
+// auditClient decorates any Client to record each call in the audit log.
+// --------------------
+type auditClient struct {
+	provider string
+	rec      *audit.Recorder
+	next     Client
+}
+
+// Chat records the JSON encoding of messages as the input and the reply
+// text as the output.
+func (a *auditClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	resp, err := a.next.Chat(ctx, messages, opts)
+	input, _ := json.Marshal(messages)
+	if err != nil {
+		a.rec.Record(ctx, audit.ActionLLMCall, input, nil, "provider="+a.provider+" error="+err.Error())
+		return nil, err
+	}
+	a.rec.Record(ctx, audit.ActionLLMCall, input, []byte(resp.Message.Content), "provider="+a.answeredBy(resp)+cachedDetail(resp))
+	return resp, nil
+}
+
+// ChatStream records the call like Chat once the stream ends.
+func (a *auditClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	resp, err := a.next.ChatStream(ctx, messages, opts, fn)
+	input, _ := json.Marshal(messages)
+	if err != nil {
+		a.rec.Record(ctx, audit.ActionLLMCall, input, nil, "provider="+a.provider+" stream=true error="+err.Error())
+		return nil, err
+	}
+	a.rec.Record(ctx, audit.ActionLLMCall, input, []byte(resp.Message.Content), "provider="+a.answeredBy(resp)+" stream=true"+cachedDetail(resp))
+	return resp, nil
+}
+
+// cachedDetail marks responses served from the cache.
+func cachedDetail(resp *ChatResponse) string {
+	if resp.Cached {
+		return " cached=true"
+	}
+	return ""
+}
+
+// answeredBy names the provider that produced resp, which after a failover
+// is not the configured one.
+func (a *auditClient) answeredBy(resp *ChatResponse) string {
+	if resp.Provider != "" {
+		return resp.Provider
+	}
+	return a.provider
+}
+
+func (a *auditClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return promptVia(ctx, a, prompt)
+}
+
+func (a *auditClient) HealthCheck(ctx context.Context) error {
+	return a.next.HealthCheck(ctx)
+}
+
+// --------------------
+// tokenizeClient decorates any Client to swap sensitive values for tokens
+// on the way out and back on the way in.
+// --------------------
+type tokenizeClient struct {
+	next Client
+}
+
+func (t *tokenizeClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	values := pii.ValuesFrom(ctx)
+	if len(values) == 0 {
+		return t.next.Chat(ctx, messages, opts)
+	}
+	vault, tokenized := tokenizeMessages(messages, values)
+	resp, err := t.next.Chat(ctx, tokenized, opts)
+	if err != nil {
+		return nil, err
+	}
+	restored := *resp
+	restored.Message.Content = vault.Restore(resp.Message.Content)
+	return &restored, nil
+}
+
+func (t *tokenizeClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	values := pii.ValuesFrom(ctx)
+	if len(values) == 0 {
+		return t.next.ChatStream(ctx, messages, opts, fn)
+	}
+	vault, tokenized := tokenizeMessages(messages, values)
+	r := &tokenRestorer{restore: vault.Restore, fn: fn}
+	resp, err := t.next.ChatStream(ctx, tokenized, opts, r.write)
+	if err == nil {
+		err = r.flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	restored := *resp
+	restored.Message.Content = vault.Restore(resp.Message.Content)
+	return &restored, nil
+}
+
+// tokenizeMessages tokenises every message with one shared vault.
+func tokenizeMessages(messages []Message, values []string) (*pii.Vault, []Message) {
+	vault := pii.NewVault()
+	tokenized := make([]Message, len(messages))
+	for i, m := range messages {
+		tokenized[i] = Message{Role: m.Role, Content: vault.Tokenize(m.Content, values)}
+	}
+	return vault, tokenized
+}
+
+func (t *tokenizeClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return promptVia(ctx, t, prompt)
+}
+
+func (t *tokenizeClient) HealthCheck(ctx context.Context) error {
+	return t.next.HealthCheck(ctx)
+}
+
+// --------------------
+// echoClient simply echoes back the last user message.
 // --------------------
 type echoClient struct{}
 
+func (e *echoClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	reply := lastUserMessage(messages)
+	return &ChatResponse{
+		Message:      Message{Role: RoleAssistant, Content: reply},
+		Model:        "echo",
+		FinishReason: "stop",
+		Usage:        estimateUsage(messages, reply),
+	}, nil
+}
+
+func (e *echoClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	resp, _ := e.Chat(ctx, messages, opts)
+	if err := streamText(ctx, resp.Message.Content, fn); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (e *echoClient) Prompt(ctx context.Context, prompt string) (string, error) {
-	return prompt, nil
+	return promptVia(ctx, e, prompt)
 }
 
 func (e *echoClient) HealthCheck(ctx context.Context) error {
@@ -2,10 +2,16 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/adi-ber/vjal-platform/pkg/audit"
 	"github.com/adi-ber/vjal-platform/pkg/config"
 	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/pii"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
 )
 
 func TestEchoProvider_PromptAndHealth(t *testing.T) {
@@ -56,3 +62,211 @@ func TestMetricsLabels(t *testing.T) {
 		t.Fatalf("expected no error, got %v", err)
 	}
 }
+
+func TestNew_ProviderRequiresFeature(t *testing.T) {
+	cfg := config.AppConfig{LLMProvider: "offline"}
+
+	_, err := New(&cfg, &license.License{Features: []string{"cloud_llm"}})
+	var fe *license.FeatureError
+	if !errors.As(err, &fe) || fe.Feature != license.FeatureOfflineLLM {
+		t.Fatalf("expected FeatureError for offline_llm, got %v", err)
+	}
+	if _, err := New(&cfg, &license.License{Features: []string{license.FeatureOfflineLLM}}); err != nil {
+		t.Fatalf("expected licensed provider to build, got %v", err)
+	}
+}
+
+// memoryLog collects audit entries.
+type memoryLog struct{ entries []storage.AuditEntry }
+
+func (m *memoryLog) AppendAudit(e storage.AuditEntry) (storage.AuditEntry, error) {
+	m.entries = append(m.entries, e)
+	return e, nil
+}
+
+func TestNew_WithAuditRecordsCalls(t *testing.T) {
+	cfg := config.AppConfig{LLMProvider: "echo"}
+	var l memoryLog
+	c, err := New(&cfg, &license.License{}, WithAudit(audit.New(&l)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := audit.WithPromptKey(audit.WithActor(context.Background(), "ip:10.0.0.1"), "userSummary")
+	if _, err := c.Prompt(ctx, "hello"); err != nil {
+		t.Fatalf("Prompt: %v", err)
+	}
+	if len(l.entries) != 1 {
+		t.Fatalf("expected one audit entry, got %d", len(l.entries))
+	}
+	e := l.entries[0]
+	if e.Action != audit.ActionLLMCall || e.Actor != "ip:10.0.0.1" || e.PromptKey != "userSummary" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	input, _ := json.Marshal(UserPrompt("hello"))
+	if e.InputHash != storage.HashContent(input) || e.OutputHash != storage.HashContent([]byte("hello")) {
+		t.Errorf("expected hashes of the messages and echoed reply, got %+v", e)
+	}
+}
+
+// recordingClient replies with a fixed text and remembers the messages.
+type recordingClient struct {
+	echoClient
+	got []Message
+}
+
+func (r *recordingClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	r.got = messages
+	return &ChatResponse{Message: Message{Role: RoleAssistant, Content: "Dear [PII_1], noted."}}, nil
+}
+
+func TestTokenizeClient_HidesSensitiveValues(t *testing.T) {
+	next := &recordingClient{}
+	c := &tokenizeClient{next: next}
+	ctx := pii.WithValues(context.Background(), []string{"Ada Lovelace"})
+
+	resp, err := c.Chat(ctx, []Message{
+		{Role: RoleSystem, Content: "Address Ada Lovelace by name."},
+		{Role: RoleUser, Content: "Summarise the answers of Ada Lovelace."},
+	}, ChatOptions{})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if next.got[0].Content != "Address [PII_1] by name." || next.got[1].Content != "Summarise the answers of [PII_1]." {
+		t.Errorf("provider saw %+v", next.got)
+	}
+	if resp.Message.Content != "Dear Ada Lovelace, noted." {
+		t.Errorf("reply not restored: %q", resp.Message.Content)
+	}
+}
+
+func TestNew_TokenizesExternalProvidersOnly(t *testing.T) {
+	ctx := pii.WithValues(context.Background(), []string{"Ada Lovelace"})
+	for name, want := range map[string]string{
+		"test-external": "About [PII_1].",
+		"test-local":    "About Ada Lovelace.",
+	} {
+		next := &recordingClient{}
+		providers[name] = provider{
+			build:    func(map[string]string) (Client, error) { return next, nil },
+			external: name == "test-external",
+		}
+		defer delete(providers, name)
+
+		cfg := config.AppConfig{LLMProvider: name, TokenizePII: true}
+		c, err := New(&cfg, &license.License{})
+		if err != nil {
+			t.Fatalf("New(%s): %v", name, err)
+		}
+		if _, err := c.Chat(ctx, UserPrompt("About Ada Lovelace."), ChatOptions{}); err != nil {
+			t.Fatalf("%s Chat: %v", name, err)
+		}
+		if got := next.got[0].Content; got != want {
+			t.Errorf("%s provider saw %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestChat_LocalProviders(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "first"},
+		{Role: RoleAssistant, Content: "ok"},
+		{Role: RoleUser, Content: "second"},
+	}
+	for provider, want := range map[string]string{"echo": "second", "offline": "Offline response to: second"} {
+		cfg := config.AppConfig{LLMProvider: provider}
+		c, err := New(&cfg, &license.License{Features: []string{license.FeatureOfflineLLM}})
+		if err != nil {
+			t.Fatalf("New(%s): %v", provider, err)
+		}
+		resp, err := c.Chat(context.Background(), messages, ChatOptions{MaxTokens: 10})
+		if err != nil {
+			t.Fatalf("%s Chat: %v", provider, err)
+		}
+		if resp.Message.Role != RoleAssistant || resp.Message.Content != want {
+			t.Errorf("%s replied %+v, want %q", provider, resp.Message, want)
+		}
+		u := resp.Usage
+		if u.PromptTokens == 0 || u.CompletionTokens == 0 || u.TotalTokens != u.PromptTokens+u.CompletionTokens {
+			t.Errorf("%s usage %+v", provider, u)
+		}
+	}
+}
+
+func TestChatStream_Echo(t *testing.T) {
+	cfg := config.AppConfig{LLMProvider: "echo"}
+	c, err := New(&cfg, &license.License{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var deltas []string
+	resp, err := c.ChatStream(context.Background(), UserPrompt("one two\nthree"), ChatOptions{}, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if len(deltas) != 3 || strings.Join(deltas, "") != "one two\nthree" || resp.Message.Content != "one two\nthree" {
+		t.Errorf("deltas %q, reply %q", deltas, resp.Message.Content)
+	}
+
+	stop := errors.New("client went away")
+	if _, err := c.ChatStream(context.Background(), UserPrompt("a b c"), ChatOptions{}, func(string) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+}
+
+// splitClient streams a fixed reply in awkward pieces.
+type splitClient struct {
+	echoClient
+	pieces []string
+}
+
+func (s *splitClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	for _, p := range s.pieces {
+		if err := fn(p); err != nil {
+			return nil, err
+		}
+	}
+	return &ChatResponse{Message: Message{Role: RoleAssistant, Content: strings.Join(s.pieces, "")}}, nil
+}
+
+func TestTokenizeClient_RestoresSplitTokens(t *testing.T) {
+	c := &tokenizeClient{next: &splitClient{pieces: []string{"Dear [PI", "I_1], your [", "x] is fine. [PII_1"}}}
+	ctx := pii.WithValues(context.Background(), []string{"Ada"})
+	var out strings.Builder
+	resp, err := c.ChatStream(ctx, UserPrompt("Hello Ada"), ChatOptions{}, func(d string) error {
+		out.WriteString(d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	want := "Dear Ada, your [x] is fine. [PII_1"
+	if out.String() != want || resp.Message.Content != want {
+		t.Errorf("streamed %q, reply %q, want %q", out.String(), resp.Message.Content, want)
+	}
+}
+
+func TestReloadable_RebuildsOnTokenizePII(t *testing.T) {
+	builds := 0
+	build := func(cfg *config.AppConfig) (Client, error) {
+		builds++
+		return &echoClient{}, nil
+	}
+	old := &config.AppConfig{LLMProvider: "echo"}
+	r, err := NewReloadable(old, build)
+	if err != nil {
+		t.Fatalf("NewReloadable: %v", err)
+	}
+	same := *old
+	if err := r.OnConfigChange(old, &same); err != nil || builds != 1 {
+		t.Fatalf("unchanged config: builds = %d, err = %v", builds, err)
+	}
+	flipped := *old
+	flipped.TokenizePII = true
+	if err := r.OnConfigChange(old, &flipped); err != nil || builds != 2 {
+		t.Errorf("tokenizePII flip: builds = %d, err = %v, want a rebuild", builds, err)
+	}
+}
@@ -10,9 +10,29 @@ func NewOfflineClient(cfg map[string]string) (Client, error) {
 	return &OfflineClient{}, nil
 }
 
+// Chat returns a simple stub response to the last user message.
+func (o *OfflineClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	reply := "Offline response to: " + lastUserMessage(messages)
+	return &ChatResponse{
+		Message:      Message{Role: RoleAssistant, Content: reply},
+		Model:        "offline",
+		FinishReason: "stop",
+		Usage:        estimateUsage(messages, reply),
+	}, nil
+}
+
+// ChatStream streams the stub response a word at a time.
+func (o *OfflineClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	resp, _ := o.Chat(ctx, messages, opts)
+	if err := streamText(ctx, resp.Message.Content, fn); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 // Prompt returns a simple stub response.
 func (o *OfflineClient) Prompt(ctx context.Context, prompt string) (string, error) {
-	return "Offline response to: " + prompt, nil
+	return promptVia(ctx, o, prompt)
 }
 
 // HealthCheck for OfflineClient is always healthy.
@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -22,26 +23,115 @@ func NewOpenAIClient(apiKey string) Client {
 	if key == "" {
 		key = os.Getenv("OPENAI_API_KEY")
 	}
-	cli := openai.NewClient(option.WithAPIKey(key))
+	// Retries are left to the resilience wrapper added by New.
+	cli := openai.NewClient(option.WithAPIKey(key), option.WithMaxRetries(0))
 	return &OpenAIClient{client: &cli}
 }
 
-// Prompt sends your prompt to OpenAI and returns the assistant's reply.
-func (o *OpenAIClient) Prompt(ctx context.Context, prompt string) (string, error) {
+// chatParams converts a conversation to request parameters. The model
+// defaults to gpt-4o.
+func chatParams(messages []Message, opts ChatOptions) (openai.ChatCompletionNewParams, error) {
 	params := openai.ChatCompletionNewParams{
-		Model: shared.ChatModelGPT4o,
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		},
+		Model:    shared.ChatModelGPT4o,
+		Messages: make([]openai.ChatCompletionMessageParamUnion, 0, len(messages)),
+	}
+	for _, m := range messages {
+		switch m.Role {
+		case RoleSystem:
+			params.Messages = append(params.Messages, openai.SystemMessage(m.Content))
+		case RoleAssistant:
+			params.Messages = append(params.Messages, openai.AssistantMessage(m.Content))
+		case RoleUser:
+			params.Messages = append(params.Messages, openai.UserMessage(m.Content))
+		default:
+			return params, fmt.Errorf("unknown message role %q", m.Role)
+		}
+	}
+	if opts.Model != "" {
+		params.Model = opts.Model
+	}
+	if opts.Temperature != nil {
+		params.Temperature = openai.Float(*opts.Temperature)
+	}
+	if opts.MaxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(int64(opts.MaxTokens))
+	}
+	if len(opts.Stop) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfChatCompletionNewsStopArray: opts.Stop}
+	}
+	return params, nil
+}
+
+// Chat sends the conversation to OpenAI and returns the assistant's reply.
+func (o *OpenAIClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	params, err := chatParams(messages, opts)
+	if err != nil {
+		return nil, err
 	}
 	resp, err := o.client.Chat.Completions.New(ctx, params)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI error: %w", err)
+		return nil, openAIError(err)
 	}
+	return chatResponse(resp)
+}
+
+// ChatStream streams the reply from OpenAI, asking for token usage in the
+// final chunk.
+func (o *OpenAIClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	params, err := chatParams(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	params.StreamOptions.IncludeUsage = openai.Bool(true)
+
+	stream := o.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+	var acc openai.ChatCompletionAccumulator
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			if err := fn(chunk.Choices[0].Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, openAIError(err)
+	}
+	return chatResponse(&acc.ChatCompletion)
+}
+
+// openAIError keeps the HTTP status and Retry-After of API errors so they
+// can be classified.
+func openAIError(err error) error {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		return &ProviderError{Provider: "openai", StatusCode: apiErr.StatusCode, RetryAfter: parseRetryAfter(apiErr.Response.Header), Err: err}
+	}
+	return fmt.Errorf("OpenAI error: %w", err)
+}
+
+func chatResponse(resp *openai.ChatCompletion) (*ChatResponse, error) {
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("OpenAI returned no choices")
+		return nil, fmt.Errorf("OpenAI returned no choices")
 	}
-	return resp.Choices[0].Message.Content, nil
+	choice := resp.Choices[0]
+	return &ChatResponse{
+		Message:      Message{Role: RoleAssistant, Content: choice.Message.Content},
+		Model:        resp.Model,
+		FinishReason: choice.FinishReason,
+		Usage: Usage{
+			PromptTokens:     int(resp.Usage.PromptTokens),
+			CompletionTokens: int(resp.Usage.CompletionTokens),
+			TotalTokens:      int(resp.Usage.TotalTokens),
+		},
+	}, nil
+}
+
+// Prompt sends your prompt to OpenAI and returns the assistant's reply.
+func (o *OpenAIClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return promptVia(ctx, o, prompt)
 }
 
 // HealthCheck for OpenAIClient is a no‑op (always healthy).
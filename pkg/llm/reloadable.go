@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync/atomic"
+
+	"github.com/adi-ber/vjal-platform/pkg/config"
+)
+
+// Factory builds a Client from a config, typically a closure over llm.New.
+type Factory func(cfg *config.AppConfig) (Client, error)
+
+// ReloadableClient is a Client whose backend is rebuilt when the LLM part of
+// the config changes. Calls in flight keep using the backend they started with.
+type ReloadableClient struct {
+	build   Factory
+	current atomic.Pointer[clientBox]
+}
+
+// clientBox lets atomic.Pointer hold an interface value.
+type clientBox struct{ Client }
+
+// NewReloadable builds the initial backend from cfg.
+func NewReloadable(cfg *config.AppConfig, build Factory) (*ReloadableClient, error) {
+	c, err := build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := &ReloadableClient{build: build}
+	r.current.Store(&clientBox{c})
+	return r, nil
+}
+
+// Chat delegates to the current backend.
+func (r *ReloadableClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	return r.current.Load().Chat(ctx, messages, opts)
+}
+
+// ChatStream delegates to the current backend.
+func (r *ReloadableClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	return r.current.Load().ChatStream(ctx, messages, opts, fn)
+}
+
+// Prompt delegates to the current backend.
+func (r *ReloadableClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return r.current.Load().Prompt(ctx, prompt)
+}
+
+// HealthCheck delegates to the current backend.
+func (r *ReloadableClient) HealthCheck(ctx context.Context) error {
+	return r.current.Load().HealthCheck(ctx)
+}
+
+// OnConfigChange implements config.Subscriber. The backend is only rebuilt
+// when one of the llm* settings changed; if the build fails the old
+// backend stays in place.
+func (r *ReloadableClient) OnConfigChange(old, new *config.AppConfig) error {
+	if old != nil && llmSettingsEqual(old, new) {
+		return nil
+	}
+	c, err := r.build(new)
+	if err != nil {
+		return err
+	}
+	r.current.Store(&clientBox{c})
+	return nil
+}
+
+// llmSettingsEqual reports whether a and b build the same Client.
+func llmSettingsEqual(a, b *config.AppConfig) bool {
+	return a.LLMProvider == b.LLMProvider && a.LLMCacheTTL == b.LLMCacheTTL &&
+		a.LLMDailyBudget == b.LLMDailyBudget && a.LLMMonthlyBudget == b.LLMMonthlyBudget &&
+		a.TokenizePII == b.TokenizePII &&
+		maps.Equal(a.LLMPrices, b.LLMPrices) &&
+		maps.Equal(a.LLMConfig, b.LLMConfig) &&
+		slices.Equal(a.LLMFailoverOn, b.LLMFailoverOn) &&
+		slices.EqualFunc(a.LLMFallbacks, b.LLMFallbacks, func(x, y config.LLMFallback) bool {
+			return x.Provider == y.Provider && maps.Equal(x.Config, y.Config)
+		})
+}
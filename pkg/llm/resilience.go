@@ -0,0 +1,269 @@
+// pkg/llm/resilience.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/metrics"
+)
+
+// Resilience settings read from llmConfig, with their defaults.
+const (
+	keyRetryAttempts   = "retry_attempts"   // calls per request, including the first (3)
+	keyRetryBaseDelay  = "retry_base_delay" // backoff before the first retry (500ms)
+	keyRetryMaxDelay   = "retry_max_delay"  // cap on any wait (10s)
+	keyBreakerFailures = "breaker_failures" // consecutive failures that open the breaker (5; 0 disables)
+	keyBreakerCooldown = "breaker_cooldown" // how long it stays open (30s)
+)
+
+// resilience is how hard to try a provider.
+type resilience struct {
+	attempts        int
+	baseDelay       time.Duration
+	maxDelay        time.Duration
+	breakerFailures int
+	breakerCooldown time.Duration
+}
+
+// resilienceConfig reads the retry and breaker settings from llmConfig.
+func resilienceConfig(c map[string]string) (resilience, error) {
+	r := resilience{
+		attempts:        3,
+		baseDelay:       500 * time.Millisecond,
+		maxDelay:        10 * time.Second,
+		breakerFailures: 5,
+		breakerCooldown: 30 * time.Second,
+	}
+	ints := map[string]*int{keyRetryAttempts: &r.attempts, keyBreakerFailures: &r.breakerFailures}
+	for key, dst := range ints {
+		if v, ok := c[key]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return r, fmt.Errorf("llmConfig %s: invalid count %q", key, v)
+			}
+			*dst = n
+		}
+	}
+	durations := map[string]*time.Duration{keyRetryBaseDelay: &r.baseDelay, keyRetryMaxDelay: &r.maxDelay, keyBreakerCooldown: &r.breakerCooldown}
+	for key, dst := range durations {
+		if v, ok := c[key]; ok {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return r, fmt.Errorf("llmConfig %s: invalid duration %q", key, v)
+			}
+			*dst = d
+		}
+	}
+	if r.attempts < 1 {
+		r.attempts = 1
+	}
+	return r, nil
+}
+
+// --------------------
+// resilientClient decorates any Client with retries and a circuit breaker.
+// --------------------
+type resilientClient struct {
+	provider string
+	policy   resilience
+	breaker  *breaker
+	next     Client
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+func newResilientClient(provider string, policy resilience, next Client) *resilientClient {
+	return &resilientClient{
+		provider: provider,
+		policy:   policy,
+		breaker:  breakerFor(provider, policy.breakerFailures, policy.breakerCooldown),
+		next:     next,
+		sleep:    sleepCtx,
+	}
+}
+
+func (c *resilientClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	return c.do(ctx, func() (*ChatResponse, bool, error) {
+		resp, err := c.next.Chat(ctx, messages, opts)
+		return resp, true, err
+	})
+}
+
+// ChatStream retries only failures that happen before the first delta;
+// text already passed to fn cannot be taken back.
+func (c *resilientClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	return c.do(ctx, func() (*ChatResponse, bool, error) {
+		started := false
+		resp, err := c.next.ChatStream(ctx, messages, opts, func(delta string) error {
+			started = true
+			return fn(delta)
+		})
+		return resp, !started, err
+	})
+}
+
+func (c *resilientClient) Prompt(ctx context.Context, prompt string) (string, error) {
+	return promptVia(ctx, c, prompt)
+}
+
+func (c *resilientClient) HealthCheck(ctx context.Context) error {
+	return c.next.HealthCheck(ctx)
+}
+
+// do runs call until it succeeds, fails for good or runs out of attempts.
+// call reports whether a failure may be retried at all.
+func (c *resilientClient) do(ctx context.Context, call func() (*ChatResponse, bool, error)) (*ChatResponse, error) {
+	for attempt := 1; ; attempt++ {
+		if !c.breaker.allow() {
+			return nil, fmt.Errorf("%s: %w", c.provider, ErrCircuitOpen)
+		}
+		resp, retryable, err := call()
+		class := Classify(err)
+		c.breaker.record(class)
+		if err == nil || !retryable || !transient(class) || attempt >= c.policy.attempts || ctx.Err() != nil {
+			return resp, err
+		}
+		wait, ok := c.backoff(attempt, err)
+		if !ok {
+			return nil, err
+		}
+		metrics.LLMRetriesTotal.WithLabelValues(c.provider).Inc()
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns how long to wait before retry number attempt: the
+// provider's Retry-After if it sent one, else exponential backoff with full
+// jitter. It reports false if Retry-After asks for longer than maxDelay.
+func (c *resilientClient) backoff(attempt int, err error) (time.Duration, bool) {
+	var pe *ProviderError
+	if errors.As(err, &pe) && pe.RetryAfter > 0 {
+		return pe.RetryAfter, pe.RetryAfter <= c.policy.maxDelay
+	}
+	d := c.policy.baseDelay << (attempt - 1)
+	if d > c.policy.maxDelay || d <= 0 {
+		d = c.policy.maxDelay
+	}
+	if d <= 0 {
+		return 0, true
+	}
+	return rand.N(d + 1), true
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// breakerState values are also the values of metrics.LLMCircuitState.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+// breaker is a per-provider circuit breaker. After failures consecutive
+// transient failures it opens and refuses calls for cooldown, then lets one
+// probe call through; the probe's result closes or reopens it.
+type breaker struct {
+	mu       sync.Mutex
+	provider string
+	failures int // threshold; 0 disables the breaker
+	cooldown time.Duration
+	now      func() time.Time
+
+	state    breakerState
+	count    int
+	openedAt time.Time
+	probing  bool
+}
+
+// breakers are shared by every client of a provider, so they survive the
+// client being rebuilt on a config change.
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*breaker)
+)
+
+// breakerFor returns the provider's breaker with the given settings.
+func breakerFor(provider string, failures int, cooldown time.Duration) *breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[provider]
+	if !ok {
+		b = &breaker{provider: provider, now: time.Now}
+		breakers[provider] = b
+		b.setState(breakerClosed)
+	}
+	b.mu.Lock()
+	b.failures, b.cooldown = failures, cooldown
+	b.mu.Unlock()
+	return b
+}
+
+// allow reports whether a call may go ahead.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures == 0 {
+		return true
+	}
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// record notes the class of an allowed call's error. Transient failures
+// count towards opening; success or a client error shows the provider is
+// answering and closes it. Anything else, such as the caller cancelling,
+// says nothing about the provider.
+func (b *breaker) record(class ErrorClass) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case class == "" || class == ClassClient:
+		b.count = 0
+		b.setState(breakerClosed)
+		return
+	case !transient(class):
+		return
+	}
+	b.count++
+	if b.failures > 0 && (b.state == breakerHalfOpen || b.count >= b.failures) {
+		b.openedAt = b.now()
+		b.setState(breakerOpen)
+	}
+}
+
+func (b *breaker) setState(s breakerState) {
+	b.state = s
+	metrics.LLMCircuitState.WithLabelValues(b.provider).Set(float64(s))
+}
@@ -0,0 +1,187 @@
+// pkg/llm/resilience_test.go
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// flakyClient fails with the queued errors before answering, and remembers
+// which provider the last call stood in for.
+type flakyClient struct {
+	echoClient
+	errs  []error
+	calls int
+	from  string
+}
+
+func (f *flakyClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	f.calls++
+	f.from = failoverFrom(ctx)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return f.echoClient.Chat(ctx, messages, opts)
+}
+
+func (f *flakyClient) ChatStream(ctx context.Context, messages []Message, opts ChatOptions, fn StreamFunc) (*ChatResponse, error) {
+	f.calls++
+	if err := fn("partial"); err != nil {
+		return nil, err
+	}
+	return nil, &ProviderError{Provider: "test", StatusCode: http.StatusBadGateway, Err: errors.New("stream cut")}
+}
+
+// testResilient wraps next with a fresh breaker and records its waits.
+func testResilient(next Client, policy resilience) (*resilientClient, *[]time.Duration) {
+	var waits []time.Duration
+	c := &resilientClient{
+		provider: "test",
+		policy:   policy,
+		breaker:  &breaker{provider: "test", failures: policy.breakerFailures, cooldown: policy.breakerCooldown, now: time.Now},
+		next:     next,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	}
+	return c, &waits
+}
+
+func status(code int) error {
+	return &ProviderError{Provider: "test", StatusCode: code, Err: fmt.Errorf("status %d", code)}
+}
+
+func TestResilient_RetriesTransientErrors(t *testing.T) {
+	policy, _ := resilienceConfig(nil)
+	next := &flakyClient{errs: []error{status(503), &ProviderError{StatusCode: 429, RetryAfter: 2 * time.Second}}}
+	c, waits := testResilient(next, policy)
+
+	resp, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{})
+	if err != nil || resp.Message.Content != "hi" {
+		t.Fatalf("Chat = %v, %v", resp, err)
+	}
+	if next.calls != 3 || len(*waits) != 2 {
+		t.Fatalf("calls %d, waits %v", next.calls, *waits)
+	}
+	if (*waits)[0] > policy.baseDelay || (*waits)[1] != 2*time.Second {
+		t.Errorf("waits %v: want jittered backoff then Retry-After", *waits)
+	}
+}
+
+func TestResilient_GivesUp(t *testing.T) {
+	policy, _ := resilienceConfig(nil)
+
+	next := &flakyClient{errs: []error{status(400)}}
+	c, _ := testResilient(next, policy)
+	if _, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{}); Classify(err) != ClassClient || next.calls != 1 {
+		t.Errorf("400 should not be retried: %v after %d calls", err, next.calls)
+	}
+
+	next = &flakyClient{errs: []error{status(500), status(500), status(500), status(500)}}
+	c, _ = testResilient(next, policy)
+	if _, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{}); Classify(err) != ClassServer || next.calls != policy.attempts {
+		t.Errorf("expected %d attempts, got %d (%v)", policy.attempts, next.calls, err)
+	}
+
+	next = &flakyClient{errs: []error{&ProviderError{StatusCode: 429, RetryAfter: time.Minute}}}
+	c, waits := testResilient(next, policy)
+	if _, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{}); Classify(err) != ClassRateLimit || len(*waits) != 0 {
+		t.Errorf("Retry-After over the max delay should not be waited for: %v, waits %v", err, *waits)
+	}
+}
+
+func TestResilient_StreamNotRetriedAfterDelta(t *testing.T) {
+	policy, _ := resilienceConfig(nil)
+	next := &flakyClient{}
+	c, _ := testResilient(next, policy)
+	_, err := c.ChatStream(context.Background(), UserPrompt("hi"), ChatOptions{}, func(string) error { return nil })
+	if Classify(err) != ClassServer || next.calls != 1 {
+		t.Errorf("stream retried after a delta: %v after %d calls", err, next.calls)
+	}
+}
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	policy, err := resilienceConfig(map[string]string{
+		keyRetryAttempts: "1", keyBreakerFailures: "2", keyBreakerCooldown: "1m",
+	})
+	if err != nil {
+		t.Fatalf("resilienceConfig: %v", err)
+	}
+	next := &flakyClient{errs: []error{status(503), status(503), status(503)}}
+	c, _ := testResilient(next, policy)
+	clock := time.Now()
+	c.breaker.now = func() time.Time { return clock }
+	chat := func() error {
+		_, err := c.Chat(context.Background(), UserPrompt("hi"), ChatOptions{})
+		return err
+	}
+
+	chat()
+	chat()
+	if err := chat(); !errors.Is(err, ErrCircuitOpen) || Classify(err) != ClassCircuitOpen || next.calls != 2 {
+		t.Fatalf("expected open breaker, got %v after %d calls", err, next.calls)
+	}
+
+	// After the cooldown one probe goes through; its failure reopens the breaker.
+	clock = clock.Add(time.Minute)
+	if err := chat(); Classify(err) != ClassServer {
+		t.Fatalf("probe: %v", err)
+	}
+	if err := chat(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected breaker reopened, got %v", err)
+	}
+
+	clock = clock.Add(time.Minute)
+	if err := chat(); err != nil {
+		t.Fatalf("probe should succeed: %v", err)
+	}
+	if c.breaker.state != breakerClosed || chat() != nil {
+		t.Errorf("breaker not closed after a good probe")
+	}
+}
+
+func TestResilienceConfig_Invalid(t *testing.T) {
+	for key, v := range map[string]string{keyRetryAttempts: "many", keyRetryMaxDelay: "soon", keyBreakerFailures: "-1"} {
+		if _, err := resilienceConfig(map[string]string{key: v}); err == nil {
+			t.Errorf("%s=%q accepted", key, v)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := map[error]ErrorClass{
+		nil:              "",
+		status(429):      ClassRateLimit,
+		status(502):      ClassServer,
+		status(408):      ClassTimeout,
+		status(401):      ClassClient,
+		context.Canceled: ClassCanceled,
+		fmt.Errorf("call: %w", context.DeadlineExceeded): ClassTimeout,
+		fmt.Errorf("x: %w", ErrCircuitOpen):              ClassCircuitOpen,
+		errors.New("boom"):                               ClassOther,
+	}
+	for err, want := range cases {
+		if got := Classify(err); got != want {
+			t.Errorf("Classify(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	h := http.Header{}
+	h.Set("Retry-After", "3")
+	if d := parseRetryAfter(h); d != 3*time.Second {
+		t.Errorf("seconds: %v", d)
+	}
+	h.Set("Retry-After-Ms", "250")
+	if d := parseRetryAfter(h); d != 250*time.Millisecond {
+		t.Errorf("milliseconds: %v", d)
+	}
+}
@@ -0,0 +1,67 @@
+// pkg/llm/stream.go
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// StreamFunc receives each piece of a reply as it is generated. Returning
+// an error stops the stream; ChatStream then returns that error.
+type StreamFunc func(delta string) error
+
+// streamText sends reply to fn a word at a time, for local providers that
+// have the whole reply at once. Each delta carries the whitespace before
+// its word.
+func streamText(ctx context.Context, reply string, fn StreamFunc) error {
+	for reply != "" {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := len(reply) - len(strings.TrimLeft(reply, " \n\t"))
+		n := len(reply)
+		if end := strings.IndexAny(reply[start:], " \n\t"); end >= 0 {
+			n = start + end
+		}
+		if err := fn(reply[:n]); err != nil {
+			return err
+		}
+		reply = reply[n:]
+	}
+	return nil
+}
+
+// tokenRestorer undoes pii tokenising on a stream of deltas. A token may
+// arrive split across deltas, so text from an unclosed "[" is held back
+// until it is closed or grows too long to be a token.
+type tokenRestorer struct {
+	restore func(string) string
+	fn      StreamFunc
+	pending string
+}
+
+// maxTokenLen bounds how much text is held back; tokens look like [PII_12].
+const maxTokenLen = 16
+
+func (t *tokenRestorer) write(delta string) error {
+	t.pending += delta
+	cut := len(t.pending)
+	if i := strings.LastIndexByte(t.pending, '['); i >= 0 && !strings.Contains(t.pending[i:], "]") && len(t.pending)-i < maxTokenLen {
+		cut = i
+	}
+	if cut == 0 {
+		return nil
+	}
+	out := t.pending[:cut]
+	t.pending = t.pending[cut:]
+	return t.fn(t.restore(out))
+}
+
+func (t *tokenRestorer) flush() error {
+	if t.pending == "" {
+		return nil
+	}
+	out := t.pending
+	t.pending = ""
+	return t.fn(t.restore(out))
+}
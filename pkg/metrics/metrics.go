@@ -16,6 +16,10 @@ var (
 		Namespace: "vjal", Subsystem: "license", Name: "validation_errors_total",
 		Help:      "Total number of license validation failures",
 	})
+	LicenseDaysRemaining = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "vjal", Subsystem: "license", Name: "days_remaining",
+		Help:      "Days until the license expires; negative once expired",
+	})
 
 	// Config
 	ConfigLoadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
@@ -27,6 +31,24 @@ var (
 		Namespace: "vjal", Subsystem: "config", Name: "load_errors_total",
 		Help:      "Number of config load errors",
 	})
+	ConfigReloadsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vjal", Subsystem: "config", Name: "reloads_total",
+		Help:      "Number of config reloads swapped in",
+	})
+
+	// Usage (with quota label)
+	UsageCurrent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "vjal", Subsystem: "usage", Name: "current",
+		Help:      "Usage of each quota in the current period",
+	}, []string{"quota"})
+	UsageLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "vjal", Subsystem: "usage", Name: "limit",
+		Help:      "Licensed limit of each quota; 0 means unlimited",
+	}, []string{"quota"})
+	QuotaExceededTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vjal", Subsystem: "usage", Name: "quota_exceeded_total",
+		Help:      "Number of requests rejected because a quota was used up",
+	}, []string{"quota"})
 
 	// Form
 	FormRenderTotal    = promauto.NewCounter(prometheus.CounterOpts{
@@ -60,8 +82,8 @@ var (
 	// LLM (with provider label)
 	LLMRequestsTotal   = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "vjal", Subsystem: "llm", Name: "requests_total",
-		Help:      "Total number of LLM requests",
-	}, []string{"provider"})
+		Help:      "Total number of LLM requests; failover_from names the provider that failed before this one, if any",
+	}, []string{"provider", "failover_from"})
 	LLMRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "vjal", Subsystem: "llm", Name: "request_duration_seconds",
 		Help:    "Duration of LLM requests",
@@ -71,6 +93,39 @@ var (
 		Namespace: "vjal", Subsystem: "llm", Name: "errors_total",
 		Help:      "Number of LLM errors",
 	}, []string{"provider"})
+	LLMFirstTokenDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "vjal", Subsystem: "llm", Name: "first_token_seconds",
+		Help:    "Time from sending a streamed LLM request to its first delta",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider"})
+	LLMTokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vjal", Subsystem: "llm", Name: "tokens_total",
+		Help:      "LLM tokens used, by kind (prompt or completion); estimated when the provider does not report them",
+	}, []string{"provider", "model", "prompt_key", "kind"})
+	LLMCostTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vjal", Subsystem: "llm", Name: "cost_total",
+		Help:      "LLM spend according to the configured price table",
+	}, []string{"provider", "model", "prompt_key"})
+	LLMBudgetExceededTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vjal", Subsystem: "llm", Name: "budget_exceeded_total",
+		Help:      "Number of LLM requests refused because a spending ceiling was reached",
+	}, []string{"period"})
+	LLMCacheHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vjal", Subsystem: "llm", Name: "cache_hits_total",
+		Help:      "Number of LLM requests answered from the response cache",
+	}, []string{"provider"})
+	LLMCacheMissesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vjal", Subsystem: "llm", Name: "cache_misses_total",
+		Help:      "Number of LLM requests the response cache could not answer",
+	}, []string{"provider"})
+	LLMRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vjal", Subsystem: "llm", Name: "retries_total",
+		Help:      "Number of LLM requests retried after a transient error",
+	}, []string{"provider"})
+	LLMCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "vjal", Subsystem: "llm", Name: "circuit_state",
+		Help:      "LLM circuit breaker state: 0 closed, 1 half-open, 2 open",
+	}, []string{"provider"})
 
 	// Output
 	OutputHTMLDuration = promauto.NewHistogram(prometheus.HistogramOpts{
@@ -0,0 +1,115 @@
+// pkg/pii/pii.go
+package pii
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Class is how sensitive a form field is.
+type Class string
+
+const (
+	None   Class = ""       // not sensitive
+	PII    Class = "pii"    // personal data, e.g. names or salaries
+	Secret Class = "secret" // credentials and similar
+)
+
+// ParseClass checks a sensitivity from a form definition.
+func ParseClass(s string) (Class, error) {
+	switch c := Class(strings.ToLower(s)); c {
+	case None, PII, Secret:
+		return c, nil
+	}
+	return None, fmt.Errorf("unknown sensitivity %q (want %q or %q)", s, PII, Secret)
+}
+
+// Mask is what a value of class c is replaced with in logs.
+func (c Class) Mask() string {
+	return "[REDACTED " + string(c) + "]"
+}
+
+// Fields maps the IDs of sensitive fields to their class.
+type Fields map[string]Class
+
+// IDs returns the field IDs in order.
+func (f Fields) IDs() []string {
+	ids := make([]string, 0, len(f))
+	for id := range f {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Redact returns a copy of data with sensitive values masked, for logging.
+func (f Fields) Redact(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if c, ok := f[k]; ok && c != None {
+			out[k] = c.Mask()
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Values returns the text of every sensitive value in data, as it would
+// appear in a rendered prompt.
+func (f Fields) Values(data map[string]interface{}) []string {
+	var vals []string
+	for _, id := range f.IDs() {
+		if v, ok := data[id]; ok && v != nil && f[id] != None {
+			if s := fmt.Sprint(v); s != "" {
+				vals = append(vals, s)
+			}
+		}
+	}
+	return vals
+}
+
+type ctxKey struct{}
+
+// WithValues returns ctx carrying the sensitive values of a request, so
+// code that only sees prompts and replies can still keep them out of the
+// audit log and away from external providers.
+func WithValues(ctx context.Context, values []string) context.Context {
+	if len(values) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKey{}, append(ValuesFrom(ctx), values...))
+}
+
+// ValuesFrom returns the sensitive values carried by ctx.
+func ValuesFrom(ctx context.Context) []string {
+	v, _ := ctx.Value(ctxKey{}).([]string)
+	return v
+}
+
+// Scrub replaces every occurrence of values in s with a mask.
+func Scrub(s string, values []string) string {
+	if len(values) == 0 {
+		return s
+	}
+	var pairs []string
+	for _, v := range longestFirst(values) {
+		pairs = append(pairs, v, "[REDACTED]")
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// longestFirst returns the non-empty values, longest first, so that a
+// strings.Replacer replaces a value that contains another one whole.
+func longestFirst(values []string) []string {
+	var sorted []string
+	for _, v := range values {
+		if v != "" {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	return sorted
+}
@@ -0,0 +1,49 @@
+package pii
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+var testFields = Fields{"name": PII, "salary": PII, "apiKey": Secret}
+
+func TestParseClass(t *testing.T) {
+	if c, err := ParseClass("PII"); err != nil || c != PII {
+		t.Errorf("ParseClass(PII) = %q, %v", c, err)
+	}
+	if _, err := ParseClass("private"); err == nil {
+		t.Error("expected unknown class to fail")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	data := map[string]interface{}{"name": "Ada", "salary": 91000.0, "city": "Leeds"}
+	got := testFields.Redact(data)
+	if got["name"] != "[REDACTED pii]" || got["salary"] != "[REDACTED pii]" || got["city"] != "Leeds" {
+		t.Errorf("Redact = %v", got)
+	}
+	if data["name"] != "Ada" {
+		t.Error("Redact modified its input")
+	}
+}
+
+func TestScrubAndContext(t *testing.T) {
+	data := map[string]interface{}{"name": "Ada Lovelace", "salary": 91000.0}
+	ctx := WithValues(context.Background(), testFields.Values(data))
+	got := Scrub("Ada Lovelace earns 91000 a year", ValuesFrom(ctx))
+	if got != "[REDACTED] earns [REDACTED] a year" {
+		t.Errorf("Scrub = %q", got)
+	}
+}
+
+func TestTokenizeRestore(t *testing.T) {
+	prompt := "Summarise: Ada Lovelace, known as Ada, earns 91000."
+	tokenized, vault := Tokenize(prompt, []string{"Ada", "Ada Lovelace", "91000", "Ada"})
+	if strings.Contains(tokenized, "Ada") || strings.Contains(tokenized, "91000") {
+		t.Fatalf("values left in %q", tokenized)
+	}
+	if got := vault.Restore(tokenized); got != prompt {
+		t.Errorf("Restore = %q, want %q", got, prompt)
+	}
+}
@@ -0,0 +1,57 @@
+// pkg/pii/tokens.go
+package pii
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Vault remembers which token stands for which value, so a reply that
+// mentions the tokens can be restored.
+type Vault struct {
+	values map[string]string // token → value
+	tokens map[string]string // value → token
+}
+
+// NewVault returns an empty vault.
+func NewVault() *Vault {
+	return &Vault{values: make(map[string]string), tokens: make(map[string]string)}
+}
+
+// Tokenize replaces every occurrence of values in s with a placeholder such
+// as [PII_1] and returns the vault to undo it.
+func Tokenize(s string, values []string) (string, *Vault) {
+	v := NewVault()
+	return v.Tokenize(s, values), v
+}
+
+// Tokenize replaces values in s like the package-level Tokenize. A value
+// keeps its token across calls, so several messages can share one vault.
+func (v *Vault) Tokenize(s string, values []string) string {
+	if len(values) == 0 {
+		return s
+	}
+	var pairs []string
+	for _, val := range longestFirst(values) {
+		tok, ok := v.tokens[val]
+		if !ok {
+			tok = fmt.Sprintf("[PII_%d]", len(v.tokens)+1)
+			v.tokens[val] = tok
+			v.values[tok] = val
+		}
+		pairs = append(pairs, val, tok)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// Restore puts the original values back in place of their tokens.
+func (v *Vault) Restore(s string) string {
+	if v == nil || len(v.values) == 0 {
+		return s
+	}
+	pairs := make([]string, 0, 2*len(v.values))
+	for tok, val := range v.values {
+		pairs = append(pairs, tok, val)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
@@ -0,0 +1,43 @@
+// pkg/problem/problem.go
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Details is an RFC 9457 problem details body. Extensions are written as
+// additional top-level members.
+type Details struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// MarshalJSON flattens Extensions next to the standard members.
+func (d Details) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(d.Extensions)+5)
+	for k, v := range d.Extensions {
+		m[k] = v
+	}
+	m["type"] = d.Type
+	m["title"] = d.Title
+	m["status"] = d.Status
+	if d.Detail != "" {
+		m["detail"] = d.Detail
+	}
+	if d.Instance != "" {
+		m["instance"] = d.Instance
+	}
+	return json.Marshal(m)
+}
+
+// Write sends d with its status code and an application/problem+json body.
+func Write(w http.ResponseWriter, d Details) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(d.Status)
+	json.NewEncoder(w).Encode(d)
+}
@@ -0,0 +1,133 @@
+// pkg/prompts/prompts.go
+package prompts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"text/template"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/security"
+)
+
+// DefaultPath is the bundle the commands load.
+const DefaultPath = "llm_prompts.enc"
+
+// A bundle is magic || version || security envelope of the JSON payload,
+// keyed by the license key and device ID it was packed for. Version 2 binds
+// magic || version as associated data; version 1 bundles have none.
+const (
+	magic   = "VJPB"
+	version = 2
+)
+
+// ErrNotBundle means the data does not start with the bundle header, for
+// example a plaintext prompt file that was never packed.
+var ErrNotBundle = errors.New("not an encrypted prompt bundle")
+
+// ErrUnknownPrompt is returned by Render for a key the bundle lacks.
+var ErrUnknownPrompt = errors.New("unknown prompt key")
+
+// payload is the plaintext inside a bundle.
+type payload struct {
+	Prompts map[string]string `json:"prompts"`
+}
+
+// Prompts maps prompt keys to text/template sources.
+type Prompts map[string]string
+
+// Pack encrypts p for the given license key and device ID.
+func Pack(p Prompts, licenseKey string, deviceID []byte) ([]byte, error) {
+	for key, src := range p {
+		if _, err := template.New(key).Parse(src); err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", key, err)
+		}
+	}
+	plain, err := json.Marshal(payload{Prompts: p})
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(magic), version)
+	sealed, err := security.EncryptWith(plain, licenseKey, deviceID, security.Options{AAD: header})
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sealed...), nil
+}
+
+// Unpack decrypts a bundle made by Pack with the same license key and
+// device ID. The prompts exist only in the returned map.
+func Unpack(data []byte, licenseKey string, deviceID []byte) (Prompts, error) {
+	if len(data) < len(magic)+1 || string(data[:len(magic)]) != magic {
+		return nil, ErrNotBundle
+	}
+	header := data[:len(magic)+1]
+	var aad []byte
+	switch v := header[len(magic)]; v {
+	case 1: // no associated data
+	case version:
+		aad = header
+	default:
+		return nil, fmt.Errorf("unsupported prompt bundle version %d", v)
+	}
+	plain, err := security.DecryptWith(data[len(header):], licenseKey, deviceID, aad)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt prompt bundle (packed for another license or device?): %w", err)
+	}
+	var pl payload
+	if err := json.Unmarshal(plain, &pl); err != nil {
+		return nil, fmt.Errorf("invalid prompt bundle payload: %w", err)
+	}
+	if pl.Prompts == nil {
+		pl.Prompts = Prompts{}
+	}
+	return pl.Prompts, nil
+}
+
+// Load reads the bundle at path and decrypts it with the key and device ID
+// of lic, which must already have been validated.
+func Load(path string, lic *license.License) (Prompts, error) {
+	if lic == nil {
+		return nil, errors.New("prompts: no valid license")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt bundle %s: %w", path, err)
+	}
+	p, err := Unpack(data, lic.Key, []byte(lic.DeviceID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Keys returns the prompt keys in sorted order.
+func (p Prompts) Keys() []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Render executes the template named key with data.
+func (p Prompts) Render(key string, data interface{}) (string, error) {
+	src, ok := p[key]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownPrompt, key)
+	}
+	t, err := template.New(key).Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("prompt %s: %w", key, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", key, err)
+	}
+	return buf.String(), nil
+}
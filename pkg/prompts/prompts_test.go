@@ -0,0 +1,94 @@
+package prompts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+)
+
+func TestPackUnpack_RoundTrip(t *testing.T) {
+	in := Prompts{"greet": "Hello {{.name}}"}
+	data, err := Pack(in, "KEY", []byte("dev"))
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	if strings.Contains(string(data), "Hello") {
+		t.Fatal("expected the bundle not to contain the prompt in the clear")
+	}
+
+	out, err := Unpack(data, "KEY", []byte("dev"))
+	if err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	got, err := out.Render("greet", map[string]string{"name": "Ada"})
+	if err != nil || got != "Hello Ada" {
+		t.Fatalf("Render = %q, %v", got, err)
+	}
+	if _, err := out.Render("missing", nil); !errors.Is(err, ErrUnknownPrompt) {
+		t.Errorf("expected ErrUnknownPrompt, got %v", err)
+	}
+}
+
+func TestUnpack_WrongKeyOrDevice(t *testing.T) {
+	data, err := Pack(Prompts{"a": "x"}, "KEY", []byte("dev"))
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	if _, err := Unpack(data, "OTHER", []byte("dev")); err == nil {
+		t.Error("expected a different license key to fail")
+	}
+	if _, err := Unpack(data, "KEY", []byte("other")); err == nil {
+		t.Error("expected a different device to fail")
+	}
+}
+
+func TestUnpack_RejectsPlaintext(t *testing.T) {
+	if _, err := Unpack([]byte(`{"a": "x"}`), "KEY", nil); !errors.Is(err, ErrNotBundle) {
+		t.Errorf("expected ErrNotBundle, got %v", err)
+	}
+}
+
+func TestPack_RejectsBadTemplate(t *testing.T) {
+	if _, err := Pack(Prompts{"bad": "{{.x"}, "KEY", nil); err == nil {
+		t.Error("expected a template parse error")
+	}
+}
+
+func TestLoad_UsesLicenseKeyAndDevice(t *testing.T) {
+	lic := &license.License{Key: "KEY", DeviceID: "dev"}
+	data, err := Pack(Prompts{"a": "x"}, lic.Key, []byte(lic.DeviceID))
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "prompts.enc")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(path, lic)
+	if err != nil || p["a"] != "x" {
+		t.Fatalf("Load = %v, %v", p, err)
+	}
+	if _, err := Load(path, nil); err == nil {
+		t.Error("expected Load without a license to fail")
+	}
+}
+
+func TestLoad_ShippedBundleOpensWithDevLicense(t *testing.T) {
+	lic, err := license.ReadFile("../../license.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load("../../"+DefaultPath, lic)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, key := range []string{"accountingClassifier", "userSummary", "dynamicFollowUp", "dynamicReport"} {
+		if _, ok := p[key]; !ok {
+			t.Errorf("shipped bundle lacks %s", key)
+		}
+	}
+}
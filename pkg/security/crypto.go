@@ -5,11 +5,9 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"io/ioutil"
 
 	"golang.org/x/crypto/scrypt"
@@ -21,54 +19,27 @@ const (
 )
 
 // deriveKey uses scrypt KDF to derive an AES key from the licenseKey, deviceID, and salt.
+// Only the legacy format uses it; envelopes record their KDF parameters.
 func deriveKey(licenseKey string, deviceID, salt []byte) ([]byte, error) {
 	// N=1<<15, r=8, p=1 as reasonable work factor
 	return scrypt.Key([]byte(licenseKey), append(deviceID, salt...), 1<<15, 8, 1, keyLen)
 }
 
-// Encrypt encrypts plaintext using AES-GCM. Returns salt||nonce||ciphertext (no auth tag separately).
+// Encrypt encrypts plaintext into a versioned envelope with DefaultKDF and no
+// associated data. See EncryptWith.
 func Encrypt(plaintext []byte, licenseKey string, deviceID []byte) ([]byte, error) {
-	// Generate random salt
-	salt := make([]byte, saltLen)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		return nil, fmt.Errorf("failed to generate salt: %w", err)
-	}
-
-	// Derive key
-	key, err := deriveKey(licenseKey, deviceID, salt)
-	if err != nil {
-		return nil, fmt.Errorf("key derivation failed: %w", err)
-	}
-
-	// Create AES-GCM
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
-	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
-	}
-
-	// Generate random nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, fmt.Errorf("failed to generate nonce: %w", err)
-	}
-
-	// Seal authenticates and encrypts
-	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
-
-	// Return concatenated: salt||nonce||ciphertext
-	out := make([]byte, 0, saltLen+len(nonce)+len(ciphertext))
-	out = append(out, salt...)
-	out = append(out, nonce...)
-	out = append(out, ciphertext...)
-	return out, nil
+	return EncryptWith(plaintext, licenseKey, deviceID, Options{})
 }
 
-// Decrypt decrypts data produced by Encrypt, verifying authenticity.
+// Decrypt decrypts data produced by Encrypt, verifying authenticity. It also
+// reads the legacy salt||nonce||ciphertext format.
 func Decrypt(data []byte, licenseKey string, deviceID []byte) ([]byte, error) {
+	return DecryptWith(data, licenseKey, deviceID, nil)
+}
+
+// decryptLegacy decrypts the original salt||nonce||ciphertext format, keyed
+// with scrypt at a fixed work factor.
+func decryptLegacy(data []byte, licenseKey string, deviceID []byte) ([]byte, error) {
 	if len(data) < saltLen {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
@@ -0,0 +1,65 @@
+// pkg/security/datakey.go
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// DataKeySize is the length of keys made by NewDataKey.
+const DataKeySize = keyLen
+
+// NewDataKey returns a random AES-256 key for envelope encryption: data is
+// sealed with it directly, and the key itself is stored wrapped with
+// EncryptWith under the license-derived key.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext with a data key using AES-GCM and returns
+// nonce||ciphertext. aad must be passed again to Open.
+func Seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := dataKeyCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open decrypts data made by Seal with the same key and aad.
+func Open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := dataKeyCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short for nonce")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+func dataKeyCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("data key must be %d bytes, got %d", DataKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
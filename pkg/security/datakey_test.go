@@ -0,0 +1,27 @@
+package security
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key, err := NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey: %v", err)
+	}
+	sealed, err := Seal(key, []byte("salary=91000"), []byte("form\x00alice"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	got, err := Open(key, sealed, []byte("form\x00alice"))
+	if err != nil || !bytes.Equal(got, []byte("salary=91000")) {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+	if _, err := Open(key, sealed, []byte("form\x00bob")); err == nil {
+		t.Error("expected Open to fail with different aad")
+	}
+	if _, err := Seal(key[:16], nil, nil); err == nil {
+		t.Error("expected Seal to reject a short key")
+	}
+}
@@ -0,0 +1,287 @@
+// pkg/security/envelope.go
+package security
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// An envelope is
+//
+//	magic(4) | version(1) | kdf(1) | len(params)(1) | params | len(salt)(1) | salt | nonce(12) | ciphertext
+//
+// The whole header, up to and including the nonce, is authenticated as GCM
+// associated data together with any caller-supplied associated data, so the
+// KDF parameters cannot be weakened without failing decryption.
+const (
+	envelopeMagic   = "VJSE"
+	envelopeVersion = 1
+)
+
+// KDF identifies the key derivation function of an envelope.
+type KDF byte
+
+const (
+	KDFScrypt   KDF = 1
+	KDFArgon2id KDF = 2
+)
+
+func (k KDF) String() string {
+	switch k {
+	case KDFScrypt:
+		return "scrypt"
+	case KDFArgon2id:
+		return "argon2id"
+	}
+	return fmt.Sprintf("kdf(%d)", byte(k))
+}
+
+// KDFParams selects a KDF and its work factor. N, R and P apply to scrypt;
+// Time, Memory (KiB) and Threads apply to Argon2id.
+type KDFParams struct {
+	KDF KDF
+
+	N, R, P uint32
+
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+var (
+	// ScryptParams matches the work factor of the legacy format.
+	ScryptParams = KDFParams{KDF: KDFScrypt, N: 1 << 15, R: 8, P: 1}
+	// Argon2idParams follows the second recommendation of RFC 9106.
+	Argon2idParams = KDFParams{KDF: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+	// DefaultKDF is used by Encrypt and by EncryptWith when Options.KDF is zero.
+	DefaultKDF = ScryptParams
+)
+
+// Upper bounds on parameters read from an envelope, so a crafted file cannot
+// make Decrypt allocate more than about 1 GiB. scrypt needs 128·N·r bytes.
+const (
+	maxScryptN      = 1 << 20
+	maxScryptRP     = 1 << 10
+	maxScryptMemory = 1 << 30 // bytes
+	maxArgon2Time   = 16
+	maxArgon2Memory = 1 << 20 // KiB, 1 GiB
+)
+
+// Options configures EncryptWith and EncryptStream.
+type Options struct {
+	KDF       KDFParams // zero value means DefaultKDF
+	AAD       []byte    // associated data; must be passed again to decrypt
+	ChunkSize int       // plaintext bytes per stream chunk; zero means DefaultChunkSize
+}
+
+// ErrNotEnvelope is returned by Inspect for data in the legacy format.
+var ErrNotEnvelope = errors.New("not a versioned envelope")
+
+// EncryptWith encrypts plaintext into an envelope using AES-256-GCM with a
+// key derived from licenseKey and deviceID by opts.KDF.
+func EncryptWith(plaintext []byte, licenseKey string, deviceID []byte, opts Options) ([]byte, error) {
+	params := opts.KDF
+	if params.KDF == 0 {
+		params = DefaultKDF
+	}
+	encoded, err := params.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	gcm, err := params.cipher(licenseKey, deviceID, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := []byte(envelopeMagic)
+	header = append(header, envelopeVersion, byte(params.KDF), byte(len(encoded)))
+	header = append(header, encoded...)
+	header = append(header, byte(len(salt)))
+	header = append(header, salt...)
+	header = append(header, nonce...)
+
+	return gcm.Seal(header, nonce, plaintext, associated(header, opts.AAD)), nil
+}
+
+// DecryptWith opens data made by EncryptWith with the same associated data,
+// or data in the legacy format when aad is empty.
+func DecryptWith(data []byte, licenseKey string, deviceID, aad []byte) ([]byte, error) {
+	h, err := parseEnvelope(data)
+	if errors.Is(err, ErrNotEnvelope) {
+		if len(aad) > 0 {
+			return nil, errors.New("legacy ciphertext cannot carry associated data")
+		}
+		return decryptLegacy(data, licenseKey, deviceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := h.params.cipher(licenseKey, deviceID, h.salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < h.size+gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short for nonce")
+	}
+	header := data[:h.size+gcm.NonceSize()]
+	nonce := header[h.size:]
+	plaintext, err := gcm.Open(nil, nonce, data[len(header):], associated(header, aad))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Inspect returns the KDF parameters of an envelope, or ErrNotEnvelope for
+// legacy data. Callers use it to find data worth re-encrypting with a
+// stronger work factor.
+func Inspect(data []byte) (KDFParams, error) {
+	h, err := parseEnvelope(data)
+	if err != nil {
+		return KDFParams{}, err
+	}
+	return h.params, nil
+}
+
+// envelopeHeader is a parsed header; size is the offset of the nonce.
+type envelopeHeader struct {
+	params KDFParams
+	salt   []byte
+	size   int
+}
+
+func parseEnvelope(data []byte) (*envelopeHeader, error) {
+	if len(data) < len(envelopeMagic)+3 || !bytes.HasPrefix(data, []byte(envelopeMagic)) {
+		return nil, ErrNotEnvelope
+	}
+	off := len(envelopeMagic)
+	if v := data[off]; v != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", v)
+	}
+	kdf, n := KDF(data[off+1]), int(data[off+2])
+	off += 3
+	if len(data) < off+n+1 {
+		return nil, fmt.Errorf("envelope header truncated")
+	}
+	params, err := decodeParams(kdf, data[off:off+n])
+	if err != nil {
+		return nil, err
+	}
+	off += n
+	saltSize := int(data[off])
+	off++
+	if len(data) < off+saltSize {
+		return nil, fmt.Errorf("envelope header truncated")
+	}
+	return &envelopeHeader{params: params, salt: data[off : off+saltSize], size: off + saltSize}, nil
+}
+
+func (p KDFParams) encode() ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	switch p.KDF {
+	case KDFScrypt:
+		b := binary.BigEndian.AppendUint32(nil, p.N)
+		b = binary.BigEndian.AppendUint32(b, p.R)
+		return binary.BigEndian.AppendUint32(b, p.P), nil
+	default: // KDFArgon2id
+		b := binary.BigEndian.AppendUint32(nil, p.Time)
+		b = binary.BigEndian.AppendUint32(b, p.Memory)
+		return append(b, p.Threads), nil
+	}
+}
+
+func decodeParams(kdf KDF, b []byte) (KDFParams, error) {
+	p := KDFParams{KDF: kdf}
+	switch {
+	case kdf == KDFScrypt && len(b) == 12:
+		p.N = binary.BigEndian.Uint32(b)
+		p.R = binary.BigEndian.Uint32(b[4:])
+		p.P = binary.BigEndian.Uint32(b[8:])
+	case kdf == KDFArgon2id && len(b) == 9:
+		p.Time = binary.BigEndian.Uint32(b)
+		p.Memory = binary.BigEndian.Uint32(b[4:])
+		p.Threads = b[8]
+	default:
+		return p, fmt.Errorf("unsupported %v parameters (%d bytes)", kdf, len(b))
+	}
+	return p, p.check()
+}
+
+// check rejects parameters outside the supported bounds.
+func (p KDFParams) check() error {
+	switch p.KDF {
+	case KDFScrypt:
+		if p.N < 2 || p.N > maxScryptN || p.N&(p.N-1) != 0 {
+			return fmt.Errorf("scrypt N must be a power of two up to %d, got %d", maxScryptN, p.N)
+		}
+		if p.R == 0 || p.P == 0 || p.R > maxScryptRP || p.P > maxScryptRP || p.R*p.P > maxScryptRP {
+			return fmt.Errorf("scrypt r=%d p=%d out of range", p.R, p.P)
+		}
+		if mem := 128 * uint64(p.N) * uint64(p.R); mem > maxScryptMemory {
+			return fmt.Errorf("scrypt N=%d r=%d needs %d MiB, more than %d", p.N, p.R, mem>>20, maxScryptMemory>>20)
+		}
+	case KDFArgon2id:
+		if p.Time == 0 || p.Time > maxArgon2Time || p.Threads == 0 {
+			return fmt.Errorf("argon2id time=%d threads=%d out of range", p.Time, p.Threads)
+		}
+		if p.Memory < 8*uint32(p.Threads) || p.Memory > maxArgon2Memory {
+			return fmt.Errorf("argon2id memory %d KiB out of range", p.Memory)
+		}
+	default:
+		return fmt.Errorf("unsupported KDF %v", p.KDF)
+	}
+	return nil
+}
+
+// cipher derives the key for salt and returns the AES-GCM AEAD.
+func (p KDFParams) cipher(licenseKey string, deviceID, salt []byte) (cipher.AEAD, error) {
+	// Copy so appending the salt never writes into the caller's deviceID.
+	s := append(append([]byte{}, deviceID...), salt...)
+	var key []byte
+	switch p.KDF {
+	case KDFScrypt:
+		k, err := scrypt.Key([]byte(licenseKey), s, int(p.N), int(p.R), int(p.P), keyLen)
+		if err != nil {
+			return nil, fmt.Errorf("key derivation failed: %w", err)
+		}
+		key = k
+	case KDFArgon2id:
+		key = argon2.IDKey([]byte(licenseKey), s, p.Time, p.Memory, p.Threads, keyLen)
+	default:
+		return nil, fmt.Errorf("unsupported KDF %v", p.KDF)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// associated binds the header and the caller's associated data.
+func associated(header, aad []byte) []byte {
+	return append(append([]byte{}, header...), aad...)
+}
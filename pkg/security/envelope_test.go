@@ -0,0 +1,100 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// encryptLegacy writes the original salt||nonce||ciphertext format.
+func encryptLegacy(t *testing.T, plaintext []byte, licenseKey string, deviceID []byte) []byte {
+	t.Helper()
+	salt := make([]byte, saltLen)
+	rand.Read(salt)
+	key, err := deriveKey(licenseKey, deviceID, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	return gcm.Seal(append(salt, nonce...), nonce, plaintext, nil)
+}
+
+func TestDecrypt_ReadsLegacyFormat(t *testing.T) {
+	data := encryptLegacy(t, []byte("old secret"), "LIC", []byte("dev"))
+	if _, err := Inspect(data); !errors.Is(err, ErrNotEnvelope) {
+		t.Fatalf("expected legacy data not to parse as an envelope, got %v", err)
+	}
+	got, err := Decrypt(data, "LIC", []byte("dev"))
+	if err != nil || string(got) != "old secret" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+}
+
+func TestEncryptWith_Argon2id(t *testing.T) {
+	params := KDFParams{KDF: KDFArgon2id, Time: 1, Memory: 8 * 1024, Threads: 1}
+	data, err := EncryptWith([]byte("hi"), "LIC", []byte("dev"), Options{KDF: params})
+	if err != nil {
+		t.Fatalf("EncryptWith: %v", err)
+	}
+	if got, err := Inspect(data); err != nil || got != params {
+		t.Fatalf("Inspect = %+v, %v", got, err)
+	}
+	got, err := Decrypt(data, "LIC", []byte("dev"))
+	if err != nil || string(got) != "hi" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+}
+
+func TestDecryptWith_AssociatedData(t *testing.T) {
+	data, err := EncryptWith([]byte("hi"), "LIC", nil, Options{AAD: []byte("file-a")})
+	if err != nil {
+		t.Fatalf("EncryptWith: %v", err)
+	}
+	if _, err := DecryptWith(data, "LIC", nil, []byte("file-a")); err != nil {
+		t.Fatalf("DecryptWith: %v", err)
+	}
+	if _, err := DecryptWith(data, "LIC", nil, []byte("file-b")); err == nil {
+		t.Error("expected other associated data to fail")
+	}
+	if _, err := Decrypt(data, "LIC", nil); err == nil {
+		t.Error("expected missing associated data to fail")
+	}
+}
+
+func TestDecrypt_HeaderIsAuthenticated(t *testing.T) {
+	data, err := EncryptWith([]byte("hi"), "LIC", nil, Options{KDF: KDFParams{KDF: KDFScrypt, N: 1 << 10, R: 8, P: 1}})
+	if err != nil {
+		t.Fatalf("EncryptWith: %v", err)
+	}
+	// Lower N in the header: the key changes and the tag no longer matches.
+	off := len(envelopeMagic) + 3
+	binary.BigEndian.PutUint32(data[off:], 1<<9)
+	if _, err := Decrypt(data, "LIC", nil); err == nil {
+		t.Error("expected an edited header to fail")
+	}
+}
+
+func TestDecrypt_RejectsExcessiveParams(t *testing.T) {
+	data, err := Encrypt([]byte("hi"), "LIC", nil)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	off := len(envelopeMagic) + 3
+	binary.BigEndian.PutUint32(data[off:], 1<<30)
+	if _, err := Inspect(data); err == nil {
+		t.Error("expected scrypt N=2^30 to be rejected")
+	}
+
+	// Each bound alone allows N=2^20 with r=16, which needs 2 GiB.
+	binary.BigEndian.PutUint32(data[off:], 1<<20)
+	binary.BigEndian.PutUint32(data[off+4:], 16)
+	if _, err := Inspect(data); err == nil {
+		t.Error("expected scrypt N=2^20 r=16 to be rejected")
+	}
+}
@@ -0,0 +1,269 @@
+// pkg/security/stream.go
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A stream is a header followed by AES-256-GCM chunks:
+//
+//	magic(4) | version(1) | kdf(1) | len(params)(1) | params | len(salt)(1) | salt | prefix(7) | chunkSize(4)
+//	chunk_0 | chunk_1 | ... | chunk_n
+//
+// Every chunk but the last holds exactly chunkSize bytes of plaintext; the
+// last holds 0..chunkSize. Chunk i is sealed with the nonce
+// prefix || i (4 bytes) || last (1 byte) and the header as associated data,
+// so reordered, dropped or appended chunks and a stream cut at a chunk
+// boundary all fail to open.
+const (
+	streamMagic      = "VJSS"
+	streamVersion    = 1
+	streamPrefixLen  = 7
+	DefaultChunkSize = 64 * 1024
+	maxChunkSize     = 16 << 20
+)
+
+// ErrTruncated means a stream ended before its final chunk.
+var ErrTruncated = errors.New("encrypted stream is truncated")
+
+// EncryptStream returns a writer that encrypts everything written to it
+// onto w, with a key derived from licenseKey and deviceID as in EncryptWith.
+// Close must be called to write the final chunk; it does not close w.
+func EncryptStream(w io.Writer, licenseKey string, deviceID []byte, opts Options) (io.WriteCloser, error) {
+	params := opts.KDF
+	if params.KDF == 0 {
+		params = DefaultKDF
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < 0 || chunkSize > maxChunkSize {
+		return nil, fmt.Errorf("chunk size %d out of range", chunkSize)
+	}
+	encoded, err := params.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltLen)
+	prefix := make([]byte, streamPrefixLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+	gcm, err := params.cipher(licenseKey, deviceID, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte(streamMagic)
+	header = append(header, streamVersion, byte(params.KDF), byte(len(encoded)))
+	header = append(header, encoded...)
+	header = append(header, byte(len(salt)))
+	header = append(header, salt...)
+	header = append(header, prefix...)
+	header = binary.BigEndian.AppendUint32(header, uint32(chunkSize))
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &streamWriter{
+		w:      w,
+		gcm:    gcm,
+		aad:    associated(header, opts.AAD),
+		prefix: prefix,
+		size:   chunkSize,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+type streamWriter struct {
+	w      io.Writer
+	gcm    cipher.AEAD
+	aad    []byte
+	prefix []byte
+	size   int
+	buf    []byte
+	seq    uint32
+	err    error
+	closed bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed encrypted stream")
+	}
+	n := 0
+	for len(p) > 0 && s.err == nil {
+		// A full buffer is only flushed once more data arrives, so the
+		// final chunk is always written by Close.
+		if len(s.buf) == s.size {
+			s.err = s.flush(false)
+			continue
+		}
+		c := copy(s.buf[len(s.buf):s.size], p)
+		s.buf = s.buf[:len(s.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, s.err
+}
+
+// Close writes the final chunk.
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return s.err
+	}
+	s.closed = true
+	if s.err == nil {
+		s.err = s.flush(true)
+	}
+	return s.err
+}
+
+func (s *streamWriter) flush(last bool) error {
+	if s.seq == ^uint32(0) {
+		return errors.New("encrypted stream has too many chunks")
+	}
+	sealed := s.gcm.Seal(nil, chunkNonce(s.prefix, s.seq, last), s.buf, s.aad)
+	s.seq++
+	s.buf = s.buf[:0]
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+// DecryptStream returns a reader of the plaintext of a stream written by
+// EncryptStream with the same associated data. Each chunk is authenticated
+// before its plaintext is returned, but a truncated or reordered stream is
+// only reported when the damaged chunk is reached, so callers must treat
+// output as untrusted until Read returns io.EOF.
+func DecryptStream(r io.Reader, licenseKey string, deviceID, aad []byte) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	fixed := make([]byte, len(streamMagic)+3)
+	if _, err := io.ReadFull(br, fixed); err != nil {
+		return nil, fmt.Errorf("reading stream header: %w", err)
+	}
+	if !bytes.HasPrefix(fixed, []byte(streamMagic)) {
+		return nil, errors.New("not an encrypted stream")
+	}
+	if v := fixed[len(streamMagic)]; v != streamVersion {
+		return nil, fmt.Errorf("unsupported stream version %d", v)
+	}
+	encoded := make([]byte, fixed[len(streamMagic)+2])
+	if _, err := io.ReadFull(br, encoded); err != nil {
+		return nil, fmt.Errorf("reading stream header: %w", err)
+	}
+	params, err := decodeParams(KDF(fixed[len(streamMagic)+1]), encoded)
+	if err != nil {
+		return nil, err
+	}
+	saltSize, err := br.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("reading stream header: %w", err)
+	}
+	rest := make([]byte, int(saltSize)+streamPrefixLen+4)
+	if _, err := io.ReadFull(br, rest); err != nil {
+		return nil, fmt.Errorf("reading stream header: %w", err)
+	}
+	salt := rest[:saltSize]
+	prefix := rest[saltSize : int(saltSize)+streamPrefixLen]
+	chunkSize := int(binary.BigEndian.Uint32(rest[int(saltSize)+streamPrefixLen:]))
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		return nil, fmt.Errorf("chunk size %d out of range", chunkSize)
+	}
+
+	gcm, err := params.cipher(licenseKey, deviceID, salt)
+	if err != nil {
+		return nil, err
+	}
+	header := append(append(append([]byte{}, fixed...), encoded...), saltSize)
+	header = append(header, rest...)
+	return &streamReader{
+		r:      br,
+		gcm:    gcm,
+		aad:    associated(header, aad),
+		prefix: prefix,
+		chunk:  make([]byte, chunkSize+gcm.Overhead()),
+	}, nil
+}
+
+type streamReader struct {
+	r      *bufio.Reader
+	gcm    cipher.AEAD
+	aad    []byte
+	prefix []byte
+	chunk  []byte
+	plain  []byte
+	seq    uint32
+	done   bool
+	err    error
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			s.err = io.EOF
+			continue
+		}
+		s.err = s.next()
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+// next reads and opens one chunk. A short read, or a full one with nothing
+// after it, must be the final chunk.
+func (s *streamReader) next() error {
+	n, err := io.ReadFull(s.r, s.chunk)
+	last := false
+	switch {
+	case err == io.EOF:
+		return ErrTruncated
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, perr := s.r.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			return perr
+		}
+	}
+	sealed := s.chunk[:n]
+	plain, err := s.gcm.Open(nil, chunkNonce(s.prefix, s.seq, last), sealed, s.aad)
+	if err != nil {
+		if last {
+			// Sealed as an intermediate chunk: the rest of the stream is missing.
+			if _, err := s.gcm.Open(nil, chunkNonce(s.prefix, s.seq, false), sealed, s.aad); err == nil {
+				return ErrTruncated
+			}
+		}
+		return fmt.Errorf("chunk %d: decryption failed: %w", s.seq, err)
+	}
+	s.seq++
+	s.plain = plain
+	s.done = last
+	return nil
+}
+
+func chunkNonce(prefix []byte, seq uint32, last bool) []byte {
+	nonce := binary.BigEndian.AppendUint32(append([]byte{}, prefix...), seq)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
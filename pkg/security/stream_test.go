@@ -0,0 +1,96 @@
+package security
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// fastKDF keeps the tests quick; the format is the same at any work factor.
+var fastKDF = KDFParams{KDF: KDFScrypt, N: 1 << 10, R: 8, P: 1}
+
+func encryptTestStream(t *testing.T, plaintext []byte, chunkSize int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := EncryptStream(&buf, "LIC", []byte("dev"), Options{KDF: fastKDF, ChunkSize: chunkSize})
+	if err != nil {
+		t.Fatalf("EncryptStream: %v", err)
+	}
+	// Write in odd-sized pieces to cross chunk boundaries.
+	for p := plaintext; len(p) > 0; {
+		n := 7
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decryptTestStream(data []byte) ([]byte, error) {
+	r, err := DecryptStream(bytes.NewReader(data), "LIC", []byte("dev"), nil)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 31, 32, 33, 100} {
+		plaintext := bytes.Repeat([]byte{'x'}, size)
+		got, err := decryptTestStream(encryptTestStream(t, plaintext, 32))
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: got %d bytes, %v", size, len(got), err)
+		}
+	}
+}
+
+func TestStream_DetectsTruncation(t *testing.T) {
+	data := encryptTestStream(t, bytes.Repeat([]byte{'x'}, 100), 32)
+	chunk := 32 + 16
+	// Cut after the second of four chunks, on a chunk boundary.
+	cut := data[:len(data)-chunk-(100-96+16)]
+	if _, err := decryptTestStream(cut); !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+	if _, err := decryptTestStream(data[:len(data)-1]); err == nil {
+		t.Error("expected a partial final chunk to fail")
+	}
+}
+
+func TestStream_DetectsReordering(t *testing.T) {
+	plaintext := append(bytes.Repeat([]byte{'a'}, 32), bytes.Repeat([]byte{'b'}, 40)...)
+	data := encryptTestStream(t, plaintext, 32)
+	chunk := 32 + 16
+	last := len(data) - (8 + 16)
+	first := last - 2*chunk
+	swapped := append([]byte{}, data[:first]...)
+	swapped = append(swapped, data[first+chunk:last]...)
+	swapped = append(swapped, data[first:first+chunk]...)
+	swapped = append(swapped, data[last:]...)
+	if _, err := decryptTestStream(swapped); err == nil {
+		t.Error("expected swapped chunks to fail")
+	}
+}
+
+func TestStream_WrongKeyOrAAD(t *testing.T) {
+	data := encryptTestStream(t, []byte("backup"), 32)
+	r, err := DecryptStream(bytes.NewReader(data), "OTHER", []byte("dev"), nil)
+	if err != nil {
+		t.Fatalf("DecryptStream: %v", err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("expected another license key to fail")
+	}
+	r, _ = DecryptStream(bytes.NewReader(data), "LIC", []byte("dev"), []byte("x"))
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("expected unexpected associated data to fail")
+	}
+}
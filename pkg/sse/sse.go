@@ -0,0 +1,49 @@
+// pkg/sse/sse.go
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrUnsupported is returned when the ResponseWriter cannot flush, so
+// events would only arrive once the handler returns.
+var ErrUnsupported = errors.New("sse: response writer does not support flushing")
+
+// Writer sends server-sent events. Each event's data is one line of JSON.
+type Writer struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+// NewWriter sets the event-stream headers on w. Write any HTTP error
+// before calling it; afterwards errors can only be sent as events.
+func NewWriter(w http.ResponseWriter) (*Writer, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // keep reverse proxies from buffering
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	return &Writer{w: w, f: f}, nil
+}
+
+// Send writes one event named event with data encoded as JSON, and
+// flushes it to the client.
+func (s *Writer) Send(event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("sse: encoding %s event: %w", event, err)
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	s.f.Flush()
+	return nil
+}
@@ -0,0 +1,27 @@
+package sse
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriter_Send(t *testing.T) {
+	rec := httptest.NewRecorder()
+	s, err := NewWriter(rec)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	s.Send("delta", map[string]string{"text": "hi\nthere"})
+	s.Send("done", struct{}{})
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	want := "event: delta\ndata: {\"text\":\"hi\\nthere\"}\n\nevent: done\ndata: {}\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	if !rec.Flushed {
+		t.Error("events were not flushed")
+	}
+}
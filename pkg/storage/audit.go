@@ -0,0 +1,172 @@
+// pkg/storage/audit.go
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// createAudit creates the append-only audit log. AUTOINCREMENT keeps seq
+// from being reused, so sqlite_sequence remembers the highest entry ever
+// written and VerifyAudit can notice entries deleted from the end.
+const createAudit = `
+CREATE TABLE IF NOT EXISTS audit_log (
+  seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+  ts          TEXT NOT NULL,
+  actor       TEXT NOT NULL,
+  action      TEXT NOT NULL,
+  prompt_key  TEXT NOT NULL,
+  input_hash  TEXT NOT NULL,
+  output_hash TEXT NOT NULL,
+  detail      TEXT NOT NULL,
+  prev_hash   TEXT NOT NULL,
+  hash        TEXT NOT NULL
+);`
+
+// AuditEntry is one record of the audit log. Each entry's Hash covers its
+// fields and the Hash of the entry before it, so editing or deleting an
+// entry breaks the chain from that point on.
+type AuditEntry struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	PromptKey  string    `json:"promptKey,omitempty"`
+	InputHash  string    `json:"inputHash,omitempty"`
+	OutputHash string    `json:"outputHash,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	PrevHash   string    `json:"prevHash"`
+	Hash       string    `json:"hash"`
+}
+
+// AuditError reports the first entry at which the chain is broken.
+type AuditError struct {
+	Seq    int64
+	Reason string
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("audit log entry %d: %s", e.Seq, e.Reason)
+}
+
+// HashContent returns the hex SHA-256 of b, the form audit entries use for
+// inputs and outputs.
+func HashContent(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// chainHash is the SHA-256 of e's JSON with Hash cleared.
+func (e AuditEntry) chainHash() string {
+	e.Hash = ""
+	payload, _ := json.Marshal(e)
+	return HashContent(payload)
+}
+
+// AppendAudit adds e to the end of the log and returns it with Seq, Time
+// (if unset), PrevHash and Hash filled in.
+func (s *Store) AppendAudit(e AuditEntry) (AuditEntry, error) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return e, fmt.Errorf("failed to begin audit append: %w", err)
+	}
+	defer tx.Rollback()
+
+	const last = `SELECT hash FROM audit_log ORDER BY seq DESC LIMIT 1;`
+	if err := tx.QueryRow(last).Scan(&e.PrevHash); err != nil && err != sql.ErrNoRows {
+		return e, fmt.Errorf("failed to read audit head: %w", err)
+	}
+	const next = `SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'audit_log'), 0) + 1;`
+	if err := tx.QueryRow(next).Scan(&e.Seq); err != nil {
+		return e, fmt.Errorf("failed to read audit sequence: %w", err)
+	}
+	e.Hash = e.chainHash()
+
+	const stmt = `
+INSERT INTO audit_log (seq, ts, actor, action, prompt_key, input_hash, output_hash, detail, prev_hash, hash)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := tx.Exec(stmt, e.Seq, e.Time.Format(time.RFC3339Nano), e.Actor, e.Action, e.PromptKey,
+		e.InputHash, e.OutputHash, e.Detail, e.PrevHash, e.Hash); err != nil {
+		return e, fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return e, fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	return e, nil
+}
+
+// AuditEntries returns entries with Seq >= from, oldest first, at most limit
+// of them (0 means all).
+func (s *Store) AuditEntries(from int64, limit int) ([]AuditEntry, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	const query = `
+SELECT seq, ts, actor, action, prompt_key, input_hash, output_hash, detail, prev_hash, hash
+FROM audit_log WHERE seq >= ? ORDER BY seq LIMIT ?;`
+	rows, err := s.db.Query(query, from, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var ts string
+		if err := rows.Scan(&e.Seq, &ts, &e.Actor, &e.Action, &e.PromptKey, &e.InputHash,
+			&e.OutputHash, &e.Detail, &e.PrevHash, &e.Hash); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if e.Time, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return nil, fmt.Errorf("audit entry %d: invalid time %q", e.Seq, ts)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// VerifyAudit walks the whole log and returns the hash of its last entry, or
+// an *AuditError at the first entry that was edited, inserted or follows a
+// deleted one. Deleting the newest entries is caught while sqlite_sequence
+// is intact; to detect a rewrite of the whole table, compare the returned
+// head with one recorded elsewhere.
+func (s *Store) VerifyAudit() (string, error) {
+	entries, err := s.AuditEntries(0, 0)
+	if err != nil {
+		return "", err
+	}
+	head, seq := "", int64(0)
+	for _, e := range entries {
+		switch {
+		case e.Seq != seq+1:
+			return "", &AuditError{Seq: seq + 1, Reason: "entry is missing"}
+		case e.PrevHash != head:
+			return "", &AuditError{Seq: e.Seq, Reason: "previous hash does not match"}
+		case e.chainHash() != e.Hash:
+			return "", &AuditError{Seq: e.Seq, Reason: "contents do not match hash"}
+		}
+		head, seq = e.Hash, e.Seq
+	}
+
+	var highest int64
+	const query = `SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'audit_log'), 0);`
+	if err := s.db.QueryRow(query).Scan(&highest); err != nil {
+		return "", fmt.Errorf("failed to read audit sequence: %w", err)
+	}
+	if highest > seq {
+		return "", &AuditError{Seq: seq + 1, Reason: fmt.Sprintf("entries %d..%d are missing", seq+1, highest)}
+	}
+	return head, nil
+}
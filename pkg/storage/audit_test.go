@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func newAuditStore(t *testing.T, n int) *Store {
+	t.Helper()
+	store, err := New(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := store.AppendAudit(AuditEntry{Actor: "tester", Action: "submit", InputHash: HashContent([]byte{byte(i)})}); err != nil {
+			t.Fatalf("AppendAudit: %v", err)
+		}
+	}
+	return store
+}
+
+func TestAudit_ChainVerifies(t *testing.T) {
+	store := newAuditStore(t, 3)
+	entries, err := store.AuditEntries(0, 0)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("AuditEntries = %d, %v", len(entries), err)
+	}
+	if entries[0].PrevHash != "" || entries[2].PrevHash != entries[1].Hash {
+		t.Errorf("entries are not chained: %+v", entries)
+	}
+	head, err := store.VerifyAudit()
+	if err != nil || head != entries[2].Hash {
+		t.Fatalf("VerifyAudit = %q, %v", head, err)
+	}
+}
+
+func TestAudit_DetectsEdits(t *testing.T) {
+	store := newAuditStore(t, 3)
+	store.db.Exec(`UPDATE audit_log SET actor = 'mallory' WHERE seq = 2`)
+	var aerr *AuditError
+	if _, err := store.VerifyAudit(); !errors.As(err, &aerr) || aerr.Seq != 2 {
+		t.Fatalf("expected an *AuditError at entry 2, got %v", err)
+	}
+}
+
+func TestAudit_DetectsDeletions(t *testing.T) {
+	store := newAuditStore(t, 3)
+	store.db.Exec(`DELETE FROM audit_log WHERE seq = 2`)
+	var aerr *AuditError
+	if _, err := store.VerifyAudit(); !errors.As(err, &aerr) || aerr.Seq != 2 {
+		t.Fatalf("expected an *AuditError at entry 2, got %v", err)
+	}
+
+	store = newAuditStore(t, 3)
+	store.db.Exec(`DELETE FROM audit_log WHERE seq = 3`)
+	if _, err := store.VerifyAudit(); !errors.As(err, &aerr) || aerr.Seq != 3 {
+		t.Fatalf("expected deleting the newest entry to be caught, got %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+// pkg/storage/encryption.go
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/security"
+)
+
+// createDataKeys holds the data keys that encrypt state rows, each wrapped
+// with a key derived from the license. Exactly one is active for new writes.
+const createDataKeys = `
+CREATE TABLE IF NOT EXISTS data_keys (
+  id      INTEGER PRIMARY KEY AUTOINCREMENT,
+  wrapped BLOB NOT NULL,
+  created TEXT NOT NULL,
+  active  INTEGER NOT NULL DEFAULT 0
+);`
+
+// An encrypted data value is encPrefix + "<key id>:" + base64(nonce||ciphertext).
+// Values without the prefix are plaintext JSON from before encryption was
+// turned on, and still load.
+const encPrefix = "enc1:"
+
+// dataKeyAAD binds wrapped data keys to this use of the license-derived key.
+var dataKeyAAD = []byte("vjal-storage-data-key-v1")
+
+// ErrNotEncrypted is returned by key management methods of a Store opened
+// without WithEncryption.
+var ErrNotEncrypted = errors.New("storage: encryption is not enabled")
+
+// Option customises a Store opened by New.
+type Option func(*Store) error
+
+// encryption is the key state of a Store opened WithEncryption.
+type encryption struct {
+	mu         sync.RWMutex
+	licenseKey string
+	deviceID   []byte
+	keys       map[int64][]byte // unwrapped data keys by id
+	active     int64
+	rows       bool // seal whole rows, not only sensitive fields
+}
+
+// WithEncryption encrypts the data column of the state table. Rows are
+// sealed with a random data key; data keys are stored wrapped with
+// security.EncryptWith under licenseKey and deviceID, so a new license only
+// needs RewrapDataKeys, not a rewrite of every row.
+func WithEncryption(licenseKey string, deviceID []byte) Option {
+	return withDataKeys(licenseKey, deviceID, true)
+}
+
+// WithFieldEncryption loads the same data keys as WithEncryption but leaves
+// rows in plaintext; only fields saved as sensitive with SaveFields are
+// sealed.
+func WithFieldEncryption(licenseKey string, deviceID []byte) Option {
+	return withDataKeys(licenseKey, deviceID, false)
+}
+
+func withDataKeys(licenseKey string, deviceID []byte, rows bool) Option {
+	return func(s *Store) error {
+		s.enc = &encryption{licenseKey: licenseKey, deviceID: deviceID, keys: map[int64][]byte{}, rows: rows}
+		if err := s.loadDataKeys(); err != nil {
+			return err
+		}
+		if s.enc.active == 0 {
+			_, err := s.RotateDataKey()
+			return err
+		}
+		return nil
+	}
+}
+
+func (s *Store) loadDataKeys() error {
+	rows, err := s.db.Query(`SELECT id, wrapped, active FROM data_keys;`)
+	if err != nil {
+		return fmt.Errorf("failed to query data keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var wrapped []byte
+		var active bool
+		if err := rows.Scan(&id, &wrapped, &active); err != nil {
+			return fmt.Errorf("failed to scan data key: %w", err)
+		}
+		key, err := security.DecryptWith(wrapped, s.enc.licenseKey, s.enc.deviceID, dataKeyAAD)
+		if err != nil {
+			return fmt.Errorf("cannot unwrap data key %d (wrapped for another license or device?): %w", id, err)
+		}
+		s.enc.keys[id] = key
+		if active {
+			s.enc.active = id
+		}
+	}
+	return rows.Err()
+}
+
+// RotateDataKey makes a new data key active for all later writes and
+// returns its id. Existing rows keep their key until Reencrypt.
+func (s *Store) RotateDataKey() (int64, error) {
+	if s.enc == nil {
+		return 0, ErrNotEncrypted
+	}
+	key, err := security.NewDataKey()
+	if err != nil {
+		return 0, err
+	}
+	s.enc.mu.RLock()
+	licenseKey, deviceID := s.enc.licenseKey, s.enc.deviceID
+	s.enc.mu.RUnlock()
+	wrapped, err := security.EncryptWith(key, licenseKey, deviceID, security.Options{AAD: dataKeyAAD})
+	if err != nil {
+		return 0, err
+	}
+
+	s.enc.mu.Lock()
+	defer s.enc.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to rotate data key: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE data_keys SET active = 0;`); err != nil {
+		return 0, fmt.Errorf("failed to rotate data key: %w", err)
+	}
+	var id int64
+	const stmt = `INSERT INTO data_keys (wrapped, created, active) VALUES (?, ?, 1) RETURNING id;`
+	if err := tx.QueryRow(stmt, wrapped, time.Now().UTC().Format(time.RFC3339)).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to store data key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to rotate data key: %w", err)
+	}
+	s.enc.keys[id] = key
+	s.enc.active = id
+	return id, nil
+}
+
+// Reencrypt rewrites every state row that is plaintext or sealed with an
+// older data key under the active key, then deletes the unused keys. Sealed
+// fields are re-sealed the same way. Without whole-row encryption, rows
+// that were encrypted earlier are written back in plaintext. Cached LLM
+// responses sealed with an older key are dropped rather than rewritten. It
+// returns the number of state rows rewritten.
+func (s *Store) Reencrypt() (int, error) {
+	if s.enc == nil {
+		return 0, ErrNotEncrypted
+	}
+	rows, err := s.db.Query(`SELECT namespace, item_key, data FROM state;`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query state: %w", err)
+	}
+	type row struct{ namespace, key, data string }
+	var all []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.namespace, &r.key, &r.data); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan state: %w", err)
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	active := s.activeKeyID()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to re-encrypt state: %w", err)
+	}
+	defer tx.Rollback()
+	stale := 0
+	for _, r := range all {
+		plain, err := s.decodeData(r.namespace, r.key, r.data)
+		if err != nil {
+			return 0, err
+		}
+		plain, resealed, err := s.resealFields(r.namespace, r.key, plain, active)
+		if err != nil {
+			return 0, err
+		}
+		id, encrypted := encryptedKeyID(r.data)
+		if !resealed && encrypted == s.enc.rows && (!encrypted || id == active) {
+			continue
+		}
+		stale++
+		data, err := s.encodeData(r.namespace, r.key, plain)
+		if err != nil {
+			return 0, err
+		}
+		const stmt = `UPDATE state SET data = ? WHERE namespace = ? AND item_key = ?;`
+		if _, err := tx.Exec(stmt, data, r.namespace, r.key); err != nil {
+			return 0, fmt.Errorf("failed to re-encrypt state: %w", err)
+		}
+	}
+	const purge = `DELETE FROM llm_cache WHERE data LIKE ? AND data NOT LIKE ?;`
+	if _, err := tx.Exec(purge, encPrefix+"%", encPrefix+strconv.FormatInt(active, 10)+":%"); err != nil {
+		return 0, fmt.Errorf("failed to purge stale cache entries: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM data_keys WHERE id != ?;`, active); err != nil {
+		return 0, fmt.Errorf("failed to delete old data keys: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to re-encrypt state: %w", err)
+	}
+
+	s.enc.mu.Lock()
+	for id := range s.enc.keys {
+		if id != active {
+			delete(s.enc.keys, id)
+		}
+	}
+	s.enc.mu.Unlock()
+	return stale, nil
+}
+
+// RewrapDataKeys wraps every data key under a new license key and device
+// ID, for example after a license renewal. No state rows change.
+func (s *Store) RewrapDataKeys(licenseKey string, deviceID []byte) error {
+	if s.enc == nil {
+		return ErrNotEncrypted
+	}
+	s.enc.mu.Lock()
+	defer s.enc.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to rewrap data keys: %w", err)
+	}
+	defer tx.Rollback()
+	for id, key := range s.enc.keys {
+		wrapped, err := security.EncryptWith(key, licenseKey, deviceID, security.Options{AAD: dataKeyAAD})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE data_keys SET wrapped = ? WHERE id = ?;`, wrapped, id); err != nil {
+			return fmt.Errorf("failed to rewrap data key %d: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to rewrap data keys: %w", err)
+	}
+	s.enc.licenseKey, s.enc.deviceID = licenseKey, deviceID
+	return nil
+}
+
+func (s *Store) activeKeyID() int64 {
+	s.enc.mu.RLock()
+	defer s.enc.mu.RUnlock()
+	return s.enc.active
+}
+
+// encryptedKeyID returns the data key id of an encrypted value.
+func encryptedKeyID(data string) (int64, bool) {
+	rest, ok := strings.CutPrefix(data, encPrefix)
+	if !ok {
+		return 0, false
+	}
+	idStr, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	return id, err == nil
+}
+
+// rowAAD binds a sealed value to its row, so values cannot be swapped
+// between keys.
+func rowAAD(namespace, key string) []byte {
+	return []byte(namespace + "\x00" + key)
+}
+
+// encodeData returns the value to store for plain JSON: sealed with the
+// active data key when encryption is on, else the JSON itself.
+func (s *Store) encodeData(namespace, key string, plain []byte) (string, error) {
+	if s.enc == nil || !s.enc.rows {
+		return string(plain), nil
+	}
+	s.enc.mu.RLock()
+	id, dek := s.enc.active, s.enc.keys[s.enc.active]
+	s.enc.mu.RUnlock()
+	sealed, err := security.Seal(dek, plain, rowAAD(namespace, key))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt state: %w", err)
+	}
+	return encPrefix + strconv.FormatInt(id, 10) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decodeData reverses encodeData, accepting plaintext rows too.
+func (s *Store) decodeData(namespace, key, data string) ([]byte, error) {
+	id, ok := encryptedKeyID(data)
+	if !ok {
+		return []byte(data), nil
+	}
+	if s.enc == nil {
+		return nil, fmt.Errorf("state %s/%s is encrypted: %w", namespace, key, ErrNotEncrypted)
+	}
+	s.enc.mu.RLock()
+	dek, ok := s.enc.keys[id]
+	s.enc.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("state %s/%s: unknown data key %d", namespace, key, id)
+	}
+	_, b64, _ := strings.Cut(strings.TrimPrefix(data, encPrefix), ":")
+	sealed, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("state %s/%s: malformed ciphertext", namespace, key)
+	}
+	plain, err := security.Open(dek, sealed, rowAAD(namespace, key))
+	if err != nil {
+		return nil, fmt.Errorf("state %s/%s: %w", namespace, key, err)
+	}
+	return plain, nil
+}
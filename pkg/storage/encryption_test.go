@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type answers struct {
+	Salary int `json:"salary"`
+}
+
+func openEncrypted(t *testing.T, path, licenseKey string) *Store {
+	t.Helper()
+	store, err := New(path, WithEncryption(licenseKey, []byte("device-1")))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return store
+}
+
+func rawData(t *testing.T, s *Store, ns, key string) string {
+	t.Helper()
+	var data string
+	if err := s.db.QueryRow(`SELECT data FROM state WHERE namespace = ? AND item_key = ?`, ns, key).Scan(&data); err != nil {
+		t.Fatalf("raw select: %v", err)
+	}
+	return data
+}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	store := openEncrypted(t, path, "KEY-1")
+	if err := store.Save("form", "alice", answers{Salary: 91000}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if raw := rawData(t, store, "form", "alice"); !strings.HasPrefix(raw, encPrefix) || strings.Contains(raw, "91000") {
+		t.Errorf("data stored in plaintext: %q", raw)
+	}
+
+	reopened := openEncrypted(t, path, "KEY-1")
+	var got answers
+	if err := reopened.Load("form", "alice", &got); err != nil || got.Salary != 91000 {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+
+	plain, _ := New(path)
+	if err := plain.Load("form", "alice", &got); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Load without keys = %v, want ErrNotEncrypted", err)
+	}
+	if _, err := New(path, WithEncryption("OTHER", []byte("device-1"))); err == nil {
+		t.Error("expected data keys not to unwrap for another license")
+	}
+}
+
+func TestEncryption_PlaintextRowsMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	plain, _ := New(path)
+	plain.Save("form", "bob", answers{Salary: 50000})
+
+	store := openEncrypted(t, path, "KEY-1")
+	var got answers
+	if err := store.Load("form", "bob", &got); err != nil || got.Salary != 50000 {
+		t.Fatalf("Load legacy row = %+v, %v", got, err)
+	}
+	if n, err := store.Reencrypt(); err != nil || n != 1 {
+		t.Fatalf("Reencrypt = %d, %v", n, err)
+	}
+	if raw := rawData(t, store, "form", "bob"); !strings.HasPrefix(raw, encPrefix) {
+		t.Errorf("row not encrypted after Reencrypt: %q", raw)
+	}
+}
+
+func TestEncryption_RotateAndRewrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	store := openEncrypted(t, path, "KEY-1")
+	store.Save("form", "alice", answers{Salary: 1})
+	old, _ := encryptedKeyID(rawData(t, store, "form", "alice"))
+
+	id, err := store.RotateDataKey()
+	if err != nil || id == old {
+		t.Fatalf("RotateDataKey = %d, %v", id, err)
+	}
+	store.Save("form", "carol", answers{Salary: 2})
+	if n, err := store.Reencrypt(); err != nil || n != 1 {
+		t.Fatalf("Reencrypt = %d, %v", n, err)
+	}
+	var keys int
+	store.db.QueryRow(`SELECT COUNT(*) FROM data_keys`).Scan(&keys)
+	if keys != 1 {
+		t.Errorf("expected old data keys to be deleted, have %d", keys)
+	}
+
+	if err := store.RewrapDataKeys("KEY-2", []byte("device-1")); err != nil {
+		t.Fatalf("RewrapDataKeys: %v", err)
+	}
+	renewed := openEncrypted(t, path, "KEY-2")
+	var got answers
+	if err := renewed.Load("form", "alice", &got); err != nil || got.Salary != 1 {
+		t.Fatalf("Load after rewrap = %+v, %v", got, err)
+	}
+}
+
+func TestEncryption_RejectsSwappedRows(t *testing.T) {
+	store := openEncrypted(t, filepath.Join(t.TempDir(), "state.db"), "KEY-1")
+	store.Save("form", "alice", answers{Salary: 1})
+	store.Save("form", "bob", answers{Salary: 2})
+	store.db.Exec(`UPDATE state SET data = ? WHERE item_key = 'bob'`, rawData(t, store, "form", "alice"))
+
+	var got answers
+	if err := store.Load("form", "bob", &got); err == nil {
+		t.Fatalf("expected swapped row to fail, got %+v", got)
+	}
+}
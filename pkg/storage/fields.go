@@ -0,0 +1,160 @@
+// pkg/storage/fields.go
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/adi-ber/vjal-platform/pkg/security"
+)
+
+// A sealed field value is fieldPrefix + "<key id>:" + base64(nonce||ciphertext)
+// of the value's JSON encoding, stored as a string in place of the value.
+const fieldPrefix = "encf1:"
+
+// A plaintext string that happens to start with fieldPrefix is stored as
+// escapePrefix + value, so it is never mistaken for a sealed value.
+const escapePrefix = fieldPrefix + "="
+
+// SaveFields saves a map of form answers like Save, but first seals each
+// field listed in sensitive on its own with the active data key. The
+// fields stay sealed inside the row even when whole rows are not
+// encrypted, so they never reach the database or a backup in plaintext.
+// It fails with ErrNotEncrypted if a sensitive field is present and the
+// Store has no data keys.
+func (s *Store) SaveFields(namespace, key string, data map[string]interface{}, sensitive []string) error {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if str, ok := v.(string); ok && strings.HasPrefix(str, fieldPrefix) {
+			v = escapePrefix + str
+		}
+		out[k] = v
+	}
+	for _, field := range sensitive {
+		v, ok := data[field]
+		if !ok || v == nil {
+			continue
+		}
+		sealed, err := s.sealField(namespace, key, field, v)
+		if err != nil {
+			return err
+		}
+		out[field] = sealed
+	}
+	return s.Save(namespace, key, out)
+}
+
+// LoadFields loads a map saved by SaveFields and opens its sealed fields.
+// A missing row yields an empty map.
+func (s *Store) LoadFields(namespace, key string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	if err := s.Load(namespace, key, &data); err != nil {
+		return nil, err
+	}
+	for field, v := range data {
+		str, ok := v.(string)
+		if !ok || !strings.HasPrefix(str, fieldPrefix) {
+			continue
+		}
+		if strings.HasPrefix(str, escapePrefix) {
+			data[field] = strings.TrimPrefix(str, escapePrefix)
+			continue
+		}
+		plain, err := s.openField(namespace, key, field, str)
+		if err != nil {
+			return nil, err
+		}
+		data[field] = plain
+	}
+	return data, nil
+}
+
+func (s *Store) sealField(namespace, key, field string, v interface{}) (string, error) {
+	if s.enc == nil {
+		return "", fmt.Errorf("sensitive field %q: %w", field, ErrNotEncrypted)
+	}
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal field %q: %w", field, err)
+	}
+	s.enc.mu.RLock()
+	id, dek := s.enc.active, s.enc.keys[s.enc.active]
+	s.enc.mu.RUnlock()
+	sealed, err := security.Seal(dek, plain, fieldAAD(namespace, key, field))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt field %q: %w", field, err)
+	}
+	return fieldPrefix + strconv.FormatInt(id, 10) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Store) openField(namespace, key, field, data string) (interface{}, error) {
+	if s.enc == nil {
+		return nil, fmt.Errorf("state %s/%s field %q is encrypted: %w", namespace, key, field, ErrNotEncrypted)
+	}
+	idStr, b64, _ := strings.Cut(strings.TrimPrefix(data, fieldPrefix), ":")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("state %s/%s field %q: malformed ciphertext", namespace, key, field)
+	}
+	s.enc.mu.RLock()
+	dek, ok := s.enc.keys[id]
+	s.enc.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("state %s/%s field %q: unknown data key %d", namespace, key, field, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("state %s/%s field %q: malformed ciphertext", namespace, key, field)
+	}
+	plain, err := security.Open(dek, sealed, fieldAAD(namespace, key, field))
+	if err != nil {
+		return nil, fmt.Errorf("state %s/%s field %q: %w", namespace, key, field, err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(plain, &v); err != nil {
+		return nil, fmt.Errorf("state %s/%s field %q: %w", namespace, key, field, err)
+	}
+	return v, nil
+}
+
+// resealFields re-seals the fields of a decoded row that use a data key
+// other than active. It reports whether anything changed; rows that are not
+// JSON objects are left alone.
+func (s *Store) resealFields(namespace, key string, plain []byte, active int64) ([]byte, bool, error) {
+	var data map[string]interface{}
+	if json.Unmarshal(plain, &data) != nil {
+		return plain, false, nil
+	}
+	changed := false
+	for field, v := range data {
+		str, ok := v.(string)
+		if !ok || !strings.HasPrefix(str, fieldPrefix) || strings.HasPrefix(str, escapePrefix) {
+			continue
+		}
+		idStr, _, _ := strings.Cut(strings.TrimPrefix(str, fieldPrefix), ":")
+		if id, err := strconv.ParseInt(idStr, 10, 64); err == nil && id == active {
+			continue
+		}
+		opened, err := s.openField(namespace, key, field, str)
+		if err != nil {
+			return nil, false, err
+		}
+		if data[field], err = s.sealField(namespace, key, field, opened); err != nil {
+			return nil, false, err
+		}
+		changed = true
+	}
+	if !changed {
+		return plain, false, nil
+	}
+	out, err := json.Marshal(data)
+	return out, true, err
+}
+
+// fieldAAD binds a sealed field to its row and name.
+func fieldAAD(namespace, key, field string) []byte {
+	return []byte(namespace + "\x00" + key + "\x00" + field)
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFields_SealedIndividually(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := New(path, WithFieldEncryption("KEY-1", []byte("device-1")))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	data := map[string]interface{}{"name": "Ada", "salary": 91000.0, "city": "Leeds"}
+	if err := store.SaveFields("form", "page1", data, []string{"name", "salary", "missing"}); err != nil {
+		t.Fatalf("SaveFields: %v", err)
+	}
+	raw := rawData(t, store, "form", "page1")
+	if !strings.Contains(raw, "Leeds") || strings.Contains(raw, "Ada") || strings.Contains(raw, "91000") {
+		t.Errorf("unexpected stored row %q", raw)
+	}
+
+	got, err := store.LoadFields("form", "page1")
+	if err != nil || got["name"] != "Ada" || got["salary"] != 91000.0 || got["city"] != "Leeds" {
+		t.Fatalf("LoadFields = %v, %v", got, err)
+	}
+
+	store.RotateDataKey()
+	if n, err := store.Reencrypt(); err != nil || n != 1 {
+		t.Fatalf("Reencrypt = %d, %v", n, err)
+	}
+	if got, err := store.LoadFields("form", "page1"); err != nil || got["name"] != "Ada" {
+		t.Fatalf("LoadFields after rotation = %v, %v", got, err)
+	}
+}
+
+func TestFields_RequireKeys(t *testing.T) {
+	store, _ := New(filepath.Join(t.TempDir(), "state.db"))
+	err := store.SaveFields("form", "page1", map[string]interface{}{"name": "Ada"}, []string{"name"})
+	if !errors.Is(err, ErrNotEncrypted) {
+		t.Fatalf("SaveFields without keys = %v, want ErrNotEncrypted", err)
+	}
+	if err := store.SaveFields("form", "page1", map[string]interface{}{"city": "Leeds"}, []string{"name"}); err != nil {
+		t.Errorf("SaveFields without sensitive values: %v", err)
+	}
+}
+
+func TestFields_PlaintextWithPrefix(t *testing.T) {
+	store, err := New(filepath.Join(t.TempDir(), "state.db"), WithFieldEncryption("KEY-1", []byte("device-1")))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	data := map[string]interface{}{"name": "Ada", "note": "encf1:not a secret", "tag": "encf1:=x"}
+	if err := store.SaveFields("form", "page1", data, []string{"name"}); err != nil {
+		t.Fatalf("SaveFields: %v", err)
+	}
+	got, err := store.LoadFields("form", "page1")
+	if err != nil || got["note"] != "encf1:not a secret" || got["tag"] != "encf1:=x" || got["name"] != "Ada" {
+		t.Fatalf("LoadFields = %v, %v", got, err)
+	}
+
+	store.RotateDataKey()
+	if _, err := store.Reencrypt(); err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if got, err := store.LoadFields("form", "page1"); err != nil || got["note"] != "encf1:not a secret" {
+		t.Fatalf("LoadFields after rotation = %v, %v", got, err)
+	}
+}
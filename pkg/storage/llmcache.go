@@ -0,0 +1,98 @@
+// pkg/storage/llmcache.go
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// createLLMCache holds cached LLM responses. prompt_key is indexed so an
+// admin can purge every response of one prompt template.
+const createLLMCache = `
+CREATE TABLE IF NOT EXISTS llm_cache (
+  cache_key  TEXT PRIMARY KEY,
+  prompt_key TEXT NOT NULL,
+  data       TEXT NOT NULL,
+  expires    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS llm_cache_prompt_key ON llm_cache (prompt_key);`
+
+// cacheNamespace binds sealed cache entries to the cache, as a namespace
+// does for state rows.
+const cacheNamespace = "llm_cache"
+
+// cacheTimeFormat is fixed-width so expiry times compare correctly as
+// strings in SQL.
+const cacheTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// CacheEntry is one cached response.
+type CacheEntry struct {
+	Key       string    // hash of everything that determines the response
+	PromptKey string    // prompt template the request ran, may be empty
+	Data      []byte    // the encoded response
+	Expires   time.Time // after this the entry is ignored
+}
+
+// PutCached stores e, replacing any entry with the same key. Like state
+// rows, the data is sealed when the Store encrypts whole rows.
+func (s *Store) PutCached(e CacheEntry) error {
+	data, err := s.encodeData(cacheNamespace, e.Key, e.Data)
+	if err != nil {
+		return err
+	}
+	const stmt = `
+INSERT INTO llm_cache (cache_key, prompt_key, data, expires)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(cache_key) DO UPDATE SET prompt_key=excluded.prompt_key, data=excluded.data, expires=excluded.expires;`
+	if _, err := s.db.Exec(stmt, e.Key, e.PromptKey, data, e.Expires.UTC().Format(cacheTimeFormat)); err != nil {
+		return fmt.Errorf("failed to save cache entry: %w", err)
+	}
+	return nil
+}
+
+// GetCached returns the data cached under key, and false if there is none
+// or it expired before now.
+func (s *Store) GetCached(key string, now time.Time) ([]byte, bool, error) {
+	const query = `SELECT data, expires FROM llm_cache WHERE cache_key = ?;`
+	var data, expires string
+	if err := s.db.QueryRow(query, key).Scan(&data, &expires); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to query cache: %w", err)
+	}
+	if t, err := time.Parse(cacheTimeFormat, expires); err != nil || !now.Before(t) {
+		return nil, false, nil
+	}
+	plain, err := s.decodeData(cacheNamespace, key, data)
+	if err != nil {
+		return nil, false, err
+	}
+	return plain, true, nil
+}
+
+// PurgeCache deletes the cached responses of promptKey, or every cached
+// response if promptKey is empty, and returns how many were deleted.
+func (s *Store) PurgeCache(promptKey string) (int64, error) {
+	var res sql.Result
+	var err error
+	if promptKey == "" {
+		res, err = s.db.Exec(`DELETE FROM llm_cache;`)
+	} else {
+		res, err = s.db.Exec(`DELETE FROM llm_cache WHERE prompt_key = ?;`, promptKey)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge cache: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// PurgeExpiredCache deletes the entries that expired before now.
+func (s *Store) PurgeExpiredCache(now time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM llm_cache WHERE expires <= ?;`, now.UTC().Format(cacheTimeFormat))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge cache: %w", err)
+	}
+	return res.RowsAffected()
+}
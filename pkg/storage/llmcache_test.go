@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLLMCache_ExpiryAndPurge(t *testing.T) {
+	store, err := New(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	now := time.Now()
+	for _, e := range []CacheEntry{
+		{Key: "a", PromptKey: "userSummary", Data: []byte(`"A"`), Expires: now.Add(time.Hour)},
+		{Key: "b", PromptKey: "userSummary", Data: []byte(`"B"`), Expires: now.Add(time.Minute)},
+		{Key: "c", PromptKey: "accountingClassifier", Data: []byte(`"C"`), Expires: now.Add(time.Hour)},
+	} {
+		if err := store.PutCached(e); err != nil {
+			t.Fatalf("PutCached: %v", err)
+		}
+	}
+
+	if data, ok, err := store.GetCached("a", now); err != nil || !ok || string(data) != `"A"` {
+		t.Errorf("GetCached(a) = %s, %v, %v", data, ok, err)
+	}
+	if _, ok, _ := store.GetCached("b", now.Add(2*time.Minute)); ok {
+		t.Error("expired entry returned")
+	}
+	if _, ok, _ := store.GetCached("missing", now); ok {
+		t.Error("missing entry returned")
+	}
+
+	if n, err := store.PurgeExpiredCache(now.Add(2 * time.Minute)); err != nil || n != 1 {
+		t.Errorf("PurgeExpiredCache = %d, %v", n, err)
+	}
+	if n, err := store.PurgeCache("userSummary"); err != nil || n != 1 {
+		t.Errorf("PurgeCache(userSummary) = %d, %v", n, err)
+	}
+	if _, ok, _ := store.GetCached("c", now); !ok {
+		t.Error("other prompt key purged")
+	}
+	if n, err := store.PurgeCache(""); err != nil || n != 1 {
+		t.Errorf("PurgeCache() = %d, %v", n, err)
+	}
+}
+
+func TestLLMCache_SealedWithRows(t *testing.T) {
+	store := openEncrypted(t, filepath.Join(t.TempDir(), "state.db"), "KEY-1")
+	e := CacheEntry{Key: "k", Data: []byte(`"salary 91000"`), Expires: time.Now().Add(time.Hour)}
+	if err := store.PutCached(e); err != nil {
+		t.Fatalf("PutCached: %v", err)
+	}
+	var raw string
+	store.db.QueryRow(`SELECT data FROM llm_cache WHERE cache_key = 'k'`).Scan(&raw)
+	if !strings.HasPrefix(raw, encPrefix) || strings.Contains(raw, "91000") {
+		t.Errorf("cache entry stored in plaintext: %q", raw)
+	}
+	if data, ok, err := store.GetCached("k", time.Now()); err != nil || !ok || string(data) != string(e.Data) {
+		t.Errorf("GetCached = %s, %v, %v", data, ok, err)
+	}
+}
+
+func TestLLMCache_ReencryptDropsStaleEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	store := openEncrypted(t, path, "KEY-1")
+	expires := time.Now().Add(time.Hour)
+	store.PutCached(CacheEntry{Key: "old", Data: []byte(`"A"`), Expires: expires})
+	if _, err := store.RotateDataKey(); err != nil {
+		t.Fatalf("RotateDataKey: %v", err)
+	}
+	store.PutCached(CacheEntry{Key: "new", Data: []byte(`"B"`), Expires: expires})
+	if _, err := store.Reencrypt(); err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+
+	reopened := openEncrypted(t, path, "KEY-1")
+	if _, ok, err := reopened.GetCached("old", time.Now()); err != nil || ok {
+		t.Errorf("entry under deleted key: %v, %v", ok, err)
+	}
+	if data, ok, err := reopened.GetCached("new", time.Now()); err != nil || !ok || string(data) != `"B"` {
+		t.Errorf("GetCached(new) = %s, %v, %v", data, ok, err)
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
 
+	"github.com/adi-ber/vjal-platform/pkg/config"
 	"github.com/adi-ber/vjal-platform/pkg/metrics"
 	_ "modernc.org/sqlite"
 )
@@ -13,10 +17,14 @@ import (
 // Store provides a simple JSON-backed key/value store in SQLite.
 type Store struct {
 	db *sql.DB
+
+	auditMu sync.Mutex // serialises AppendAudit so the chain stays linear
+
+	enc *encryption // nil unless opened WithEncryption
 }
 
 // New opens (or creates) the SQLite file at dbPath and ensures the state table exists.
-func New(dbPath string) (*Store, error) {
+func New(dbPath string, opts ...Option) (*Store, error) {
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open sqlite db: %w", err)
@@ -31,7 +39,54 @@ CREATE TABLE IF NOT EXISTS state (
 	if _, err := db.Exec(createStmt); err != nil {
 		return nil, fmt.Errorf("failed to create state table: %w", err)
 	}
-	return &Store{db: db}, nil
+	const createCounters = `
+CREATE TABLE IF NOT EXISTS counters (
+  name   TEXT NOT NULL,
+  period TEXT NOT NULL,
+  value  INTEGER NOT NULL,
+  PRIMARY KEY (name, period)
+);`
+	if _, err := db.Exec(createCounters); err != nil {
+		return nil, fmt.Errorf("failed to create counters table: %w", err)
+	}
+	if _, err := db.Exec(createAudit); err != nil {
+		return nil, fmt.Errorf("failed to create audit table: %w", err)
+	}
+	if _, err := db.Exec(createDataKeys); err != nil {
+		return nil, fmt.Errorf("failed to create data keys table: %w", err)
+	}
+	if _, err := db.Exec(createLLMCache); err != nil {
+		return nil, fmt.Errorf("failed to create llm cache table: %w", err)
+	}
+	s := &Store{db: db}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Open opens <cfg.OutputDir>/state.db with data keys wrapped under the
+// license key and device ID that keys returns, usually license.SecretKeys.
+// When cfg.EncryptState is set whole rows are encrypted; otherwise only
+// sensitive fields are, and if the keys are unavailable the Store opens
+// without them and SaveFields refuses sensitive fields.
+func Open(cfg *config.AppConfig, keys config.KeySource) (*Store, error) {
+	dbPath := filepath.Join(cfg.OutputDir, "state.db")
+	licenseKey, deviceID, err := keys(cfg)
+	if cfg.EncryptState {
+		if err != nil {
+			return nil, fmt.Errorf("state encryption: %w", err)
+		}
+		return New(dbPath, WithEncryption(licenseKey, deviceID))
+	}
+	if err != nil {
+		log.Printf("⚠️  storage: no data keys, sensitive fields cannot be saved: %v", err)
+		return New(dbPath)
+	}
+	return New(dbPath, WithFieldEncryption(licenseKey, deviceID))
 }
 
 // Save stores the JSON-serialized value under (namespace, key).
@@ -43,11 +98,15 @@ func (s *Store) Save(namespace, key string, value interface{}) error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
+	data, err := s.encodeData(namespace, key, bytesData)
+	if err != nil {
+		return err
+	}
 	const stmt = `
 INSERT INTO state (namespace, item_key, data)
 VALUES (?, ?, ?)
 ON CONFLICT(namespace, item_key) DO UPDATE SET data=excluded.data;`
-	if _, err := s.db.Exec(stmt, namespace, key, string(bytesData)); err != nil {
+	if _, err := s.db.Exec(stmt, namespace, key, data); err != nil {
 		return fmt.Errorf("failed to save state: %w", err)
 	}
 	return nil
@@ -68,8 +127,87 @@ func (s *Store) Load(namespace, key string, dest interface{}) error {
 		}
 		return fmt.Errorf("failed to query state: %w", err)
 	}
-	if err := json.Unmarshal([]byte(jsonData), dest); err != nil {
+	plain, err := s.decodeData(namespace, key, jsonData)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(plain, dest); err != nil {
 		return fmt.Errorf("failed to unmarshal state data: %w", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// AddCounter adds delta to the counter (name, period) and returns its new
+// value. Counters start at zero.
+func (s *Store) AddCounter(name, period string, delta int64) (int64, error) {
+	const stmt = `
+INSERT INTO counters (name, period, value)
+VALUES (?, ?, ?)
+ON CONFLICT(name, period) DO UPDATE SET value = value + excluded.value
+RETURNING value;`
+	var value int64
+	if err := s.db.QueryRow(stmt, name, period, delta).Scan(&value); err != nil {
+		return 0, fmt.Errorf("failed to update counter: %w", err)
+	}
+	return value, nil
+}
+
+// Counter returns the value of (name, period), or zero if it was never added to.
+func (s *Store) Counter(name, period string) (int64, error) {
+	const query = `SELECT value FROM counters WHERE name = ? AND period = ?;`
+	var value int64
+	if err := s.db.QueryRow(query, name, period).Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to query counter: %w", err)
+	}
+	return value, nil
+}
+
+// Counter is one row of the counters table.
+type Counter struct {
+	Name   string
+	Period string
+	Value  int64
+}
+
+// SumCounter adds up name over the periods from..to inclusive. Periods
+// compare as strings, so "2006-01-02" days sort correctly.
+func (s *Store) SumCounter(name, from, to string) (int64, error) {
+	const query = `SELECT COALESCE(SUM(value), 0) FROM counters WHERE name = ? AND period BETWEEN ? AND ?;`
+	var sum int64
+	if err := s.db.QueryRow(query, name, from, to).Scan(&sum); err != nil {
+		return 0, fmt.Errorf("failed to sum counter: %w", err)
+	}
+	return sum, nil
+}
+
+// Counters returns every counter row with a period in from..to inclusive,
+// ordered by period and name.
+func (s *Store) Counters(from, to string) ([]Counter, error) {
+	const query = `SELECT name, period, value FROM counters WHERE period BETWEEN ? AND ? ORDER BY period, name;`
+	rows, err := s.db.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query counters: %w", err)
+	}
+	defer rows.Close()
+	var out []Counter
+	for rows.Next() {
+		var c Counter
+		if err := rows.Scan(&c.Name, &c.Period, &c.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan counter: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// Snapshot writes a consistent copy of the database to path, which must not
+// exist yet. It can run while the servers are using the database.
+func (s *Store) Snapshot(path string) error {
+	if _, err := s.db.Exec(`VACUUM INTO ?;`, path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
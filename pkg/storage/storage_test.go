@@ -53,4 +53,68 @@ func TestStore_SaveLoad(t *testing.T) {
 	if len(empty) != 0 {
 		t.Errorf("expected empty map for nonexistent key, got %v", empty)
 	}
-}
\ No newline at end of file
+}
+
+func TestStore_Counters(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "storagetest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dbPath := filepath.Join(tmpDir, "state.db")
+
+	store, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if v, err := store.Counter("calls", "2026-01"); err != nil || v != 0 {
+		t.Fatalf("expected unset counter to be 0, got %d, %v", v, err)
+	}
+	store.AddCounter("calls", "2026-01", 2)
+	if v, _ := store.AddCounter("calls", "2026-01", 3); v != 5 {
+		t.Errorf("expected 5 after adding 2 and 3, got %d", v)
+	}
+	store.AddCounter("calls", "2026-02", 1)
+
+	// Counters survive reopening the database
+	reopened, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if v, _ := reopened.Counter("calls", "2026-01"); v != 5 {
+		t.Errorf("expected persisted value 5, got %d", v)
+	}
+
+	if sum, _ := reopened.SumCounter("calls", "2026-01", "2026-12"); sum != 6 {
+		t.Errorf("expected sum 6 over the year, got %d", sum)
+	}
+	rows, err := reopened.Counters("2026-02", "2026-02")
+	if err != nil || len(rows) != 1 || rows[0] != (Counter{"calls", "2026-02", 1}) {
+		t.Errorf("unexpected counter rows %v, %v", rows, err)
+	}
+}
+
+func TestStore_Snapshot(t *testing.T) {
+	dir := t.TempDir()
+	store, err := New(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	store.Save("form", "alice", map[string]int{"salary": 1})
+
+	copyPath := filepath.Join(dir, "copy.db")
+	if err := store.Snapshot(copyPath); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if err := store.Snapshot(copyPath); err == nil {
+		t.Error("expected Snapshot over an existing file to fail")
+	}
+	copied, err := New(copyPath)
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	var got map[string]int
+	if err := copied.Load("form", "alice", &got); err != nil || got["salary"] != 1 {
+		t.Errorf("Load from snapshot = %v, %v", got, err)
+	}
+}
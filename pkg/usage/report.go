@@ -0,0 +1,134 @@
+// pkg/usage/report.go
+package usage
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+// reportContext separates the report MAC key from other keys derived from
+// the same license.
+const reportContext = "vjal-usage-report-v1"
+
+// Report is a usage summary for vendor billing, built from the persisted
+// counters. Its MAC is keyed from the license, so the vendor, who holds the
+// same license, can detect edits. Anyone with the license file can derive
+// the key, so a report is tamper-evident rather than unforgeable.
+type Report struct {
+	Version     int              `json:"version"`
+	LicenseKey  string           `json:"license_key"`
+	DeviceID    string           `json:"deviceID,omitempty"`
+	From        string           `json:"from"` // first day, "2006-01-02"
+	To          string           `json:"to"`   // last day, inclusive
+	GeneratedAt time.Time        `json:"generatedAt"`
+	LLMCalls    int64            `json:"llmCalls"`
+	PDFs        int64            `json:"pdfs"`
+	Features    map[string]int64 `json:"features"`
+	Daily       []DayUsage       `json:"daily"`
+
+	MAC string `json:"mac,omitempty"` // base64 HMAC-SHA256 over the other fields
+}
+
+// DayUsage holds every counter recorded on one day.
+type DayUsage struct {
+	Day      string           `json:"day"`
+	Counters map[string]int64 `json:"counters"`
+}
+
+// ErrReportMAC means a report was edited or made with a different license.
+var ErrReportMAC = errors.New("usage report MAC does not match")
+
+// BuildReport summarises the counters in store for the days from..to
+// inclusive and signs the result with lic.
+func BuildReport(store *storage.Store, lic *license.License, deviceID string, from, to time.Time) (*Report, error) {
+	r := &Report{
+		Version:     1,
+		LicenseKey:  lic.Key,
+		DeviceID:    deviceID,
+		From:        from.UTC().Format(DayFormat),
+		To:          to.UTC().Format(DayFormat),
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+		Features:    map[string]int64{},
+		Daily:       []DayUsage{},
+	}
+	if r.From > r.To {
+		return nil, fmt.Errorf("report range %s..%s is empty", r.From, r.To)
+	}
+	rows, err := store.Counters(r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range rows {
+		switch {
+		case c.Name == LLMCalls:
+			r.LLMCalls += c.Value
+		case c.Name == PDFs:
+			r.PDFs += c.Value
+		case strings.HasPrefix(c.Name, featurePrefix):
+			r.Features[strings.TrimPrefix(c.Name, featurePrefix)] += c.Value
+		}
+		if n := len(r.Daily); n == 0 || r.Daily[n-1].Day != c.Period {
+			r.Daily = append(r.Daily, DayUsage{Day: c.Period, Counters: map[string]int64{}})
+		}
+		r.Daily[len(r.Daily)-1].Counters[c.Name] = c.Value
+	}
+	if err := r.Sign(lic); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Sign sets the MAC using a key derived from lic.
+func (r *Report) Sign(lic *license.License) error {
+	mac, err := r.mac(lic)
+	if err != nil {
+		return err
+	}
+	r.MAC = base64.StdEncoding.EncodeToString(mac)
+	return nil
+}
+
+// Verify checks that the report belongs to lic and has not been edited.
+func (r *Report) Verify(lic *license.License) error {
+	if r.LicenseKey != lic.Key {
+		return fmt.Errorf("report is for license %s, not %s", r.LicenseKey, lic.Key)
+	}
+	got, err := base64.StdEncoding.DecodeString(r.MAC)
+	if err != nil || r.MAC == "" {
+		return ErrReportMAC
+	}
+	want, err := r.mac(lic)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(got, want) {
+		return ErrReportMAC
+	}
+	return nil
+}
+
+func (r *Report) mac(lic *license.License) ([]byte, error) {
+	key, err := hkdf.Key(sha256.New, []byte(lic.Key+"\n"+lic.Signature), nil, reportContext, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	c := *r
+	c.MAC = ""
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(payload)
+	return h.Sum(nil), nil
+}
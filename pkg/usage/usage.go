@@ -0,0 +1,309 @@
+// pkg/usage/usage.go
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/metrics"
+	"github.com/adi-ber/vjal-platform/pkg/problem"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+// Quota names.
+const (
+	LLMCalls    = "llm_calls"   // LLM prompts per calendar month
+	PDFs        = "pdfs"        // PDFs rendered per calendar month
+	Definitions = "definitions" // form definitions deployed at once
+)
+
+// quotas declares every quota and the license limit that caps it. Monthly
+// quotas are counted per day in storage and summed over the calendar month;
+// the others are checked against a value the caller supplies.
+var quotas = []struct {
+	name    string
+	monthly bool
+	limit   func(*license.Limits) int64
+}{
+	{LLMCalls, true, func(l *license.Limits) int64 { return l.LLMCallsPerMonth }},
+	{PDFs, true, func(l *license.Limits) int64 { return l.PDFsPerMonth }},
+	{Definitions, false, func(l *license.Limits) int64 { return l.MaxDefinitions }},
+}
+
+// LicenseSource supplies the current license; *license.Manager implements it.
+type LicenseSource interface {
+	License() *license.License
+}
+
+// featurePrefix namespaces per-feature counters, see CountFeature.
+const featurePrefix = "feature:"
+
+// Quota is the state of one quota, as served by the admin endpoint.
+type Quota struct {
+	Name   string `json:"name"`
+	Period string `json:"period,omitempty"` // "2006-01" for monthly quotas
+	Used   int64  `json:"used"`
+	Limit  int64  `json:"limit"` // 0 means unlimited
+}
+
+// QuotaExceededError reports a request that would go over a licensed limit.
+type QuotaExceededError struct {
+	Quota  string
+	Period string
+	Used   int64
+	Limit  int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	if e.Period == "" {
+		return fmt.Sprintf("quota %s exceeded: %d in use, license allows %d", e.Quota, e.Used, e.Limit)
+	}
+	return fmt.Sprintf("quota %s exceeded: %d of %d used in %s", e.Quota, e.Used, e.Limit, e.Period)
+}
+
+// Tracker counts usage per day in a storage.Store, so counts survive
+// restarts and can be reported for any date range, and enforces the limits
+// of the current license.
+type Tracker struct {
+	store *storage.Store
+	lic   LicenseSource
+	now   func() time.Time
+
+	mu sync.Mutex // serialises check-and-add
+}
+
+// NewTracker counts usage in store against the limits of lic's license.
+func NewTracker(store *storage.Store, lic LicenseSource) *Tracker {
+	return &Tracker{store: store, lic: lic, now: time.Now}
+}
+
+// Consume records n uses of a monthly quota, or fails with a
+// *QuotaExceededError and records nothing if that would pass the limit.
+func (t *Tracker) Consume(quota string, n int64) error {
+	_, err := t.consume(quota, n)
+	return err
+}
+
+// consume is Consume, returning the day the uses were recorded on.
+func (t *Tracker) consume(quota string, n int64) (string, error) {
+	limit, monthly, err := t.limit(quota)
+	if err != nil {
+		return "", err
+	}
+	if !monthly {
+		return "", fmt.Errorf("quota %s is not counted; use Check", quota)
+	}
+	now := t.now().UTC()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	used, err := t.monthly(quota, now)
+	if err != nil {
+		return "", err
+	}
+	if limit > 0 && used+n > limit {
+		metrics.QuotaExceededTotal.WithLabelValues(quota).Inc()
+		return "", &QuotaExceededError{Quota: quota, Period: now.Format(monthFormat), Used: used, Limit: limit}
+	}
+	day := now.Format(DayFormat)
+	if _, err = t.store.AddCounter(quota, day, n); err != nil {
+		return "", err
+	}
+	metrics.UsageCurrent.WithLabelValues(quota).Set(float64(used + n))
+	metrics.UsageLimit.WithLabelValues(quota).Set(float64(limit))
+	return day, nil
+}
+
+// refund takes back n uses of quota recorded on day. It never fails a
+// request: storage errors are logged.
+func (t *Tracker) refund(quota, day string, n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.store.AddCounter(quota, day, -n); err != nil {
+		log.Printf("⚠️  usage: refunding %d %s: %v", n, quota, err)
+		return
+	}
+	if used, err := t.monthly(quota, t.now().UTC()); err == nil {
+		metrics.UsageCurrent.WithLabelValues(quota).Set(float64(used))
+	}
+}
+
+// CountFeature records one use of a license feature for usage reports. It
+// never fails a request: storage errors are logged. Use a Submission to count
+// only requests that succeed.
+func (t *Tracker) CountFeature(feature string) {
+	day := t.now().UTC().Format(DayFormat)
+	if _, err := t.store.AddCounter(featurePrefix+feature, day, 1); err != nil {
+		log.Printf("⚠️  usage: counting feature %s: %v", feature, err)
+	}
+}
+
+// Submission gates one request and counts the features it used once the
+// request succeeds. Its Gate is a copy whose OnAllow records each feature a
+// check lets through; checks that pass on a failed request count nothing.
+// Quotas it consumes can be refunded if the work they paid for fails.
+type Submission struct {
+	license.Gate
+	tracker  *Tracker
+	features []string
+	consumed []consumption
+}
+
+// consumption is one Consume made by a Submission.
+type consumption struct {
+	quota, day string
+	n          int64
+}
+
+// Submission starts gating one request with g.
+func (t *Tracker) Submission(g license.Gate) *Submission {
+	s := &Submission{Gate: g, tracker: t}
+	s.OnAllow = func(feature string) {
+		if !slices.Contains(s.features, feature) {
+			s.features = append(s.features, feature)
+		}
+	}
+	return s
+}
+
+// Consume is Tracker.Consume, remembered so that Refund can take it back.
+func (s *Submission) Consume(quota string, n int64) error {
+	day, err := s.tracker.consume(quota, n)
+	if err != nil {
+		return err
+	}
+	s.consumed = append(s.consumed, consumption{quota, day, n})
+	return nil
+}
+
+// Refund takes back every use of the given quotas this submission
+// consumed, e.g. when the LLM call they were counted for fails.
+func (s *Submission) Refund(quotas ...string) {
+	kept := s.consumed[:0]
+	for _, c := range s.consumed {
+		if !slices.Contains(quotas, c.quota) {
+			kept = append(kept, c)
+			continue
+		}
+		s.tracker.refund(c.quota, c.day, c.n)
+	}
+	s.consumed = kept
+}
+
+// Done counts each feature the request used once. Call it after the
+// request succeeded.
+func (s *Submission) Done() {
+	for _, f := range s.features {
+		s.tracker.CountFeature(f)
+	}
+	s.features = nil
+}
+
+// Check fails with a *QuotaExceededError if inUse is over the limit of a
+// quota that is not counted, such as Definitions.
+func (t *Tracker) Check(quota string, inUse int64) error {
+	limit, _, err := t.limit(quota)
+	if err != nil {
+		return err
+	}
+	metrics.UsageCurrent.WithLabelValues(quota).Set(float64(inUse))
+	metrics.UsageLimit.WithLabelValues(quota).Set(float64(limit))
+	if limit > 0 && inUse > limit {
+		metrics.QuotaExceededTotal.WithLabelValues(quota).Inc()
+		return &QuotaExceededError{Quota: quota, Used: inUse, Limit: limit}
+	}
+	return nil
+}
+
+// Usage returns the current period's count and limit of every monthly quota.
+func (t *Tracker) Usage() ([]Quota, error) {
+	now := t.now().UTC()
+	period := now.Format(monthFormat)
+	var out []Quota
+	for _, q := range quotas {
+		if !q.monthly {
+			continue
+		}
+		limit, _, _ := t.limit(q.name)
+		used, err := t.monthly(q.name, now)
+		if err != nil {
+			return nil, err
+		}
+		metrics.UsageCurrent.WithLabelValues(q.name).Set(float64(used))
+		metrics.UsageLimit.WithLabelValues(q.name).Set(float64(limit))
+		out = append(out, Quota{Name: q.name, Period: period, Used: used, Limit: limit})
+	}
+	return out, nil
+}
+
+// Handler serves Usage as JSON, for an admin endpoint.
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		quotas, err := t.Usage()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(quotas)
+	})
+}
+
+// limit returns the licensed limit of quota and whether it is monthly.
+func (t *Tracker) limit(quota string) (int64, bool, error) {
+	for _, q := range quotas {
+		if q.name != quota {
+			continue
+		}
+		lic := t.lic.License()
+		if lic == nil || lic.Limits == nil {
+			return 0, q.monthly, nil
+		}
+		return q.limit(lic.Limits), q.monthly, nil
+	}
+	return 0, false, fmt.Errorf("unknown quota %q", quota)
+}
+
+// Counter periods are days; quotas are enforced per calendar month.
+const (
+	DayFormat   = "2006-01-02"
+	monthFormat = "2006-01"
+)
+
+// monthly sums name over the calendar month containing now.
+func (t *Tracker) monthly(name string, now time.Time) (int64, error) {
+	month := now.Format(monthFormat)
+	return t.store.SumCounter(name, month+"-01", month+"-31")
+}
+
+// WriteProblem answers a *QuotaExceededError with 429 Too Many Requests and
+// an application/problem+json body. Any other error, such as a storage
+// failure while counting, is logged and answered with 500.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	var qe *QuotaExceededError
+	if !errors.As(err, &qe) {
+		log.Printf("⚠️  usage: %s: %v", r.URL.Path, err)
+		problem.Write(w, problem.Details{
+			Type:     "urn:vjal:problem:usage",
+			Title:    "Usage could not be counted",
+			Status:   http.StatusInternalServerError,
+			Instance: r.URL.Path,
+		})
+		return
+	}
+	problem.Write(w, problem.Details{
+		Type:       "urn:vjal:problem:quota-exceeded",
+		Title:      "Quota exceeded",
+		Status:     http.StatusTooManyRequests,
+		Detail:     qe.Error(),
+		Instance:   r.URL.Path,
+		Extensions: map[string]interface{}{"quota": qe.Quota, "used": qe.Used, "limit": qe.Limit},
+	})
+}
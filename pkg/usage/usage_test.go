@@ -0,0 +1,204 @@
+package usage
+
+import (
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adi-ber/vjal-platform/pkg/license"
+	"github.com/adi-ber/vjal-platform/pkg/storage"
+)
+
+type staticLicense struct{ lic *license.License }
+
+func (s staticLicense) License() *license.License { return s.lic }
+
+func newTestTracker(t *testing.T, limits *license.Limits) (*Tracker, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+	store, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	return NewTracker(store, staticLicense{&license.License{Limits: limits}}), dbPath
+}
+
+func TestTracker_ConsumeEnforcesMonthlyLimit(t *testing.T) {
+	tr, dbPath := newTestTracker(t, &license.Limits{LLMCallsPerMonth: 3})
+	tr.now = func() time.Time { return time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC) }
+
+	if err := tr.Consume(LLMCalls, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Consume(LLMCalls, 2); err == nil {
+		t.Fatal("expected quota error when going over the limit")
+	}
+	if err := tr.Consume(LLMCalls, 1); err != nil {
+		t.Fatalf("expected the last call to fit, got %v", err)
+	}
+
+	// A fresh tracker over the same database sees the persisted count.
+	store, _ := storage.New(dbPath)
+	restarted := NewTracker(store, tr.lic)
+	restarted.now = tr.now
+	var qe *QuotaExceededError
+	if err := restarted.Consume(LLMCalls, 1); !errors.As(err, &qe) || qe.Used != 3 || qe.Period != "2026-03" {
+		t.Fatalf("expected QuotaExceededError with 3 used in 2026-03, got %v", err)
+	}
+
+	// A new month starts from zero.
+	restarted.now = func() time.Time { return time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC) }
+	if err := restarted.Consume(LLMCalls, 1); err != nil {
+		t.Errorf("expected a new period to reset usage, got %v", err)
+	}
+}
+
+func TestTracker_UnlimitedAndCheck(t *testing.T) {
+	tr, _ := newTestTracker(t, &license.Limits{MaxDefinitions: 2})
+	if err := tr.Consume(PDFs, 1000); err != nil {
+		t.Errorf("expected zero limit to mean unlimited, got %v", err)
+	}
+	if err := tr.Check(Definitions, 2); err != nil {
+		t.Errorf("expected 2 definitions to be allowed, got %v", err)
+	}
+	if err := tr.Check(Definitions, 3); err == nil {
+		t.Error("expected 3 definitions to exceed the limit")
+	}
+	if err := tr.Consume("bogus", 1); err == nil {
+		t.Error("expected error for unknown quota")
+	}
+}
+
+func TestTracker_HandlerAndProblem(t *testing.T) {
+	tr, _ := newTestTracker(t, &license.Limits{PDFsPerMonth: 1})
+	tr.Consume(PDFs, 1)
+
+	rec := httptest.NewRecorder()
+	tr.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/admin/usage", nil))
+	if !strings.Contains(rec.Body.String(), `"name":"pdfs"`) || !strings.Contains(rec.Body.String(), `"used":1,"limit":1`) {
+		t.Errorf("unexpected usage body: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	WriteProblem(rec, httptest.NewRequest("POST", "/process", nil), tr.Consume(PDFs, 1))
+	if rec.Code != 429 || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected 429 problem+json, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	WriteProblem(rec, httptest.NewRequest("POST", "/process", nil), errors.New("database is locked"))
+	if rec.Code != 500 || strings.Contains(rec.Body.String(), "quota") || strings.Contains(rec.Body.String(), "locked") {
+		t.Errorf("expected a plain 500 for a storage error, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReport_BuildSignVerify(t *testing.T) {
+	lic := &license.License{Key: "BILL", Signature: "c2ln"}
+	tr, _ := newTestTracker(t, nil)
+	tr.lic = staticLicense{lic}
+	day := func(d int) func() time.Time {
+		return func() time.Time { return time.Date(2026, 5, d, 12, 0, 0, 0, time.UTC) }
+	}
+	tr.now = day(1)
+	tr.Consume(LLMCalls, 2)
+	tr.CountFeature("accounting")
+	tr.now = day(2)
+	tr.Consume(PDFs, 1)
+	tr.now = day(20)
+	tr.Consume(LLMCalls, 5) // outside the report range
+
+	r, err := BuildReport(tr.store, lic, "dev-1", time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("BuildReport: %v", err)
+	}
+	if r.LLMCalls != 2 || r.PDFs != 1 || r.Features["accounting"] != 1 || len(r.Daily) != 2 {
+		t.Errorf("unexpected report totals: %+v", r)
+	}
+	if err := r.Verify(lic); err != nil {
+		t.Fatalf("expected report to verify, got %v", err)
+	}
+
+	r.LLMCalls = 1
+	if err := r.Verify(lic); !errors.Is(err, ErrReportMAC) {
+		t.Errorf("expected ErrReportMAC after editing, got %v", err)
+	}
+	r.LLMCalls = 2
+	if err := r.Verify(&license.License{Key: "BILL", Signature: "b3RoZXI="}); !errors.Is(err, ErrReportMAC) {
+		t.Errorf("expected ErrReportMAC for a different license, got %v", err)
+	}
+}
+
+func TestGate_OnAllowCountsFeatures(t *testing.T) {
+	tr, _ := newTestTracker(t, nil)
+	lic := &license.License{Features: []string{"pdf_export"}}
+	g := license.Gate{Formats: map[string]string{"pdf": "pdf_export"}, OnAllow: tr.CountFeature}
+
+	g.Format(lic, "pdf")
+	g.Format(lic, "html")
+	g.Format(&license.License{}, "pdf")
+
+	today := tr.now().UTC().Format(DayFormat)
+	if n, _ := tr.store.Counter("feature:pdf_export", today); n != 1 {
+		t.Errorf("expected one counted use of pdf_export, got %d", n)
+	}
+}
+
+func TestSubmission_CountsOnlyWhenDone(t *testing.T) {
+	tr, _ := newTestTracker(t, nil)
+	lic := &license.License{Features: []string{"pdf_export", "accounting"}}
+	g := license.Gate{
+		PromptKeys: map[string]string{"accountingClassifier": "accounting"},
+		Formats:    map[string]string{"pdf": "pdf_export"},
+	}
+	today := tr.now().UTC().Format(DayFormat)
+
+	failed := tr.Submission(g)
+	failed.PromptKey(lic, "accountingClassifier")
+	failed.Format(lic, "pdf")
+	if n, _ := tr.store.Counter("feature:accounting", today); n != 0 {
+		t.Errorf("expected nothing counted before Done, got %d", n)
+	}
+
+	ok := tr.Submission(g)
+	ok.PromptKey(lic, "accountingClassifier")
+	ok.PromptKey(lic, "accountingClassifier")
+	ok.Format(lic, "pdf")
+	ok.Done()
+	for _, f := range []string{"accounting", "pdf_export"} {
+		if n, _ := tr.store.Counter("feature:"+f, today); n != 1 {
+			t.Errorf("expected one counted use of %s, got %d", f, n)
+		}
+	}
+	if g.OnAllow != nil {
+		t.Error("Submission must not change the gate it was given")
+	}
+}
+
+func TestSubmission_RefundsFailedWork(t *testing.T) {
+	tr, _ := newTestTracker(t, &license.Limits{LLMCallsPerMonth: 1})
+	today := tr.now().UTC().Format(DayFormat)
+
+	failed := tr.Submission(license.Gate{})
+	if err := failed.Consume(LLMCalls, 1); err != nil {
+		t.Fatalf("Consume: %v", err)
+	}
+	if err := failed.Consume(PDFs, 1); err != nil {
+		t.Fatalf("Consume: %v", err)
+	}
+	failed.Refund(LLMCalls)
+	failed.Refund(LLMCalls) // already refunded
+	if n, _ := tr.store.Counter(LLMCalls, today); n != 0 {
+		t.Errorf("expected the failed call to be refunded, got %d", n)
+	}
+	if n, _ := tr.store.Counter(PDFs, today); n != 1 {
+		t.Errorf("expected other quotas to stay consumed, got %d", n)
+	}
+
+	// The refunded call leaves room under the limit.
+	if err := tr.Submission(license.Gate{}).Consume(LLMCalls, 1); err != nil {
+		t.Errorf("expected the refunded quota to be available, got %v", err)
+	}
+}